@@ -11,14 +11,17 @@ require (
 )
 
 require (
-	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/pkoukk/tiktoken-go v0.1.8
+	github.com/pkoukk/tiktoken-go-loader v0.0.2
 	github.com/samber/lo v1.52.0
 	go.uber.org/zap v1.27.1
+	golang.org/x/image v0.33.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/bytedance/gopkg v0.1.3 // indirect
+	github.com/dlclark/regexp2 v1.10.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 )
 
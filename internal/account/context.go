@@ -0,0 +1,239 @@
+package account
+
+import (
+	"context"
+	"sync"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// defaultPool 全局账号池，启动时由 Init 按配置创建，未初始化时没有账号
+var defaultPool = &Pool{byName: map[string]*Account{}}
+
+// Init 根据配置创建全局账号池
+func Init(cfg *config.Config) error {
+	p, err := NewPool(cfg)
+	if err != nil {
+		return err
+	}
+	defaultPool = p
+	return nil
+}
+
+// Len 返回全局账号池的账号数
+func Len() int {
+	return defaultPool.Len()
+}
+
+// AccountStats 返回全局账号池中所有账号的统计信息
+func AccountStats() []Stats {
+	return defaultPool.Stats()
+}
+
+// lease 一个请求占用的账号，第一次需要 Cookie 时才分配，同一请求内的所有上游调用使用同一个账号
+type lease struct {
+	pool    *Pool
+	mu      sync.Mutex
+	key     string // 粘性绑定的键
+	account *Account
+	probe   bool
+	failed  map[*Account]bool // 请求中已经失败过的账号，换号重试时跳过
+}
+
+type leaseKey struct{}
+
+// WithLease 在 ctx 中放入请求级别的账号租约，key 非空时同一 key 的请求固定使用同一个账号
+// 返回的 release 在请求结束时调用
+func WithLease(ctx context.Context, key string) (context.Context, func()) {
+	return defaultPool.WithLease(ctx, key)
+}
+
+// WithLease 在 ctx 中放入使用该账号池的租约
+func (p *Pool) WithLease(ctx context.Context, key string) (context.Context, func()) {
+	l := &lease{pool: p, key: key}
+	return context.WithValue(ctx, leaseKey{}, l), l.release
+}
+
+// leaseFrom 取出 ctx 中的租约
+func leaseFrom(ctx context.Context) *lease {
+	l, _ := ctx.Value(leaseKey{}).(*lease)
+	return l
+}
+
+// accountLocked 返回租约的账号，尚未分配时按粘性键与策略分配，调用方需持有 l.mu
+func (l *lease) accountLocked() *Account {
+	if l.account == nil {
+		l.account, l.probe = l.pool.acquire(l.key)
+	}
+	return l.account
+}
+
+// release 释放租约占用的账号
+func (l *lease) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.account != nil {
+		l.pool.release(l.account, l.probe)
+		l.account = nil
+	}
+}
+
+// SetStickyKey 设置粘性绑定的键，只在请求头没有指定会话键且尚未分配账号时生效
+func SetStickyKey(ctx context.Context, key string) {
+	l := leaseFrom(ctx)
+	if l == nil || key == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.key == "" && l.account == nil {
+		l.key = key
+	}
+}
+
+// Prefer 要求请求使用指定的账号，用于引用已上传的文件或复用会话，这些数据只属于创建它们的账号
+// 已分配其他账号或指定的账号不可用时返回 false；name 为空或 ctx 中没有租约时总是返回 true
+func Prefer(ctx context.Context, name string) bool {
+	l := leaseFrom(ctx)
+	if l == nil || name == "" {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.account != nil {
+		return l.account.name == name
+	}
+	a, probe, ok := l.pool.reserve(name)
+	if !ok {
+		return false
+	}
+	l.account, l.probe = a, probe
+	return true
+}
+
+// Cookie 返回请求使用的账号的 Cookie，ctx 中没有租约时按策略临时选择一个账号
+func Cookie(ctx context.Context) string {
+	if l := leaseFrom(ctx); l != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if a := l.accountLocked(); a != nil {
+			return l.pool.cookie(a)
+		}
+		return ""
+	}
+	a, probe := defaultPool.acquire("")
+	if a == nil {
+		return ""
+	}
+	defaultPool.release(a, probe)
+	return defaultPool.cookie(a)
+}
+
+// Current 返回请求当前使用的账号名与 Cookie，同一请求的并发调用可能换用其他账号，调用方按返回的账号名调用 Failover
+func Current(ctx context.Context) (name, cookie string) {
+	l := leaseFrom(ctx)
+	if l == nil {
+		return "", Cookie(ctx)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if a := l.accountLocked(); a != nil {
+		return a.name, l.pool.cookie(a)
+	}
+	return "", ""
+}
+
+// Failover 记录账号 name 的上游响应，401/403 或 402/429 摘除该账号后请求改用另一个可用账号并返回 true，由调用方用新账号重试
+// 同一请求的并发调用已经换用其他账号时直接返回 true；没有其他可用账号时返回 false，请求继续使用原来的账号
+func Failover(ctx context.Context, name string, status int) bool {
+	l := leaseFrom(ctx)
+	if l == nil {
+		return false
+	}
+	a := l.pool.get(name)
+	if a == nil {
+		return false
+	}
+	l.pool.report(a, status)
+	if health := statusHealth(status); health != HealthExpired && health != HealthRateLimited {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.failed == nil {
+		l.failed = make(map[*Account]bool)
+	}
+	l.failed[a] = true
+	if l.account != a {
+		return l.account != nil
+	}
+	next, probe := l.pool.failover(l.key, l.failed)
+	if next == nil {
+		return false
+	}
+	l.pool.release(l.account, l.probe)
+	l.account, l.probe = next, probe
+	logger.Warn("账号不可用，改用其他账号重试",
+		zap.String("from", a.name),
+		zap.String("to", next.name),
+		zap.Int("status", status),
+	)
+	return true
+}
+
+// Name 返回请求使用的账号名，ctx 中没有租约时返回空字符串
+func Name(ctx context.Context) string {
+	l := leaseFrom(ctx)
+	if l == nil {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if a := l.accountLocked(); a != nil {
+		return a.name
+	}
+	return ""
+}
+
+// Scope 配置了多个账号时返回请求使用的账号名，用于区分各账号上传的文件与会话；只有一个账号时返回空字符串
+func Scope(ctx context.Context) string {
+	l := leaseFrom(ctx)
+	if l == nil || l.pool.Len() <= 1 {
+		return ""
+	}
+	return Name(ctx)
+}
+
+// Report 记录上游响应的 HTTP 状态码，鉴权失败或额度耗尽时摘除请求使用的账号
+func Report(ctx context.Context, status int) {
+	l := leaseFrom(ctx)
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	a := l.account
+	l.mu.Unlock()
+	if a != nil {
+		l.pool.report(a, status)
+	}
+}
+
+// Detach 返回不随请求取消的 ctx，继续使用请求已分配的账号，用于请求返回后仍在运行的后台任务
+// 返回的 release 在后台任务结束时调用
+func Detach(ctx context.Context) (context.Context, func()) {
+	l := leaseFrom(ctx)
+	if l == nil {
+		return context.Background(), func() {}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	detached := &lease{pool: l.pool, key: l.key, account: l.account}
+	if detached.account != nil {
+		l.pool.hold(detached.account)
+	}
+	return context.WithValue(context.Background(), leaseKey{}, detached), detached.release
+}
@@ -0,0 +1,259 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// Cookie 的健康状态
+const (
+	HealthUnknown     = "unknown"      // 尚未检查
+	HealthValid       = "valid"        // Cookie 有效
+	HealthExpired     = "expired"      // 401/403，Cookie 已失效
+	HealthRateLimited = "rate_limited" // 402/429，额度耗尽或被限流
+	HealthError       = "error"        // 网络错误或上游返回其他错误，无法判断 Cookie 是否有效
+)
+
+// TransitionEvent 账号健康状态变化时 webhook 通知的事件名
+const TransitionEvent = "account.health_changed"
+
+// Transition 账号健康状态的一次变化，同时作为 webhook 的请求体
+type Transition struct {
+	Event   string    `json:"event"`
+	Account string    `json:"account"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Status  int       `json:"status,omitempty"` // 上游返回的 HTTP 状态码
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// ProbeFunc 用指定的 Cookie 发起一次开销很小的上游请求，返回 HTTP 状态码；请求未得到响应或响应内容表示失败时返回 error
+type ProbeFunc func(ctx context.Context, cookie string) (int, error)
+
+// statusHealth 将上游响应的状态码转换为健康状态
+func statusHealth(status int) string {
+	switch {
+	case status == 401 || status == 403:
+		return HealthExpired
+	case status == 402 || status == 429:
+		return HealthRateLimited
+	case status > 0 && status < 400:
+		return HealthValid
+	default:
+		return HealthError
+	}
+}
+
+// setHealthLocked 更新账号的健康状态，状态变化时返回 Transition，调用方在释放锁后交给 emit
+func (p *Pool) setHealthLocked(a *Account, health string, status int, errMsg string) *Transition {
+	if a.health == health {
+		return nil
+	}
+	t := &Transition{
+		Event:   TransitionEvent,
+		Account: a.name,
+		From:    a.health,
+		To:      health,
+		Status:  status,
+		Error:   errMsg,
+		Time:    time.Now(),
+	}
+	a.health = health
+	return t
+}
+
+// emit 记录健康状态变化并在配置了 webhook 时异步通知；启动后第一次检查确认有效不算作变化
+func (p *Pool) emit(t *Transition) {
+	if t == nil || (t.From == HealthUnknown && t.To == HealthValid) {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("account", t.Account),
+		zap.String("from", t.From),
+		zap.String("to", t.To),
+		zap.Int("status", t.Status),
+		zap.String("error", t.Error),
+	}
+	if t.To == HealthValid {
+		logger.Info("账号健康状态变化", fields...)
+	} else {
+		logger.Warn("账号健康状态变化", fields...)
+	}
+
+	if p.health.WebhookURL == "" {
+		return
+	}
+	p.notifies.Add(1)
+	go func() {
+		defer p.notifies.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), p.health.Timeout)
+		defer cancel()
+		resp, err := p.webhook.R().
+			SetContext(ctx).
+			SetBody(t).
+			Post(p.health.WebhookURL)
+		if err == nil && resp.IsError() {
+			err = fmt.Errorf("status %d", resp.StatusCode())
+		}
+		if err != nil {
+			logger.Warn("发送账号状态通知失败", zap.String("account", t.Account), zap.Error(err))
+		}
+	}()
+}
+
+// WaitNotifications 等待全局账号池发送中的健康状态通知完成，用于退出前
+func WaitNotifications() {
+	defaultPool.WaitNotifications()
+}
+
+// WaitNotifications 等待发送中的健康状态通知完成，每个通知最多等待 health_check.timeout
+func (p *Pool) WaitNotifications() {
+	p.notifies.Wait()
+}
+
+// StartHealthCheck 设置全局账号池的健康检查请求，启用健康检查时立即检查一次所有账号并按间隔定期检查
+func StartHealthCheck(probe ProbeFunc) {
+	defaultPool.StartHealthCheck(probe)
+}
+
+// StartHealthCheck 设置健康检查请求，启用健康检查时在后台定期检查所有账号
+func (p *Pool) StartHealthCheck(probe ProbeFunc) {
+	p.mu.Lock()
+	p.probe = probe
+	p.mu.Unlock()
+	if !p.health.Enabled {
+		return
+	}
+	go func() {
+		p.CheckAll(context.Background())
+		for range time.Tick(p.health.Interval) {
+			p.CheckAll(context.Background())
+		}
+	}()
+}
+
+// CheckAll 并发检查所有账号
+func (p *Pool) CheckAll(ctx context.Context) {
+	p.mu.Lock()
+	accounts := append([]*Account(nil), p.accounts...)
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, a := range accounts {
+		wg.Add(1)
+		go func(a *Account) {
+			defer wg.Done()
+			p.check(ctx, a)
+		}(a)
+	}
+	wg.Wait()
+}
+
+// Check 立即检查指定的账号，返回检查后的统计信息，账号不存在时返回 false
+func Check(ctx context.Context, name string) (Stats, bool) {
+	return defaultPool.Check(ctx, name)
+}
+
+// Check 立即检查指定的账号，返回检查后的统计信息，账号不存在时返回 false
+func (p *Pool) Check(ctx context.Context, name string) (Stats, bool) {
+	p.mu.Lock()
+	a, exists := p.byName[name]
+	p.mu.Unlock()
+	if !exists {
+		return Stats{}, false
+	}
+	p.check(ctx, a)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return a.statsLocked(), true
+}
+
+// check 用账号的 Cookie 发起一次健康检查：有效时恢复因 Cookie 失效被摘除的账号，失效或额度耗尽时摘除账号
+// 检查请求不消耗对话额度，有效不代表额度已恢复，因额度耗尽摘除的账号仍等冷却结束后由对话请求试探恢复
+// 网络错误等无法判断的结果只记录，不影响账号的分配
+func (p *Pool) check(ctx context.Context, a *Account) {
+	p.mu.Lock()
+	probe, cookie := p.probe, a.cookie
+	p.mu.Unlock()
+	if probe == nil {
+		return
+	}
+
+	if p.health.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.health.Timeout)
+		defer cancel()
+	}
+	status, err := probe(ctx, cookie)
+
+	p.mu.Lock()
+	if a.cookie != cookie {
+		// 检查期间 Cookie 被替换，结果已经过时
+		p.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	health := statusHealth(status)
+	if err != nil && health == HealthValid {
+		health = HealthError
+	}
+	a.lastCheck = now
+	a.lastError = ""
+	if err != nil {
+		a.lastError = err.Error()
+	}
+	switch health {
+	case HealthValid:
+		if a.reason == ReasonUnauthorized {
+			p.restoreLocked(a)
+		}
+		a.lastSuccess = now
+	case HealthExpired, HealthRateLimited:
+		p.ejectLocked(a, status, now)
+	}
+	transition := p.setHealthLocked(a, health, status, a.lastError)
+	p.mu.Unlock()
+	p.emit(transition)
+}
+
+// Upsert 在全局账号池中替换账号的 Cookie，账号不存在时添加新账号，返回替换或添加后的统计信息
+func Upsert(ctx context.Context, ac config.AccountConfig) (Stats, bool) {
+	return defaultPool.Upsert(ctx, ac)
+}
+
+// Upsert 在运行时替换账号的 Cookie，账号不存在时添加新账号，created 表示是否为新账号
+// 因 Cookie 失效被摘除的账号替换后立即恢复分配，因额度耗尽被摘除的账号仍等冷却结束；设置了健康检查请求时立即检查一次
+// 已分配该账号的请求后续的上游调用也会使用新 Cookie
+// 同一账号应替换为同一 Monica 用户的新 Cookie，否则该账号上传的文件与会话将无法继续使用
+func (p *Pool) Upsert(ctx context.Context, ac config.AccountConfig) (stats Stats, created bool) {
+	p.mu.Lock()
+	a, exists := p.byName[ac.Name]
+	if exists {
+		a.cookie = ac.Cookie
+		if ac.Weight > 0 {
+			a.weight = ac.Weight
+		}
+		if a.reason == ReasonUnauthorized {
+			p.restoreLocked(a)
+			a.reason = ""
+		}
+		// 健康状态保留到检查完成，替换失效的 Cookie 后检查有效时会作为状态变化通知
+		a.lastError = ""
+	} else {
+		a = p.addLocked(ac.Name, ac.Cookie, ac.Weight)
+	}
+	p.mu.Unlock()
+	logger.Info("账号 Cookie 已更新", zap.String("account", ac.Name), zap.Bool("created", !exists))
+
+	stats, _ = p.Check(ctx, ac.Name)
+	return stats, !exists
+}
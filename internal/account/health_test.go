@@ -0,0 +1,107 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"monica-proxy/internal/config"
+)
+
+// fakeProbe Cookie 为 good 时返回 200，为 limited 时返回 429，其余返回 401
+func fakeProbe(_ context.Context, cookie string) (int, error) {
+	switch cookie {
+	case "good":
+		return http.StatusOK, nil
+	case "limited":
+		return http.StatusTooManyRequests, nil
+	default:
+		return http.StatusUnauthorized, nil
+	}
+}
+
+// TestHealthCheck 测试健康检查的状态变化、webhook 通知与运行时替换 Cookie
+func TestHealthCheck(t *testing.T) {
+	transitions := make(chan Transition, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tr Transition
+		if err := json.NewDecoder(r.Body).Decode(&tr); err != nil {
+			t.Errorf("解析 webhook 请求失败: %v", err)
+		}
+		transitions <- tr
+	}))
+	defer webhook.Close()
+
+	cfg := &config.Config{
+		Monica: config.MonicaConfig{Accounts: []config.AccountConfig{
+			{Name: "a", Cookie: "bad"},
+			{Name: "b", Cookie: "good"},
+			{Name: "c", Cookie: "limited"},
+		}},
+		AccountPool: config.AccountPoolConfig{Strategy: config.AccountRoundRobin, Cooldown: time.Minute, MaxCooldown: time.Hour, StickyTTL: time.Hour},
+		HealthCheck: config.HealthCheckConfig{Timeout: time.Second, WebhookURL: webhook.URL},
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 通知在后台发送，结束前等待发送完成再关闭 webhook
+	defer p.WaitNotifications()
+	p.StartHealthCheck(fakeProbe)
+	p.CheckAll(context.Background())
+
+	want := map[string][2]string{
+		"a": {HealthExpired, StateEjected},
+		"b": {HealthValid, StateActive},
+		"c": {HealthRateLimited, StateEjected},
+	}
+	for _, s := range p.Stats() {
+		if w := want[s.Name]; s.Health != w[0] || s.State != w[1] || s.LastCheck == nil {
+			t.Errorf("账号 %s 的状态错误: health=%s state=%s", s.Name, s.Health, s.State)
+		}
+	}
+	// 启动后第一次检查有效的账号不通知
+	got := map[string]string{}
+	for i := 0; i < 2; i++ {
+		select {
+		case tr := <-transitions:
+			got[tr.Account] = tr.To
+		case <-time.After(time.Second):
+			t.Fatal("未收到 webhook 通知")
+		}
+	}
+	if got["a"] != HealthExpired || got["c"] != HealthRateLimited {
+		t.Errorf("webhook 通知错误: %v", got)
+	}
+
+	stats, created := p.Upsert(context.Background(), config.AccountConfig{Name: "a", Cookie: "good"})
+	if created || stats.Health != HealthValid || stats.State != StateActive || stats.LastSuccess == nil {
+		t.Errorf("替换 Cookie 后账号未恢复: %+v", stats)
+	}
+	select {
+	case tr := <-transitions:
+		if tr.Account != "a" || tr.From != HealthExpired || tr.To != HealthValid {
+			t.Errorf("恢复通知错误: %+v", tr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("未收到恢复通知")
+	}
+
+	// 检查不消耗额度，因额度耗尽摘除的账号检查有效时仍等冷却结束
+	stats, _ = p.Upsert(context.Background(), config.AccountConfig{Name: "c", Cookie: "good"})
+	if stats.Health != HealthValid || stats.State != StateEjected || stats.Reason != ReasonQuotaExceeded {
+		t.Errorf("额度耗尽的账号不应因检查有效而恢复: %+v", stats)
+	}
+	// 对话请求返回 401 摘除的账号检查有效时立即恢复
+	p.report(p.byName["b"], http.StatusUnauthorized)
+	if stats, _ := p.Check(context.Background(), "b"); stats.State != StateActive {
+		t.Errorf("Cookie 失效摘除的账号检查有效后未恢复: %+v", stats)
+	}
+
+	if _, created := p.Upsert(context.Background(), config.AccountConfig{Name: "d", Cookie: "good", Weight: 2}); !created || p.Len() != 4 {
+		t.Error("未添加新账号")
+	}
+}
@@ -0,0 +1,397 @@
+// Package account 管理多个 Monica 账号：按策略为请求分配账号，鉴权失败或额度耗尽时摘除账号，冷却后放行一个请求试探恢复
+package account
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"monica-proxy/internal/cache"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/logger"
+
+	"github.com/go-resty/resty/v2"
+	"go.uber.org/zap"
+)
+
+// 账号状态
+const (
+	StateActive  = "active"  // 正常使用
+	StateEjected = "ejected" // 已摘除，冷却中
+	StateProbing = "probing" // 冷却结束，正在用一个请求试探
+)
+
+// 摘除原因
+const (
+	ReasonUnauthorized  = "unauthorized"   // 401/403，Cookie 失效
+	ReasonQuotaExceeded = "quota_exceeded" // 402/429，额度耗尽或被限流
+)
+
+// Account 账号池中的一个账号，状态字段由 Pool.mu 保护
+type Account struct {
+	name   string
+	cookie string
+	weight int
+
+	state        string
+	reason       string
+	ejectedUntil time.Time
+	cooldown     time.Duration // 最近一次摘除的冷却时间，试探失败时加倍
+	current      int           // 平滑加权轮询的当前权重
+	inFlight     int
+	requests     int64
+	failures     int64
+	ejections    int64
+	lastSuccess  time.Time
+	lastFailure  time.Time
+
+	health    string // Cookie 的健康状态，由健康检查与上游响应更新
+	lastCheck time.Time
+	lastError string
+}
+
+// Stats 账号的运行统计
+type Stats struct {
+	Name         string     `json:"name"`
+	State        string     `json:"state"`
+	Reason       string     `json:"reason,omitempty"` // 最近一次摘除的原因
+	Weight       int        `json:"weight"`
+	InFlight     int        `json:"in_flight"`
+	Requests     int64      `json:"requests"`  // 分配到该账号的请求数
+	Failures     int64      `json:"failures"`  // 上游返回错误状态码的次数
+	Ejections    int64      `json:"ejections"` // 被摘除的次数
+	EjectedUntil *time.Time `json:"ejected_until,omitempty"`
+	LastSuccess  *time.Time `json:"last_success,omitempty"`
+	LastFailure  *time.Time `json:"last_failure,omitempty"`
+	Health       string     `json:"health"`
+	LastCheck    *time.Time `json:"last_check,omitempty"` // 最近一次健康检查的时间
+	LastError    string     `json:"last_error,omitempty"` // 最近一次健康检查的错误
+}
+
+// Pool 账号池
+type Pool struct {
+	mu       sync.Mutex
+	cfg      config.AccountPoolConfig
+	health   config.HealthCheckConfig
+	probe    ProbeFunc // 健康检查使用的上游请求，由 StartHealthCheck 设置
+	accounts []*Account
+	byName   map[string]*Account
+	next     int                // 轮询的下一个位置
+	sticky   *cache.LRU[string] // 会话键或 user 到账号名的绑定
+
+	webhook  *resty.Client  // 发送健康状态通知的客户端
+	notifies sync.WaitGroup // 发送中的健康状态通知
+}
+
+// NewPool 根据配置创建账号池，未配置 monica.accounts 时使用 monica.cookie 作为唯一的账号
+func NewPool(cfg *config.Config) (*Pool, error) {
+	accounts := cfg.Monica.Accounts
+	if len(accounts) == 0 && cfg.Monica.Cookie != "" {
+		accounts = []config.AccountConfig{{Name: "default", Cookie: cfg.Monica.Cookie}}
+	}
+	sticky, err := cache.New[string](cache.Options{MaxEntries: 100000, TTL: cfg.AccountPool.StickyTTL})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pool{
+		cfg:    cfg.AccountPool,
+		health: cfg.HealthCheck,
+		byName:  make(map[string]*Account, len(accounts)),
+		sticky:  sticky,
+		webhook: resty.New(),
+	}
+	for i, ac := range accounts {
+		name := ac.Name
+		if name == "" {
+			name = fmt.Sprintf("account-%d", i+1)
+		}
+		if _, exists := p.byName[name]; exists {
+			return nil, fmt.Errorf("duplicate account name: %s", name)
+		}
+		p.addLocked(name, ac.Cookie, ac.Weight)
+	}
+	return p, nil
+}
+
+// addLocked 添加账号，调用方需持有锁
+func (p *Pool) addLocked(name, cookie string, weight int) *Account {
+	a := &Account{name: name, cookie: cookie, weight: max(weight, 1), state: StateActive, health: HealthUnknown}
+	p.accounts = append(p.accounts, a)
+	p.byName[name] = a
+	return a
+}
+
+// Len 返回账号数
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.accounts)
+}
+
+// cookie 返回账号当前的 Cookie，Cookie 可能在运行时被替换
+func (p *Pool) cookie(a *Account) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return a.cookie
+}
+
+// Stats 返回所有账号的统计信息，按配置顺序排列
+func (p *Pool) Stats() []Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]Stats, 0, len(p.accounts))
+	for _, a := range p.accounts {
+		stats = append(stats, a.statsLocked())
+	}
+	return stats
+}
+
+// statsLocked 返回账号的统计信息，调用方需持有 Pool.mu
+func (a *Account) statsLocked() Stats {
+	s := Stats{
+		Name:      a.name,
+		State:     a.state,
+		Reason:    a.reason,
+		Weight:    a.weight,
+		InFlight:  a.inFlight,
+		Requests:  a.requests,
+		Failures:  a.failures,
+		Ejections: a.ejections,
+		Health:    a.health,
+		LastError: a.lastError,
+	}
+	if a.state != StateActive {
+		s.EjectedUntil = timePtr(a.ejectedUntil)
+	}
+	s.LastSuccess = timePtr(a.lastSuccess)
+	s.LastFailure = timePtr(a.lastFailure)
+	s.LastCheck = timePtr(a.lastCheck)
+	return s
+}
+
+// timePtr 零值返回 nil，JSON 中省略
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// availableLocked 账号是否可以分配新请求：正常状态，或冷却已结束且没有其他请求在试探
+func (p *Pool) availableLocked(a *Account, now time.Time) bool {
+	return a.state == StateActive || (a.state == StateEjected && !now.Before(a.ejectedUntil))
+}
+
+// acquire 为请求分配账号：优先使用 key 绑定的账号，否则按策略选择
+// 所有账号都被摘除时使用最早结束冷却的账号，返回的 probe 表示该请求用于试探冷却结束的账号
+func (p *Pool) acquire(key string) (a *Account, probe bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.accounts) == 0 {
+		return nil, false
+	}
+
+	now := time.Now()
+	if key != "" {
+		if name, ok := p.sticky.Get(key); ok {
+			if bound, exists := p.byName[name]; exists && p.availableLocked(bound, now) {
+				a = bound
+			}
+		}
+	}
+	if a == nil {
+		a = p.selectLocked(now, nil)
+	}
+	if a == nil {
+		a = p.accounts[0]
+		for _, candidate := range p.accounts[1:] {
+			if candidate.ejectedUntil.Before(a.ejectedUntil) {
+				a = candidate
+			}
+		}
+		logger.Warn("所有账号均已摘除，使用最早结束冷却的账号", zap.String("account", a.name))
+	}
+	if key != "" {
+		p.sticky.Set(key, a.name, 0)
+	}
+	return a, p.takeLocked(a, now)
+}
+
+// reserve 为请求占用指定的账号，账号不存在或不可用时返回 false
+func (p *Pool) reserve(name string) (a *Account, probe, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	a, exists := p.byName[name]
+	if !exists || !p.availableLocked(a, now) {
+		return nil, false, false
+	}
+	return a, p.takeLocked(a, now), true
+}
+
+// takeLocked 记录账号上新增的请求，冷却已结束的账号转为试探状态并返回 true
+func (p *Pool) takeLocked(a *Account, now time.Time) bool {
+	a.inFlight++
+	a.requests++
+	if a.state == StateEjected && !now.Before(a.ejectedUntil) {
+		a.state = StateProbing
+		logger.Info("账号冷却结束，开始试探", zap.String("account", a.name))
+		return true
+	}
+	return false
+}
+
+// failover 按策略从 skip 之外的可用账号中选择一个，没有可用账号时返回 nil；key 非空时改为绑定新账号
+func (p *Pool) failover(key string, skip map[*Account]bool) (a *Account, probe bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if a = p.selectLocked(now, skip); a == nil {
+		return nil, false
+	}
+	if key != "" {
+		p.sticky.Set(key, a.name, 0)
+	}
+	return a, p.takeLocked(a, now)
+}
+
+// get 按名称查找账号
+func (p *Pool) get(name string) *Account {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.byName[name]
+}
+
+// selectLocked 按配置的策略从可用账号中选择一个，跳过 skip 中的账号，没有可用账号时返回 nil
+func (p *Pool) selectLocked(now time.Time, skip map[*Account]bool) *Account {
+	n := len(p.accounts)
+	switch p.cfg.Strategy {
+	case config.AccountWeighted:
+		// 平滑加权轮询：每轮所有账号加上各自的权重，选出当前权重最大的账号后减去总权重
+		var best *Account
+		total := 0
+		for _, a := range p.accounts {
+			if !p.availableLocked(a, now) || skip[a] {
+				continue
+			}
+			a.current += a.weight
+			total += a.weight
+			if best == nil || a.current > best.current {
+				best = a
+			}
+		}
+		if best != nil {
+			best.current -= total
+		}
+		return best
+
+	case config.AccountLeastInFlight:
+		// 进行中请求数相同时按轮询顺序选择，避免总是落在第一个账号
+		var best *Account
+		bestIndex := 0
+		for i := 0; i < n; i++ {
+			index := (p.next + i) % n
+			a := p.accounts[index]
+			if p.availableLocked(a, now) && !skip[a] && (best == nil || a.inFlight < best.inFlight) {
+				best, bestIndex = a, index
+			}
+		}
+		if best != nil {
+			p.next = bestIndex + 1
+		}
+		return best
+
+	default:
+		for i := 0; i < n; i++ {
+			index := (p.next + i) % n
+			if a := p.accounts[index]; p.availableLocked(a, now) && !skip[a] {
+				p.next = index + 1
+				return a
+			}
+		}
+		return nil
+	}
+}
+
+// hold 为后台任务再占用一次账号，不检查账号状态
+func (p *Pool) hold(a *Account) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a.inFlight++
+}
+
+// release 请求结束，试探请求没有得到上游响应时账号回到冷却结束的状态，由下一个请求继续试探
+func (p *Pool) release(a *Account, probe bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a.inFlight--
+	if probe && a.state == StateProbing {
+		a.state = StateEjected
+	}
+}
+
+// report 记录上游响应：成功时恢复试探中的账号，401/403 与 402/429 时摘除账号，其余错误只计数
+func (p *Pool) report(a *Account, status int) {
+	p.mu.Lock()
+	now := time.Now()
+	var transition *Transition
+	switch health := statusHealth(status); health {
+	case HealthValid:
+		// 摘除前发出的请求成功不代表账号已恢复，只有试探请求成功才恢复账号
+		if a.state == StateProbing {
+			p.restoreLocked(a)
+		}
+		a.lastSuccess = now
+		transition = p.setHealthLocked(a, health, status, "")
+	case HealthExpired, HealthRateLimited:
+		a.failures++
+		a.lastFailure = now
+		p.ejectLocked(a, status, now)
+		transition = p.setHealthLocked(a, health, status, "")
+	default:
+		a.failures++
+		a.lastFailure = now
+	}
+	p.mu.Unlock()
+	p.emit(transition)
+}
+
+// restoreLocked 恢复被摘除的账号，调用方需持有锁
+func (p *Pool) restoreLocked(a *Account) {
+	if a.state != StateActive {
+		logger.Info("账号已恢复", zap.String("account", a.name))
+	}
+	a.state = StateActive
+	a.cooldown = 0
+}
+
+// ejectLocked 摘除账号：首次摘除使用基础冷却时间，试探失败时加倍，调用方需持有锁
+func (p *Pool) ejectLocked(a *Account, status int, now time.Time) {
+	if a.state == StateEjected {
+		// 同一账号的其他请求已经触发摘除
+		return
+	}
+	reason := ReasonUnauthorized
+	if statusHealth(status) == HealthRateLimited {
+		reason = ReasonQuotaExceeded
+	}
+	if a.state == StateProbing && a.cooldown > 0 {
+		a.cooldown = min(a.cooldown*2, p.cfg.MaxCooldown)
+	} else {
+		a.cooldown = p.cfg.Cooldown
+	}
+	a.state = StateEjected
+	a.reason = reason
+	a.ejectedUntil = now.Add(a.cooldown)
+	a.ejections++
+	logger.Warn("账号已摘除",
+		zap.String("account", a.name),
+		zap.String("reason", reason),
+		zap.Int("status", status),
+		zap.Duration("cooldown", a.cooldown),
+	)
+}
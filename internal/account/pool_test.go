@@ -0,0 +1,175 @@
+package account
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"monica-proxy/internal/config"
+)
+
+func testPool(t *testing.T, strategy string, weights ...int) *Pool {
+	t.Helper()
+	cfg := &config.Config{AccountPool: config.AccountPoolConfig{
+		Strategy:    strategy,
+		Cooldown:    time.Minute,
+		MaxCooldown: 4 * time.Minute,
+		StickyTTL:   time.Hour,
+	}}
+	for _, w := range weights {
+		cfg.Monica.Accounts = append(cfg.Monica.Accounts, config.AccountConfig{Cookie: "cookie", Weight: w})
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// pick 分配一个账号后立即释放，返回账号名
+func pick(p *Pool, key string) string {
+	ctx, release := p.WithLease(context.Background(), key)
+	defer release()
+	return Name(ctx)
+}
+
+// TestStrategies 测试三种选择策略的分配顺序
+func TestStrategies(t *testing.T) {
+	p := testPool(t, config.AccountRoundRobin, 0, 0, 0)
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, pick(p, ""))
+	}
+	if want := []string{"account-1", "account-2", "account-3", "account-1"}; !slices.Equal(got, want) {
+		t.Errorf("round_robin = %v, want %v", got, want)
+	}
+
+	p = testPool(t, config.AccountWeighted, 3, 1)
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		counts[pick(p, "")]++
+	}
+	if counts["account-1"] != 6 || counts["account-2"] != 2 {
+		t.Errorf("weighted 分配结果错误: %v", counts)
+	}
+
+	p = testPool(t, config.AccountLeastInFlight, 0, 0)
+	ctx, release := p.WithLease(context.Background(), "")
+	defer release()
+	busy := Name(ctx)
+	for i := 0; i < 3; i++ {
+		if name := pick(p, ""); name == busy {
+			t.Errorf("least_in_flight 选择了进行中请求更多的账号 %s", name)
+		}
+	}
+}
+
+// TestEjectAndProbe 测试摘除、冷却结束后的试探与冷却时间加倍
+func TestEjectAndProbe(t *testing.T) {
+	p := testPool(t, config.AccountRoundRobin, 0, 0)
+	a := p.byName["account-1"]
+
+	ctx, release := p.WithLease(context.Background(), "")
+	if Name(ctx) != "account-1" {
+		t.Fatal("第一个请求未分配到 account-1")
+	}
+	Report(ctx, 401)
+	release()
+	if a.state != StateEjected || a.reason != ReasonUnauthorized || a.cooldown != time.Minute {
+		t.Fatalf("401 未摘除账号: state=%s reason=%s cooldown=%s", a.state, a.reason, a.cooldown)
+	}
+	for i := 0; i < 3; i++ {
+		if name := pick(p, ""); name != "account-2" {
+			t.Errorf("冷却中的账号仍被分配: %s", name)
+		}
+	}
+
+	// 冷却结束后只有一个请求用于试探，试探失败时冷却时间加倍
+	a.ejectedUntil = time.Now()
+	ctx, release = p.WithLease(context.Background(), "")
+	if !Prefer(ctx, "account-1") || a.state != StateProbing {
+		t.Fatalf("冷却结束的账号未进入试探: state=%s", a.state)
+	}
+	if Prefer(context.WithValue(context.Background(), leaseKey{}, &lease{pool: p}), "account-1") {
+		t.Error("试探中的账号被分配给其他请求")
+	}
+	Report(ctx, 429)
+	release()
+	if a.state != StateEjected || a.reason != ReasonQuotaExceeded || a.cooldown != 2*time.Minute {
+		t.Fatalf("试探失败后状态错误: state=%s reason=%s cooldown=%s", a.state, a.reason, a.cooldown)
+	}
+
+	a.ejectedUntil = time.Now()
+	ctx, release = p.WithLease(context.Background(), "")
+	Prefer(ctx, "account-1")
+	Report(ctx, 200)
+	release()
+	if a.state != StateActive || a.cooldown != 0 || a.lastSuccess.IsZero() {
+		t.Errorf("试探成功后账号未恢复: state=%s cooldown=%s", a.state, a.cooldown)
+	}
+
+	stats := p.Stats()
+	if stats[0].Ejections != 2 || stats[0].Failures != 2 || stats[0].InFlight != 0 {
+		t.Errorf("账号统计错误: %+v", stats[0])
+	}
+}
+
+// TestStickyAndDetach 测试粘性绑定与后台任务继续使用请求的账号
+func TestStickyAndDetach(t *testing.T) {
+	p := testPool(t, config.AccountRoundRobin, 0, 0, 0)
+	first := pick(p, "user-a")
+	for i := 0; i < 3; i++ {
+		if name := pick(p, "user-a"); name != first {
+			t.Errorf("粘性绑定失效: %s != %s", name, first)
+		}
+	}
+
+	ctx, release := p.WithLease(context.Background(), "")
+	name := Name(ctx)
+	detached, done := Detach(ctx)
+	release()
+	if Name(detached) != name || p.byName[name].inFlight != 1 {
+		t.Errorf("后台任务未继续使用请求的账号")
+	}
+	done()
+	if p.byName[name].inFlight != 0 {
+		t.Errorf("后台任务结束后未释放账号")
+	}
+}
+
+// TestFailover 测试账号失效后请求换用未失败过的可用账号，没有其他账号时继续使用原账号
+func TestFailover(t *testing.T) {
+	p := testPool(t, config.AccountRoundRobin, 0, 0, 0)
+	ctx, release := p.WithLease(context.Background(), "user-a")
+
+	if Name(ctx) != "account-1" || !Failover(ctx, "account-1", 401) || Name(ctx) != "account-2" {
+		t.Fatalf("401 后未换用下一个账号: %s", Name(ctx))
+	}
+	if a := p.byName["account-1"]; a.state != StateEjected || a.inFlight != 0 {
+		t.Errorf("失败的账号未摘除或未释放: state=%s in_flight=%d", a.state, a.inFlight)
+	}
+	if name, _ := p.sticky.Get("user-a"); name != "account-2" {
+		t.Errorf("粘性绑定未改为换用的账号: %s", name)
+	}
+	// 并发调用已经换号时直接用当前账号重试
+	if !Failover(ctx, "account-1", 401) || Name(ctx) != "account-2" {
+		t.Errorf("其他调用已换号时不应再次换号: %s", Name(ctx))
+	}
+	if Failover(ctx, "account-2", 500) || Name(ctx) != "account-2" {
+		t.Errorf("非鉴权或额度错误不应换号")
+	}
+	if !Failover(ctx, "account-2", 429) || Name(ctx) != "account-3" {
+		t.Fatalf("429 后未换用下一个账号: %s", Name(ctx))
+	}
+	if Failover(ctx, "account-3", 401) || Name(ctx) != "account-3" {
+		t.Errorf("没有其他可用账号时应继续使用原账号: %s", Name(ctx))
+	}
+	release()
+
+	for _, stats := range p.Stats() {
+		if stats.InFlight != 0 {
+			t.Errorf("账号 %s 未释放: %d", stats.Name, stats.InFlight)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package apiserver
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"monica-proxy/internal/account"
 	"monica-proxy/internal/config"
 	"monica-proxy/internal/errors"
 	"monica-proxy/internal/logger"
@@ -11,9 +13,11 @@ import (
 	"monica-proxy/internal/service"
 	"monica-proxy/internal/types"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
-	"github.com/sashabaranov/go-openai"
 	"go.uber.org/zap"
 )
 
@@ -25,44 +29,84 @@ func RegisterRoutes(e *echo.Echo, cfg *config.Config) {
 	// 添加中间件
 	e.Use(middleware.BearerAuth(cfg))
 	e.Use(middleware.RequestLogger(cfg))
+	e.Use(middleware.ResponseHeaders())
+	e.Use(middleware.AccountLease())
 
 	// 初始化服务实例
 	chatService := service.NewChatService(cfg)
 	modelService := service.NewModelService(cfg)
 	imageService := service.NewImageService(cfg)
 	customBotService := service.NewCustomBotService(cfg)
+	messagesService := service.NewMessagesService(cfg, chatService, customBotService)
+	responsesService := service.NewResponsesService(cfg, chatService, customBotService)
+	fileService := service.NewFileService(cfg)
 
 	// ChatGPT 风格的请求转发到 /v1/chat/completions
 	e.POST("/v1/chat/completions", createChatCompletionHandler(chatService, customBotService, cfg))
+	// Anthropic 风格的请求转发到 /v1/messages
+	e.POST("/v1/messages", createMessagesHandler(messagesService))
+	// OpenAI Responses API
+	e.POST("/v1/responses", createResponsesHandler(responsesService))
+	e.GET("/v1/responses/:id", createGetResponseHandler(responsesService))
+	e.DELETE("/v1/responses/:id", createDeleteResponseHandler(responsesService))
+	// OpenAI Files API
+	e.POST("/v1/files", createUploadFileHandler(fileService))
+	e.GET("/v1/files", createListFilesHandler(fileService))
+	e.GET("/v1/files/:id", createGetFileHandler(fileService))
+	e.DELETE("/v1/files/:id", createDeleteFileHandler(fileService))
+	e.GET("/v1/files/:id/content", createFileContentHandler(fileService))
 	// 获取支持的模型列表
 	e.GET("/v1/models", createListModelsHandler(modelService))
 	// DALL-E 风格的图片生成请求
-	e.POST("/v1/images/generations", createImageGenerationHandler(imageService))
+	e.POST("/v1/images/generations", createImageGenerationHandler(imageService, cfg))
+	// 图像编辑与生成相似图像，multipart 上传原图
+	e.POST("/v1/images/edits", createImageEditHandler(imageService, cfg, false))
+	e.POST("/v1/images/variations", createImageEditHandler(imageService, cfg, true))
+	// 本地保存的生成图片，通过签名链接访问
+	e.GET(service.ImageFilePath+":id", createImageFileHandler(imageService))
+	// 异步图像生成任务
+	e.GET("/v1/images/jobs/:id", createImageJobHandler(imageService, cfg))
+	e.GET("/v1/images/jobs/:id/events", createImageJobEventsHandler(imageService, cfg))
 	// Custom Bot 测试接口
 	e.POST("/v1/chat/custom-bot/:bot_uid", createCustomBotHandler(customBotService, cfg))
 	// 新增不带bot_uid的路由，使用环境变量中的BOT_UID
 	e.POST("/v1/chat/custom-bot", createCustomBotHandler(customBotService, cfg))
+	// 运维接口，都需要 X-Admin-Token
+	admin := e.Group("/admin", middleware.AdminAuth(cfg))
+	// 上传缓存统计
+	admin.GET("/cache/stats", createCacheStatsHandler())
+	// 账号池状态与统计
+	admin.GET("/accounts", createAccountStatsHandler(cfg))
+	admin.POST("/accounts/:name/check", createAccountCheckHandler())
+	// 运行时替换或添加账号的 Cookie
+	admin.PUT("/accounts/:name", createAccountUpdateHandler())
 }
 
 // createChatCompletionHandler 创建聊天完成处理器
 func createChatCompletionHandler(chatService service.ChatService, customBotService service.CustomBotService, cfg *config.Config) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var req openai.ChatCompletionRequest
+		var req types.ChatCompletionRequest
 		if err := c.Bind(&req); err != nil {
 			return errors.NewBadRequestError("无效的请求数据", err)
 		}
+		mode, err := reasoningMode(cfg, &req)
+		if err != nil {
+			return err
+		}
 
-		ctx := c.Request().Context()
+		// 去掉模型名的 :online 后缀，联网搜索开关随 ctx 传给请求转换
+		ctx, model := types.WithWebSearch(c.Request().Context(), req.Model, req.WebSearch)
+		req.Model = model
+		ctx = types.WithSessionKey(ctx, c.Request().Header.Get(types.SessionKeyHeader))
 		var result interface{}
-		var err error
 
 		// 检查是否启用了 Custom Bot 模式
 		if cfg.Monica.EnableCustomBotMode {
 			// 使用 Custom Bot Service 处理请求
-			result, err = customBotService.HandleCustomBotChat(ctx, &req, cfg.Monica.BotUID)
+			result, err = customBotService.HandleCustomBotChat(ctx, &req.ChatCompletionRequest, cfg.Monica.BotUID)
 		} else {
 			// 使用普通的 Chat Service 处理请求
-			result, err = chatService.HandleChatCompletion(ctx, &req)
+			result, err = chatService.HandleChatCompletion(ctx, &req.ChatCompletionRequest)
 		}
 
 		if err != nil {
@@ -71,17 +115,15 @@ func createChatCompletionHandler(chatService service.ChatService, customBotServi
 
 		// 根据请求参数决定响应方式
 		if req.Stream {
-			// 对于流式请求，result是一个io.ReadCloser
-			rawBody, ok := result.(io.Reader)
+			// 对于流式请求，result是一个*monica.CompletionStream
+			stream, ok := result.(*monica.CompletionStream)
 			if !ok {
 				return errors.NewInternalError(nil)
 			}
 
 			// 确保关闭响应体
-			closer, isCloser := rawBody.(io.Closer)
-			if isCloser {
-				defer closer.Close()
-			}
+			defer stream.Close()
+			stream.SetReasoningMode(mode)
 
 			// 设置响应头
 			c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
@@ -90,14 +132,246 @@ func createChatCompletionHandler(chatService service.ChatService, customBotServi
 			c.Response().WriteHeader(http.StatusOK)
 
 			// 流式处理响应
-			if err := monica.StreamMonicaSSEToClient(req.Model, c.Response().Writer, rawBody); err != nil {
+			if err := monica.StreamMonicaSSEToClient(ctx, c.Response().Writer, stream); err != nil {
 				return errors.NewInternalError(err)
 			}
 			return nil
 		} else {
 			// 对于非流式请求，直接返回JSON响应
+			if resp, ok := result.(*types.ChatCompletionResponse); ok {
+				monica.ApplyReasoningMode(resp, mode)
+			}
+			return c.JSON(http.StatusOK, result)
+		}
+	}
+}
+
+// reasoningMode 确定思考过程的输出方式，请求中的 reasoning_mode 优先于配置
+func reasoningMode(cfg *config.Config, req *types.ChatCompletionRequest) (string, error) {
+	if req.ReasoningMode == "" {
+		return cfg.Reasoning.Mode, nil
+	}
+	if !slices.Contains(config.ReasoningModes, req.ReasoningMode) {
+		return "", errors.NewBadRequestError(
+			fmt.Sprintf("reasoning_mode必须是以下之一: %s", strings.Join(config.ReasoningModes, ", ")), nil)
+	}
+	return req.ReasoningMode, nil
+}
+
+// createMessagesHandler 创建 Anthropic Messages 处理器
+func createMessagesHandler(messagesService service.MessagesService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req types.AnthropicMessagesRequest
+		if err := c.Bind(&req); err != nil {
+			return errors.NewBadRequestError("无效的请求数据", err)
+		}
+
+		ctx, model := types.WithWebSearch(c.Request().Context(), req.Model, nil)
+		req.Model = model
+		ctx = types.WithSessionKey(ctx, c.Request().Header.Get(types.SessionKeyHeader))
+		result, err := messagesService.HandleMessages(ctx, &req)
+		if err != nil {
+			return err
+		}
+
+		if !req.Stream {
+			return c.JSON(http.StatusOK, result)
+		}
+
+		stream, ok := result.(*monica.CompletionStream)
+		if !ok {
+			return errors.NewInternalError(fmt.Errorf("流式响应类型错误"))
+		}
+		defer stream.Close()
+
+		c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+		c.Response().Header().Set("Cache-Control", "no-cache")
+		c.Response().Header().Set("Connection", "keep-alive")
+		c.Response().WriteHeader(http.StatusOK)
+
+		if err := monica.StreamMonicaSSEToAnthropic(ctx, c.Response().Writer, stream, req.Thinking.Enabled()); err != nil {
+			logger.Error("流式响应写入失败", zap.Error(err))
+			return err
+		}
+		return nil
+	}
+}
+
+// createResponsesHandler 创建 Responses 处理器
+func createResponsesHandler(responsesService service.ResponsesService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req types.ResponsesRequest
+		if err := c.Bind(&req); err != nil {
+			return errors.NewBadRequestError("无效的请求数据", err)
+		}
+
+		// 内置的 web_search 工具同样开启联网搜索
+		ctx, model := types.WithWebSearch(c.Request().Context(), req.Model, types.ResponsesWebSearch(req.Tools))
+		req.Model = model
+		ctx = types.WithSessionKey(ctx, c.Request().Header.Get(types.SessionKeyHeader))
+		result, err := responsesService.CreateResponse(ctx, &req)
+		if err != nil {
+			return err
+		}
+
+		if !req.Stream {
 			return c.JSON(http.StatusOK, result)
 		}
+
+		stream, ok := result.(*service.ResponseStream)
+		if !ok {
+			return errors.NewInternalError(fmt.Errorf("流式响应类型错误"))
+		}
+		defer stream.Close()
+
+		c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+		c.Response().Header().Set("Cache-Control", "no-cache")
+		c.Response().Header().Set("Connection", "keep-alive")
+		c.Response().WriteHeader(http.StatusOK)
+
+		if err := stream.Stream(ctx, c.Response().Writer); err != nil {
+			logger.Error("流式响应写入失败", zap.Error(err))
+			return err
+		}
+		return nil
+	}
+}
+
+// createGetResponseHandler 创建获取响应处理器
+func createGetResponseHandler(responsesService service.ResponsesService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		resp, err := responsesService.GetResponse(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// createDeleteResponseHandler 创建删除响应处理器
+func createDeleteResponseHandler(responsesService service.ResponsesService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		resp, err := responsesService.DeleteResponse(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// createUploadFileHandler 创建文件上传处理器
+func createUploadFileHandler(fileService service.FileService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return errors.NewBadRequestError("缺少 file 字段", err)
+		}
+		resp, err := fileService.UploadFile(c.Request().Context(), file, c.FormValue("purpose"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// createListFilesHandler 创建文件列表处理器
+func createListFilesHandler(fileService service.FileService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, fileService.ListFiles(c.QueryParam("purpose")))
+	}
+}
+
+// createGetFileHandler 创建获取文件处理器
+func createGetFileHandler(fileService service.FileService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		resp, err := fileService.GetFile(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// createDeleteFileHandler 创建删除文件处理器
+func createDeleteFileHandler(fileService service.FileService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		resp, err := fileService.DeleteFile(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// createFileContentHandler 创建文件内容下载处理器
+func createFileContentHandler(fileService service.FileService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, contentType, err := fileService.GetFileContent(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, contentType, data)
+	}
+}
+
+// createCacheStatsHandler 创建上传缓存统计处理器
+func createCacheStatsHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]any{
+			"image_cache": types.ImageCacheStats(),
+		})
+	}
+}
+
+// createAccountStatsHandler 创建账号池统计处理器
+func createAccountStatsHandler(cfg *config.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]any{
+			"strategy": cfg.AccountPool.Strategy,
+			"accounts": account.AccountStats(),
+		})
+	}
+}
+
+// createAccountCheckHandler 创建账号健康检查处理器，立即检查一次并返回检查后的状态
+func createAccountCheckHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := c.Param("name")
+		stats, ok := account.Check(c.Request().Context(), name)
+		if !ok {
+			return errors.NewNotFoundError(fmt.Sprintf("账号不存在: %s", name))
+		}
+		return c.JSON(http.StatusOK, stats)
+	}
+}
+
+// createAccountUpdateHandler 创建账号 Cookie 替换处理器，账号不存在时添加新账号
+func createAccountUpdateHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req struct {
+			Cookie string `json:"cookie"`
+			Weight int    `json:"weight"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return errors.NewBadRequestError("无效的请求数据", err)
+		}
+		if req.Cookie == "" {
+			return errors.NewInvalidInputError("cookie 不能为空", nil)
+		}
+		if req.Weight < 0 {
+			return errors.NewInvalidInputError("weight 不能为负数", nil)
+		}
+
+		stats, created := account.Upsert(c.Request().Context(), config.AccountConfig{
+			Name:   c.Param("name"),
+			Cookie: req.Cookie,
+			Weight: req.Weight,
+		})
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		return c.JSON(status, stats)
 	}
 }
 
@@ -128,7 +402,7 @@ func createListModelsHandler(modelService service.ModelService) echo.HandlerFunc
 }
 
 // createImageGenerationHandler 创建图片生成处理器
-func createImageGenerationHandler(imageService service.ImageService) echo.HandlerFunc {
+func createImageGenerationHandler(imageService service.ImageService, cfg *config.Config) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		// 解析请求
 		var req types.ImageGenerationRequest
@@ -136,6 +410,15 @@ func createImageGenerationHandler(imageService service.ImageService) echo.Handle
 			return errors.NewBadRequestError("无效的请求数据", err)
 		}
 
+		// 异步模式立即返回任务
+		if req.Async {
+			job, err := imageService.CreateImageJob(c.Request().Context(), &req)
+			if err != nil {
+				return err
+			}
+			return c.JSON(http.StatusAccepted, job)
+		}
+
 		// 调用服务生成图片
 		resp, err := imageService.GenerateImage(c.Request().Context(), &req)
 		if err != nil {
@@ -143,7 +426,158 @@ func createImageGenerationHandler(imageService service.ImageService) echo.Handle
 		}
 
 		// 返回结果
-		return c.JSON(http.StatusOK, resp)
+		return c.JSON(http.StatusOK, absoluteImageURLs(c, cfg, resp))
+	}
+}
+
+// createImageEditHandler 创建图像编辑处理器，variation 为 true 时生成相似图像
+func createImageEditHandler(imageService service.ImageService, cfg *config.Config, variation bool) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := &types.ImageEditRequest{
+			Model:          c.FormValue("model"),
+			Prompt:         c.FormValue("prompt"),
+			Size:           c.FormValue("size"),
+			ResponseFormat: c.FormValue("response_format"),
+			User:           c.FormValue("user"),
+		}
+		if n := c.FormValue("n"); n != "" {
+			var err error
+			if req.N, err = strconv.Atoi(n); err != nil {
+				return errors.NewBadRequestError("无效的 n", err)
+			}
+		}
+
+		// gpt-image-1 的客户端使用 image[] 字段，只取第一张图片
+		image, err := readFormImage(c, "image", "image[]")
+		if err != nil {
+			return err
+		}
+		if image == nil {
+			return errors.NewBadRequestError("缺少 image 字段", nil)
+		}
+		req.Image = image
+		if !variation {
+			if req.Mask, err = readFormImage(c, "mask"); err != nil {
+				return err
+			}
+		}
+
+		var resp *types.ImageGenerationResponse
+		if variation {
+			resp, err = imageService.CreateImageVariation(c.Request().Context(), req)
+		} else {
+			resp, err = imageService.EditImage(c.Request().Context(), req)
+		}
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, absoluteImageURLs(c, cfg, resp))
+	}
+}
+
+// readFormImage 读取 multipart 中第一个存在的图片字段，都不存在时返回 nil
+func readFormImage(c echo.Context, fields ...string) ([]byte, error) {
+	for _, field := range fields {
+		file, err := c.FormFile(field)
+		if err != nil {
+			continue
+		}
+		if file.Size > types.MaxImageSize {
+			return nil, errors.NewInvalidInputError(fmt.Sprintf("%s 过大", field), fmt.Errorf("file size exceeds limit: %d > %d", file.Size, types.MaxImageSize))
+		}
+		src, err := file.Open()
+		if err != nil {
+			return nil, errors.NewBadRequestError("读取上传文件失败", err)
+		}
+		defer src.Close()
+		data, err := io.ReadAll(src)
+		if err != nil {
+			return nil, errors.NewBadRequestError("读取上传文件失败", err)
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+// createImageJobHandler 查询异步图像生成任务
+func createImageJobHandler(imageService service.ImageService, cfg *config.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		job, err := imageService.GetImageJob(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		job.Result = absoluteImageURLs(c, cfg, job.Result)
+		return c.JSON(http.StatusOK, job)
+	}
+}
+
+// createImageJobEventsHandler 以 SSE 推送异步任务的进度，任务结束后发送 completed 或 failed 事件并断开
+func createImageJobEventsHandler(imageService service.ImageService, cfg *config.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		// 任务不存在时返回普通的错误响应
+		if _, err := imageService.GetImageJob(ctx, c.Param("id")); err != nil {
+			return err
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
+		c.Response().Header().Set("Cache-Control", "no-cache")
+		c.Response().WriteHeader(http.StatusOK)
+
+		err := imageService.WatchImageJob(ctx, c.Param("id"), func(job *types.ImageJob) error {
+			event := "progress"
+			switch job.Status {
+			case types.ImageJobSucceeded:
+				event = "completed"
+			case types.ImageJobFailed:
+				event = "failed"
+			}
+			job.Result = absoluteImageURLs(c, cfg, job.Result)
+			data, err := json.Marshal(job)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(c.Response(), "event: %s\ndata: %s\n\n", event, data); err != nil {
+				return err
+			}
+			c.Response().Flush()
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			logger.Warn("推送图像生成任务进度失败", zap.Error(err))
+		}
+		return nil
+	}
+}
+
+// absoluteImageURLs 本地保存的图片只有路径，补全为完整链接；返回副本，不修改服务中保存的结果
+func absoluteImageURLs(c echo.Context, cfg *config.Config, resp *types.ImageGenerationResponse) *types.ImageGenerationResponse {
+	if resp == nil {
+		return nil
+	}
+	baseURL := strings.TrimSuffix(cfg.ImageStore.PublicURL, "/")
+	if baseURL == "" {
+		baseURL = c.Scheme() + "://" + c.Request().Host
+	}
+	result := *resp
+	result.Data = slices.Clone(resp.Data)
+	for i := range result.Data {
+		if strings.HasPrefix(result.Data[i].URL, service.ImageFilePath) {
+			result.Data[i].URL = baseURL + result.Data[i].URL
+		}
+	}
+	return &result
+}
+
+// createImageFileHandler 返回本地保存的生成图片
+func createImageFileHandler(imageService service.ImageService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data, mimeType, err := imageService.GetImageFile(c.Request().Context(), c.Param("id"), c.QueryParam("expires"), c.QueryParam("signature"))
+		if err != nil {
+			return err
+		}
+		c.Response().Header().Set("Cache-Control", "private, max-age=3600")
+		return c.Blob(http.StatusOK, mimeType, data)
 	}
 }
 
@@ -160,13 +594,19 @@ func createCustomBotHandler(service service.CustomBotService, cfg *config.Config
 			}
 		}
 
-		var req openai.ChatCompletionRequest
+		var req types.ChatCompletionRequest
 		if err := c.Bind(&req); err != nil {
 			return errors.NewBadRequestError("请求体解析失败", err)
 		}
+		mode, err := reasoningMode(cfg, &req)
+		if err != nil {
+			return err
+		}
 
-		ctx := c.Request().Context()
-		result, err := service.HandleCustomBotChat(ctx, &req, botUID)
+		ctx, model := types.WithWebSearch(c.Request().Context(), req.Model, req.WebSearch)
+		req.Model = model
+		ctx = types.WithSessionKey(ctx, c.Request().Header.Get(types.SessionKeyHeader))
+		result, err := service.HandleCustomBotChat(ctx, &req.ChatCompletionRequest, botUID)
 		if err != nil {
 			return err
 		}
@@ -179,15 +619,16 @@ func createCustomBotHandler(service service.CustomBotService, cfg *config.Config
 			c.Response().Header().Set("Connection", "keep-alive")
 			c.Response().Header().Set("Transfer-Encoding", "chunked")
 
-			// 获取响应体（io.ReadCloser）
-			stream, ok := result.(io.ReadCloser)
+			// 获取响应体（*monica.CompletionStream）
+			stream, ok := result.(*monica.CompletionStream)
 			if !ok {
 				return errors.NewInternalError(fmt.Errorf("流式响应类型错误"))
 			}
 			defer stream.Close()
+			stream.SetReasoningMode(mode)
 
 			// 转换并写入响应
-			err := monica.StreamMonicaSSEToClient(req.Model, c.Response().Writer, stream)
+			err := monica.StreamMonicaSSEToClient(ctx, c.Response().Writer, stream)
 			if err != nil {
 				logger.Error("流式响应写入失败", zap.Error(err))
 				return err
@@ -198,6 +639,9 @@ func createCustomBotHandler(service service.CustomBotService, cfg *config.Config
 		}
 
 		// 非流式响应
+		if resp, ok := result.(*types.ChatCompletionResponse); ok {
+			monica.ApplyReasoningMode(resp, mode)
+		}
 		return c.JSON(http.StatusOK, result)
 	}
 }
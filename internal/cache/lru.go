@@ -0,0 +1,215 @@
+// Package cache 提供带容量上限、过期时间与可选磁盘持久化的 LRU 缓存
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Options 缓存配置，MaxEntries、MaxBytes、TTL 为 0 时表示不限制
+type Options struct {
+	MaxEntries int
+	MaxBytes   int64
+	TTL        time.Duration
+	Path       string // 持久化文件路径，为空时仅保存在内存
+}
+
+// Stats 缓存统计
+type Stats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"` // 因容量上限淘汰的条目数
+	Expired   int64 `json:"expired"`   // 因过期删除的条目数
+}
+
+// entry 缓存条目，字段导出以便持久化
+type entry[V any] struct {
+	Key       string    `json:"key"`
+	Value     V         `json:"value"`
+	Size      int64     `json:"size"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LRU 并发安全的 LRU 缓存
+type LRU[V any] struct {
+	mu    sync.Mutex
+	opts  Options
+	ll    *list.List // 最近使用的条目在前
+	items map[string]*list.Element
+	bytes int64
+	stats Stats
+	dirty bool
+
+	saveMu sync.Mutex // 串行化 Save，避免并发写同一个临时文件或旧快照覆盖新快照
+}
+
+// New 创建缓存，Path 对应的文件存在时加载其中未过期的条目
+func New[V any](opts Options) (*LRU[V], error) {
+	c := &LRU[V]{
+		opts:  opts,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+	if opts.Path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(opts.Path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cache file failed: %w", err)
+	}
+	var entries []*entry[V]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse cache file failed: %w", err)
+	}
+	// 文件中按最近使用顺序保存，逆序插入以恢复原有顺序
+	now := time.Now()
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt) {
+			continue
+		}
+		c.addLocked(e)
+	}
+	return c, nil
+}
+
+// Get 获取未过期的条目，并将其标记为最近使用
+func (c *LRU[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return zero, false
+	}
+	e := el.Value.(*entry[V])
+	if !e.ExpiresAt.IsZero() && time.Now().After(e.ExpiresAt) {
+		c.removeLocked(el)
+		c.stats.Expired++
+		c.stats.Misses++
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	c.stats.Hits++
+	return e.Value, true
+}
+
+// Set 写入条目，size 计入 MaxBytes，超出上限时淘汰最久未使用的条目
+func (c *LRU[V]) Set(key string, value V, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeLocked(el)
+	}
+	e := &entry[V]{Key: key, Value: value, Size: size}
+	if c.opts.TTL > 0 {
+		e.ExpiresAt = time.Now().Add(c.opts.TTL)
+	}
+	c.addLocked(e)
+	c.dirty = true
+}
+
+// Delete 删除条目
+func (c *LRU[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeLocked(el)
+		c.dirty = true
+	}
+}
+
+// Stats 返回缓存统计
+func (c *LRU[V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.ll.Len()
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// Save 将未过期的条目按最近使用顺序写回磁盘，未配置 Path 或没有修改时直接返回
+func (c *LRU[V]) Save() error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	if c.opts.Path == "" || !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	entries := make([]*entry[V], 0, c.ll.Len())
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*entry[V])
+		if e.ExpiresAt.IsZero() || now.Before(e.ExpiresAt) {
+			entries = append(entries, e)
+		}
+	}
+	data, err := json.Marshal(entries)
+	c.dirty = false
+	c.mu.Unlock()
+
+	if err == nil {
+		err = writeFileAtomic(c.opts.Path, data)
+	}
+	if err != nil {
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+		return fmt.Errorf("save cache file failed: %w", err)
+	}
+	return nil
+}
+
+// addLocked 插入到最前并按容量上限淘汰，调用方需持有锁
+func (c *LRU[V]) addLocked(e *entry[V]) {
+	c.items[e.Key] = c.ll.PushFront(e)
+	c.bytes += e.Size
+	for c.ll.Len() > 1 && c.overLimitLocked() {
+		c.removeLocked(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+// overLimitLocked 是否超出条目数或字节数上限
+func (c *LRU[V]) overLimitLocked() bool {
+	return (c.opts.MaxEntries > 0 && c.ll.Len() > c.opts.MaxEntries) ||
+		(c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes)
+}
+
+// removeLocked 删除条目，调用方需持有锁
+func (c *LRU[V]) removeLocked(el *list.Element) {
+	e := c.ll.Remove(el).(*entry[V])
+	delete(c.items, e.Key)
+	c.bytes -= e.Size
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免写入中断时损坏已有数据
+func writeFileAtomic(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
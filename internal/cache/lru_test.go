@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLRUEviction 测试按条目数与字节数淘汰最久未使用的条目
+func TestLRUEviction(t *testing.T) {
+	c, _ := New[string](Options{MaxEntries: 2, MaxBytes: 100})
+	c.Set("a", "A", 10)
+	c.Set("b", "B", 10)
+	c.Get("a") // a 变为最近使用
+	c.Set("c", "C", 10)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b 应被淘汰")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a 不应被淘汰")
+	}
+
+	c.Set("d", "D", 95)
+	if stats := c.Stats(); stats.Entries != 1 || stats.Bytes != 95 {
+		t.Errorf("超过字节上限后的统计错误: %+v", stats)
+	}
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Evictions != 3 {
+		t.Errorf("命中统计错误: %+v", stats)
+	}
+}
+
+// TestLRUTTL 测试过期条目不会被返回
+func TestLRUTTL(t *testing.T) {
+	c, _ := New[string](Options{TTL: 10 * time.Millisecond})
+	c.Set("a", "A", 1)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Error("过期条目不应命中")
+	}
+	if stats := c.Stats(); stats.Expired != 1 || stats.Entries != 0 {
+		t.Errorf("过期统计错误: %+v", stats)
+	}
+}
+
+// TestLRUPersistence 测试保存后重新加载保留条目与使用顺序
+func TestLRUPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, _ := New[string](Options{MaxEntries: 2, Path: path})
+	c.Set("a", "A", 1)
+	c.Set("b", "B", 1)
+	c.Get("a")
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := New[string](Options{MaxEntries: 2, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.Set("c", "C", 1) // 应淘汰最久未使用的 b
+	if v, ok := reloaded.Get("a"); !ok || v != "A" {
+		t.Errorf("Get(a) = %q, %v", v, ok)
+	}
+	if _, ok := reloaded.Get("b"); ok {
+		t.Error("重新加载后使用顺序错误")
+	}
+}
+
+// TestLRUConcurrentSave 测试并发保存不会互相覆盖临时文件，最后写入的是最新的快照
+func TestLRUConcurrentSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, _ := New[int](Options{Path: path})
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Set(strconv.Itoa(i), i, 0)
+			if err := c.Save(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	reloaded, err := New[int](Options{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := reloaded.Stats(); stats.Entries != 16 {
+		t.Errorf("保存的条目数 = %d, 期望 16", stats.Entries)
+	}
+}
@@ -22,6 +22,12 @@ type Config struct {
 	// Monica API 配置
 	Monica MonicaConfig `yaml:"monica" json:"monica"`
 
+	// 多账号池配置
+	AccountPool AccountPoolConfig `yaml:"account_pool" json:"account_pool"`
+
+	// 账号健康检查配置
+	HealthCheck HealthCheckConfig `yaml:"health_check" json:"health_check"`
+
 	// 安全配置
 	Security SecurityConfig `yaml:"security" json:"security"`
 
@@ -30,6 +36,54 @@ type Config struct {
 
 	// 日志配置
 	Logging LoggingConfig `yaml:"logging" json:"logging"`
+
+	// Responses API 配置
+	Responses ResponsesConfig `yaml:"responses" json:"responses"`
+
+	// 结构化输出配置
+	StructuredOutput StructuredOutputConfig `yaml:"structured_output" json:"structured_output"`
+
+	// 多候选（n > 1）配置
+	Choices ChoicesConfig `yaml:"choices" json:"choices"`
+
+	// 思考过程输出配置
+	Reasoning ReasoningConfig `yaml:"reasoning" json:"reasoning"`
+
+	// 普通模式下的系统提示词配置
+	SystemPrompt SystemPromptConfig `yaml:"system_prompt" json:"system_prompt"`
+
+	// 远程文件下载配置
+	RemoteFetch RemoteFetchConfig `yaml:"remote_fetch" json:"remote_fetch"`
+
+	// 文档附件配置
+	Attachments AttachmentsConfig `yaml:"attachments" json:"attachments"`
+
+	// Files API 配置
+	Files FilesConfig `yaml:"files" json:"files"`
+
+	// 上传缓存配置
+	ImageCache ImageCacheConfig `yaml:"image_cache" json:"image_cache"`
+
+	// 图片上传失败处理配置
+	ImageUpload ImageUploadConfig `yaml:"image_upload" json:"image_upload"`
+
+	// 图片预处理配置
+	ImagePreprocess ImagePreprocessConfig `yaml:"image_preprocess" json:"image_preprocess"`
+
+	// 联网搜索配置
+	WebSearch WebSearchConfig `yaml:"web_search" json:"web_search"`
+
+	// 会话模式配置
+	Session SessionConfig `yaml:"session" json:"session"`
+
+	// 上下文窗口管理配置
+	Context ContextConfig `yaml:"context" json:"context"`
+
+	// 生成图片的本地存储配置
+	ImageStore ImageStoreConfig `yaml:"image_store" json:"image_store"`
+
+	// 图像生成模型配置
+	ImageModels ImageModelsConfig `yaml:"image_models" json:"image_models"`
 }
 
 // ServerConfig 服务器配置
@@ -43,14 +97,49 @@ type ServerConfig struct {
 
 // MonicaConfig Monica API 配置
 type MonicaConfig struct {
-	Cookie              string `yaml:"cookie" json:"cookie"`
-	BotUID              string `yaml:"bot_uid" json:"bot_uid"`
-	EnableCustomBotMode bool   `yaml:"enable_custom_bot_mode" json:"enable_custom_bot_mode"`
+	Cookie              string          `yaml:"cookie" json:"cookie"`
+	BotUID              string          `yaml:"bot_uid" json:"bot_uid"`
+	EnableCustomBotMode bool            `yaml:"enable_custom_bot_mode" json:"enable_custom_bot_mode"`
+	Accounts            []AccountConfig `yaml:"accounts" json:"accounts"` // 多账号，配置后忽略 Cookie
+}
+
+// AccountConfig 账号池中的一个 Monica 账号
+type AccountConfig struct {
+	Name   string `yaml:"name" json:"name"`     // 账号名，用于日志与统计，为空时按顺序命名为 account-1、account-2...
+	Cookie string `yaml:"cookie" json:"cookie"` // 账号的登录 Cookie
+	Weight int    `yaml:"weight" json:"weight"` // weighted 策略的权重，为 0 时按 1 计算
+}
+
+// 账号选择策略
+const (
+	AccountRoundRobin    = "round_robin"     // 轮流使用
+	AccountLeastInFlight = "least_in_flight" // 使用进行中请求最少的账号
+	AccountWeighted      = "weighted"        // 按权重平滑轮询
+)
+
+// AccountStrategies 支持的账号选择策略
+var AccountStrategies = []string{AccountRoundRobin, AccountLeastInFlight, AccountWeighted}
+
+// AccountPoolConfig 多账号池配置
+type AccountPoolConfig struct {
+	Strategy    string        `yaml:"strategy" json:"strategy"`         // 选择策略：round_robin, least_in_flight, weighted
+	Cooldown    time.Duration `yaml:"cooldown" json:"cooldown"`         // 账号因鉴权失败或额度耗尽被摘除后的冷却时间，之后放行一个请求试探
+	MaxCooldown time.Duration `yaml:"max_cooldown" json:"max_cooldown"` // 试探失败时冷却时间加倍的上限
+	StickyTTL   time.Duration `yaml:"sticky_ttl" json:"sticky_ttl"`     // 会话键或 user 与账号的绑定闲置多久后失效
+}
+
+// HealthCheckConfig 账号健康检查配置，定期用开销很小的上游请求校验每个账号的 Cookie
+type HealthCheckConfig struct {
+	Enabled    bool          `yaml:"enabled" json:"enabled"`
+	Interval   time.Duration `yaml:"interval" json:"interval"`       // 检查间隔
+	Timeout    time.Duration `yaml:"timeout" json:"timeout"`         // 单次检查与 webhook 请求的超时时间
+	WebhookURL string        `yaml:"webhook_url" json:"webhook_url"` // 账号状态变化时 POST 通知的地址，为空时只记录日志
 }
 
 // SecurityConfig 安全配置
 type SecurityConfig struct {
 	BearerToken      string        `yaml:"bearer_token" json:"bearer_token"`
+	AdminToken       string        `yaml:"admin_token" json:"admin_token"` // /admin/* 运维接口需要额外提供的令牌，为空时禁用这些接口
 	TLSSkipVerify    bool          `yaml:"tls_skip_verify" json:"tls_skip_verify"`
 	RateLimitEnabled bool          `yaml:"rate_limit_enabled" json:"rate_limit_enabled"`
 	RateLimitRPS     int           `yaml:"rate_limit_rps" json:"rate_limit_rps"`
@@ -77,6 +166,184 @@ type LoggingConfig struct {
 	MaskSensitive    bool   `yaml:"mask_sensitive" json:"mask_sensitive"`
 }
 
+// ResponsesConfig Responses API 本地响应存储配置
+type ResponsesConfig struct {
+	StoreTTL        time.Duration `yaml:"store_ttl" json:"store_ttl"`                 // 响应保留时间
+	StoreMaxEntries int           `yaml:"store_max_entries" json:"store_max_entries"` // 最多保留的响应数量
+}
+
+// StructuredOutputConfig response_format 结构化输出配置
+type StructuredOutputConfig struct {
+	MaxRetries int `yaml:"max_retries" json:"max_retries"` // 校验失败后重新请求上游的最大次数，也用于 tool_choice 要求调用工具的校验
+}
+
+// 多候选部分失败策略
+const (
+	PartialFailureFail    = "fail"    // 任一候选失败则整个请求失败
+	PartialFailurePartial = "partial" // 返回成功的候选
+)
+
+// ChoicesConfig n > 1 多候选配置，每个候选对应一次独立的上游请求
+type ChoicesConfig struct {
+	MaxN           int    `yaml:"max_n" json:"max_n"`                     // 单个请求允许的最大 n
+	Concurrency    int    `yaml:"concurrency" json:"concurrency"`         // 同时进行的上游请求数
+	PartialFailure string `yaml:"partial_failure" json:"partial_failure"` // 部分候选失败时的处理策略：fail, partial
+}
+
+// 思考过程输出方式
+const (
+	ReasoningModeContent = "reasoning_content" // 通过 reasoning_content 字段输出
+	ReasoningModeTags    = "tags"              // 以 <think></think> 包裹写入 content
+	ReasoningModeHidden  = "hidden"            // 不输出思考过程
+)
+
+// ReasoningModes 支持的思考过程输出方式
+var ReasoningModes = []string{ReasoningModeContent, ReasoningModeTags, ReasoningModeHidden}
+
+// ReasoningConfig 思考过程输出配置，请求中的 reasoning_mode 优先
+type ReasoningConfig struct {
+	Mode string `yaml:"mode" json:"mode"` // 默认输出方式：reasoning_content, tags, hidden
+}
+
+// 普通模式下系统提示词的处理策略
+const (
+	SystemPromptPair      = "pair"       // 作为开头的一问一答插入对话
+	SystemPromptMerge     = "merge"      // 按模板合并进第一条用户消息
+	SystemPromptCustomBot = "custom_bot" // 带系统提示词的请求改走 Custom Bot 接口
+	SystemPromptIgnore    = "ignore"     // 忽略系统提示词
+)
+
+// SystemPromptStrategies 支持的系统提示词处理策略
+var SystemPromptStrategies = []string{SystemPromptPair, SystemPromptMerge, SystemPromptCustomBot, SystemPromptIgnore}
+
+// 系统提示词模板占位符
+const (
+	SystemPromptPlaceholder = "{{system}}"
+	UserPromptPlaceholder   = "{{user}}"
+)
+
+// SystemPromptConfig 普通（非 Custom Bot）模式下 system / developer 消息的处理方式
+type SystemPromptConfig struct {
+	Strategy  string `yaml:"strategy" json:"strategy"`     // 处理策略：pair, merge, custom_bot, ignore
+	Template  string `yaml:"template" json:"template"`     // merge 策略的模板，{{system}} 与 {{user}} 分别替换为系统提示词与用户消息
+	PairReply string `yaml:"pair_reply" json:"pair_reply"` // pair 策略中模型对系统提示词的答复
+}
+
+// RemoteFetchConfig 下载消息中 http(s) 图片链接的配置
+type RemoteFetchConfig struct {
+	Enabled      bool          `yaml:"enabled" json:"enabled"`             // 是否允许下载远程图片
+	MaxSize      int64         `yaml:"max_size" json:"max_size"`           // 单个文件的最大字节数
+	Timeout      time.Duration `yaml:"timeout" json:"timeout"`             // 单次下载的超时时间（含重定向）
+	MaxRedirects int           `yaml:"max_redirects" json:"max_redirects"` // 最多跟随的重定向次数
+	AllowPrivate bool          `yaml:"allow_private" json:"allow_private"` // 是否允许访问内网、回环等私有地址
+	AllowedHosts []string      `yaml:"allowed_hosts" json:"allowed_hosts"` // 即使解析到私有地址也允许访问的主机名或 CIDR
+}
+
+// AttachmentsConfig 聊天消息中文档附件（PDF、文本、Office 等）的配置
+type AttachmentsConfig struct {
+	MaxSize      int64         `yaml:"max_size" json:"max_size"`           // 单个文档的最大字节数
+	AllowedTypes []string      `yaml:"allowed_types" json:"allowed_types"` // 允许上传的 MIME 类型
+	IndexTimeout time.Duration `yaml:"index_timeout" json:"index_timeout"` // 等待 Monica 解析文档的最长时间
+}
+
+// FilesConfig /v1/files 配置，上传后的文件元数据保存在本地
+type FilesConfig struct {
+	StorePath string `yaml:"store_path" json:"store_path"` // 元数据文件路径，为空时仅保存在内存
+	MaxSize   int64  `yaml:"max_size" json:"max_size"`     // 单个文件的最大字节数
+}
+
+// ImageCacheConfig 已上传图片与文档的缓存配置，命中时直接复用 Monica 的 file_uid
+type ImageCacheConfig struct {
+	MaxEntries  int           `yaml:"max_entries" json:"max_entries"`   // 最多缓存的文件数
+	MaxBytes    int64         `yaml:"max_bytes" json:"max_bytes"`       // 缓存文件的总字节数上限
+	TTL         time.Duration `yaml:"ttl" json:"ttl"`                   // 缓存有效期，应不超过 Monica 保留 file_uid 的时间
+	PersistPath string        `yaml:"persist_path" json:"persist_path"` // 持久化文件路径，为空时仅保存在内存
+}
+
+// 图片上传失败的处理策略
+const (
+	ImageUploadStrict  = "strict"  // 返回错误，指明失败的内容段
+	ImageUploadLenient = "lenient" // 跳过失败的图片，在消息中注明并返回警告响应头
+	ImageUploadRetry   = "retry"   // 重试失败的图片，仍失败时按 RetryFallback 处理
+)
+
+// ImageUploadPolicies 支持的图片上传失败处理策略
+var ImageUploadPolicies = []string{ImageUploadStrict, ImageUploadLenient, ImageUploadRetry}
+
+// ImageUploadConfig 消息中图片上传失败时的处理配置
+type ImageUploadConfig struct {
+	FailurePolicy string `yaml:"failure_policy" json:"failure_policy"` // 处理策略：strict, lenient, retry
+	MaxRetries    int    `yaml:"max_retries" json:"max_retries"`       // retry 策略的最大重试次数
+	RetryFallback string `yaml:"retry_fallback" json:"retry_fallback"` // 重试后仍失败时的处理：strict, lenient
+}
+
+// ImagePreprocessConfig 上传前的图片预处理配置：缩放、重新压缩、格式转换与去除元数据
+// 默认只去除元数据，不限制尺寸，超出上传大小上限或需要转换格式、摆正方向时才重新编码
+type ImagePreprocessConfig struct {
+	Enabled            bool `yaml:"enabled" json:"enabled"`                           // 是否启用预处理
+	MaxDimension       int  `yaml:"max_dimension" json:"max_dimension"`               // 长边的最大像素数，0 表示不限制
+	LowDetailDimension int  `yaml:"low_detail_dimension" json:"low_detail_dimension"` // detail=low 时长边的最大像素数
+	JPEGQuality        int  `yaml:"jpeg_quality" json:"jpeg_quality"`                 // 重新编码 JPEG 的质量（1-100）
+}
+
+// WebSearchConfig 联网搜索配置，请求中的 web_search 字段与模型名的 :online 后缀优先
+type WebSearchConfig struct {
+	Models []string `yaml:"models" json:"models"` // 默认开启联网搜索的模型
+}
+
+// SessionConfig 会话模式配置，开启后复用 Monica 会话，每次只发送新增的消息
+type SessionConfig struct {
+	Enabled     bool          `yaml:"enabled" json:"enabled"`             // 是否启用会话模式
+	TTL         time.Duration `yaml:"ttl" json:"ttl"`                     // 会话闲置多久后过期
+	MaxEntries  int           `yaml:"max_entries" json:"max_entries"`     // 最多保存的会话数
+	KeyFromUser bool          `yaml:"key_from_user" json:"key_from_user"` // 没有 X-Conversation-Key 请求头时是否以 user 字段作为会话键
+}
+
+// 上下文超出模型窗口时的处理策略
+const (
+	ContextStrategyNone      = "none"      // 不处理，原样发送
+	ContextStrategyTruncate  = "truncate"  // 丢弃最早的对话，保留系统提示词与最近的对话
+	ContextStrategySummarize = "summarize" // 用低成本模型把最早的对话概括为摘要
+)
+
+// ContextStrategies 支持的上下文处理策略
+var ContextStrategies = []string{ContextStrategyNone, ContextStrategyTruncate, ContextStrategySummarize}
+
+// ContextConfig 上下文窗口管理配置
+type ContextConfig struct {
+	Strategy      string         `yaml:"strategy" json:"strategy"`             // 处理策略：none, truncate, summarize
+	DefaultLimit  int            `yaml:"default_limit" json:"default_limit"`   // 未知模型的上下文窗口 token 数
+	ModelLimits   map[string]int `yaml:"model_limits" json:"model_limits"`     // 覆盖内置的模型上下文窗口
+	ReserveTokens int            `yaml:"reserve_tokens" json:"reserve_tokens"` // 请求未指定 max_tokens 时为回复预留的 token 数
+	SummaryModel  string         `yaml:"summary_model" json:"summary_model"`   // summarize 策略使用的模型
+}
+
+// ImageStoreConfig 生成图片的本地存储配置，开启后图片保存在本地并通过带签名的链接从代理访问
+type ImageStoreConfig struct {
+	Enabled    bool          `yaml:"enabled" json:"enabled"`         // 是否启用本地存储
+	Dir        string        `yaml:"dir" json:"dir"`                 // 图片保存目录
+	Retention  time.Duration `yaml:"retention" json:"retention"`     // 图片保留时长
+	MaxBytes   int64         `yaml:"max_bytes" json:"max_bytes"`     // 图片总字节数上限，超出时删除最早的图片
+	URLTTL     time.Duration `yaml:"url_ttl" json:"url_ttl"`         // 签名链接的有效期
+	SigningKey string        `yaml:"signing_key" json:"signing_key"` // 链接签名密钥，为空时启动时随机生成，重启后旧链接失效
+	PublicURL  string        `yaml:"public_url" json:"public_url"`   // 对外访问的地址，如 https://proxy.example.com，为空时使用请求的地址
+}
+
+// ImageModelsConfig 图像生成模型配置，Models 中的条目覆盖或扩展内置的模型表
+type ImageModelsConfig struct {
+	Default string                      `yaml:"default" json:"default"` // 请求未指定 model 时使用的模型
+	Models  map[string]ImageModelConfig `yaml:"models" json:"models"`   // OpenAI 风格的模型名到 Monica 模型的映射
+}
+
+// ImageModelConfig 单个图像生成模型
+type ImageModelConfig struct {
+	ModelType    string   `yaml:"model_type" json:"model_type"`       // Monica 的 model_type
+	AspectRatios []string `yaml:"aspect_ratios" json:"aspect_ratios"` // 支持的宽高比，如 1:1, 16:9，第一个为默认值
+	MaxImages    int      `yaml:"max_images" json:"max_images"`       // 单次请求最多生成的图片数
+	Qualities    []string `yaml:"qualities" json:"qualities"`         // 支持的 quality，为空时不接受该参数
+	Styles       []string `yaml:"styles" json:"styles"`               // 支持的 style，为空时不接受该参数
+}
+
 // Load 加载配置，优先级：配置文件 > 环境变量 > 默认值
 func Load() (*Config, error) {
 	// 1. 设置默认配置
@@ -117,6 +384,17 @@ func getDefaultConfig() *Config {
 			BotUID:              "",
 			EnableCustomBotMode: false,
 		},
+		AccountPool: AccountPoolConfig{
+			Strategy:    AccountRoundRobin,
+			Cooldown:    time.Minute,
+			MaxCooldown: 30 * time.Minute,
+			StickyTTL:   24 * time.Hour,
+		},
+		HealthCheck: HealthCheckConfig{
+			Enabled:  true,
+			Interval: 5 * time.Minute,
+			Timeout:  10 * time.Second,
+		},
 		Security: SecurityConfig{
 			TLSSkipVerify:    true,
 			RateLimitEnabled: false, // 默认禁用，需要明确配置
@@ -139,6 +417,85 @@ func getDefaultConfig() *Config {
 			EnableRequestLog: true,
 			MaskSensitive:    true,
 		},
+		Responses: ResponsesConfig{
+			StoreTTL:        24 * time.Hour,
+			StoreMaxEntries: 10000,
+		},
+		StructuredOutput: StructuredOutputConfig{
+			MaxRetries: 2,
+		},
+		Choices: ChoicesConfig{
+			MaxN:           8,
+			Concurrency:    4,
+			PartialFailure: PartialFailureFail,
+		},
+		Reasoning: ReasoningConfig{
+			Mode: ReasoningModeTags,
+		},
+		RemoteFetch: RemoteFetchConfig{
+			Enabled:      true,
+			MaxSize:      10 * 1024 * 1024,
+			Timeout:      15 * time.Second,
+			MaxRedirects: 3,
+			AllowPrivate: false,
+		},
+		Attachments: AttachmentsConfig{
+			MaxSize: 20 * 1024 * 1024,
+			AllowedTypes: []string{
+				"application/pdf",
+				"text/plain",
+				"text/markdown",
+				"text/csv",
+				"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			},
+			IndexTimeout: 60 * time.Second,
+		},
+		Files: FilesConfig{
+			StorePath: "data/files.json",
+			MaxSize:   20 * 1024 * 1024,
+		},
+		ImageCache: ImageCacheConfig{
+			MaxEntries: 10000,
+			MaxBytes:   2 * 1024 * 1024 * 1024,
+			TTL:        24 * time.Hour,
+		},
+		ImageUpload: ImageUploadConfig{
+			FailurePolicy: ImageUploadLenient,
+			MaxRetries:    2,
+			RetryFallback: ImageUploadLenient,
+		},
+		ImagePreprocess: ImagePreprocessConfig{
+			Enabled:            true,
+			MaxDimension:       0,
+			LowDetailDimension: 512,
+			JPEGQuality:        85,
+		},
+		Session: SessionConfig{
+			Enabled:    false,
+			TTL:        time.Hour,
+			MaxEntries: 10000,
+		},
+		Context: ContextConfig{
+			Strategy:      ContextStrategyNone,
+			DefaultLimit:  128000,
+			ReserveTokens: 4096,
+			SummaryModel:  "gpt-4.1-nano",
+		},
+		ImageModels: ImageModelsConfig{
+			Default: "dall-e-3",
+		},
+		ImageStore: ImageStoreConfig{
+			Enabled:   false,
+			Dir:       "data/images",
+			Retention: 24 * time.Hour,
+			MaxBytes:  1024 * 1024 * 1024,
+			URLTTL:    time.Hour,
+		},
+		SystemPrompt: SystemPromptConfig{
+			Strategy:  SystemPromptIgnore,
+			Template:  "<system_instructions>\n{{system}}\n</system_instructions>\n\n{{user}}",
+			PairReply: "Understood. I will follow these instructions throughout our conversation.",
+		},
 	}
 }
 
@@ -218,6 +575,18 @@ func overrideWithEnv(config *Config) {
 	if cookie := os.Getenv("MONICA_COOKIE"); cookie != "" {
 		config.Monica.Cookie = cookie
 	}
+	// 多账号：MONICA_COOKIE_1、MONICA_COOKIE_2...，遇到第一个未设置的序号为止
+	var accounts []AccountConfig
+	for i := 1; ; i++ {
+		cookie := os.Getenv(fmt.Sprintf("MONICA_COOKIE_%d", i))
+		if cookie == "" {
+			break
+		}
+		accounts = append(accounts, AccountConfig{Cookie: cookie})
+	}
+	if len(accounts) > 0 {
+		config.Monica.Accounts = accounts
+	}
 	if botUID := os.Getenv("BOT_UID"); botUID != "" {
 		config.Monica.BotUID = botUID
 	}
@@ -227,10 +596,53 @@ func overrideWithEnv(config *Config) {
 		}
 	}
 
+	// 多账号池配置
+	if strategy := os.Getenv("ACCOUNT_STRATEGY"); strategy != "" {
+		config.AccountPool.Strategy = strategy
+	}
+	if cooldown := os.Getenv("ACCOUNT_COOLDOWN"); cooldown != "" {
+		if t, err := time.ParseDuration(cooldown); err == nil {
+			config.AccountPool.Cooldown = t
+		}
+	}
+	if cooldown := os.Getenv("ACCOUNT_MAX_COOLDOWN"); cooldown != "" {
+		if t, err := time.ParseDuration(cooldown); err == nil {
+			config.AccountPool.MaxCooldown = t
+		}
+	}
+	if ttl := os.Getenv("ACCOUNT_STICKY_TTL"); ttl != "" {
+		if t, err := time.ParseDuration(ttl); err == nil {
+			config.AccountPool.StickyTTL = t
+		}
+	}
+
+	// 账号健康检查配置
+	if enabled := os.Getenv("HEALTH_CHECK_ENABLED"); enabled != "" {
+		if e, err := strconv.ParseBool(enabled); err == nil {
+			config.HealthCheck.Enabled = e
+		}
+	}
+	if interval := os.Getenv("HEALTH_CHECK_INTERVAL"); interval != "" {
+		if t, err := time.ParseDuration(interval); err == nil {
+			config.HealthCheck.Interval = t
+		}
+	}
+	if timeout := os.Getenv("HEALTH_CHECK_TIMEOUT"); timeout != "" {
+		if t, err := time.ParseDuration(timeout); err == nil {
+			config.HealthCheck.Timeout = t
+		}
+	}
+	if webhook := os.Getenv("HEALTH_CHECK_WEBHOOK_URL"); webhook != "" {
+		config.HealthCheck.WebhookURL = webhook
+	}
+
 	// 安全配置
 	if token := os.Getenv("BEARER_TOKEN"); token != "" {
 		config.Security.BearerToken = token
 	}
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		config.Security.AdminToken = token
+	}
 	if skipVerify := os.Getenv("TLS_SKIP_VERIFY"); skipVerify != "" {
 		if skip, err := strconv.ParseBool(skipVerify); err == nil {
 			config.Security.TLSSkipVerify = skip
@@ -254,6 +666,266 @@ func overrideWithEnv(config *Config) {
 	if format := os.Getenv("LOG_FORMAT"); format != "" {
 		config.Logging.Format = format
 	}
+
+	// Responses API 配置
+	if ttl := os.Getenv("RESPONSES_STORE_TTL"); ttl != "" {
+		if t, err := time.ParseDuration(ttl); err == nil {
+			config.Responses.StoreTTL = t
+		}
+	}
+	if maxEntries := os.Getenv("RESPONSES_STORE_MAX_ENTRIES"); maxEntries != "" {
+		if n, err := strconv.Atoi(maxEntries); err == nil {
+			config.Responses.StoreMaxEntries = n
+		}
+	}
+
+	// 结构化输出配置
+	if retries := os.Getenv("STRUCTURED_OUTPUT_MAX_RETRIES"); retries != "" {
+		if n, err := strconv.Atoi(retries); err == nil {
+			config.StructuredOutput.MaxRetries = n
+		}
+	}
+
+	// 多候选配置
+	if maxN := os.Getenv("CHOICES_MAX_N"); maxN != "" {
+		if n, err := strconv.Atoi(maxN); err == nil {
+			config.Choices.MaxN = n
+		}
+	}
+	if concurrency := os.Getenv("CHOICES_CONCURRENCY"); concurrency != "" {
+		if n, err := strconv.Atoi(concurrency); err == nil {
+			config.Choices.Concurrency = n
+		}
+	}
+	if policy := os.Getenv("CHOICES_PARTIAL_FAILURE"); policy != "" {
+		config.Choices.PartialFailure = policy
+	}
+
+	// 思考过程输出配置
+	if mode := os.Getenv("REASONING_MODE"); mode != "" {
+		config.Reasoning.Mode = mode
+	}
+
+	// 系统提示词配置
+	if strategy := os.Getenv("SYSTEM_PROMPT_STRATEGY"); strategy != "" {
+		config.SystemPrompt.Strategy = strategy
+	}
+	if template := os.Getenv("SYSTEM_PROMPT_TEMPLATE"); template != "" {
+		config.SystemPrompt.Template = template
+	}
+
+	// 远程文件下载配置
+	if enabled := os.Getenv("REMOTE_FETCH_ENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			config.RemoteFetch.Enabled = b
+		}
+	}
+	if maxSize := os.Getenv("REMOTE_FETCH_MAX_SIZE"); maxSize != "" {
+		if n, err := strconv.ParseInt(maxSize, 10, 64); err == nil {
+			config.RemoteFetch.MaxSize = n
+		}
+	}
+	if timeout := os.Getenv("REMOTE_FETCH_TIMEOUT"); timeout != "" {
+		if t, err := time.ParseDuration(timeout); err == nil {
+			config.RemoteFetch.Timeout = t
+		}
+	}
+	if redirects := os.Getenv("REMOTE_FETCH_MAX_REDIRECTS"); redirects != "" {
+		if n, err := strconv.Atoi(redirects); err == nil {
+			config.RemoteFetch.MaxRedirects = n
+		}
+	}
+	if allowPrivate := os.Getenv("REMOTE_FETCH_ALLOW_PRIVATE"); allowPrivate != "" {
+		if b, err := strconv.ParseBool(allowPrivate); err == nil {
+			config.RemoteFetch.AllowPrivate = b
+		}
+	}
+	if hosts := os.Getenv("REMOTE_FETCH_ALLOWED_HOSTS"); hosts != "" {
+		config.RemoteFetch.AllowedHosts = splitList(hosts)
+	}
+
+	// 文档附件配置
+	if maxSize := os.Getenv("ATTACHMENTS_MAX_SIZE"); maxSize != "" {
+		if n, err := strconv.ParseInt(maxSize, 10, 64); err == nil {
+			config.Attachments.MaxSize = n
+		}
+	}
+	if types := os.Getenv("ATTACHMENTS_ALLOWED_TYPES"); types != "" {
+		config.Attachments.AllowedTypes = splitList(types)
+	}
+	if timeout := os.Getenv("ATTACHMENTS_INDEX_TIMEOUT"); timeout != "" {
+		if t, err := time.ParseDuration(timeout); err == nil {
+			config.Attachments.IndexTimeout = t
+		}
+	}
+
+	// Files API 配置
+	// 允许显式设置为空，仅在内存中保存文件元数据
+	if path, ok := os.LookupEnv("FILES_STORE_PATH"); ok {
+		config.Files.StorePath = path
+	}
+	if maxSize := os.Getenv("FILES_MAX_SIZE"); maxSize != "" {
+		if n, err := strconv.ParseInt(maxSize, 10, 64); err == nil {
+			config.Files.MaxSize = n
+		}
+	}
+
+	// 上传缓存配置
+	if maxEntries := os.Getenv("IMAGE_CACHE_MAX_ENTRIES"); maxEntries != "" {
+		if n, err := strconv.Atoi(maxEntries); err == nil {
+			config.ImageCache.MaxEntries = n
+		}
+	}
+	if maxBytes := os.Getenv("IMAGE_CACHE_MAX_BYTES"); maxBytes != "" {
+		if n, err := strconv.ParseInt(maxBytes, 10, 64); err == nil {
+			config.ImageCache.MaxBytes = n
+		}
+	}
+	if ttl := os.Getenv("IMAGE_CACHE_TTL"); ttl != "" {
+		if t, err := time.ParseDuration(ttl); err == nil {
+			config.ImageCache.TTL = t
+		}
+	}
+	if path := os.Getenv("IMAGE_CACHE_PERSIST_PATH"); path != "" {
+		config.ImageCache.PersistPath = path
+	}
+
+	// 图片上传失败处理配置
+	if policy := os.Getenv("IMAGE_UPLOAD_FAILURE_POLICY"); policy != "" {
+		config.ImageUpload.FailurePolicy = policy
+	}
+	if retries := os.Getenv("IMAGE_UPLOAD_MAX_RETRIES"); retries != "" {
+		if n, err := strconv.Atoi(retries); err == nil {
+			config.ImageUpload.MaxRetries = n
+		}
+	}
+	if fallback := os.Getenv("IMAGE_UPLOAD_RETRY_FALLBACK"); fallback != "" {
+		config.ImageUpload.RetryFallback = fallback
+	}
+
+	// 图片预处理配置
+	if enabled := os.Getenv("IMAGE_PREPROCESS_ENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			config.ImagePreprocess.Enabled = b
+		}
+	}
+	if dimension := os.Getenv("IMAGE_PREPROCESS_MAX_DIMENSION"); dimension != "" {
+		if n, err := strconv.Atoi(dimension); err == nil {
+			config.ImagePreprocess.MaxDimension = n
+		}
+	}
+	if dimension := os.Getenv("IMAGE_PREPROCESS_LOW_DETAIL_DIMENSION"); dimension != "" {
+		if n, err := strconv.Atoi(dimension); err == nil {
+			config.ImagePreprocess.LowDetailDimension = n
+		}
+	}
+	if quality := os.Getenv("IMAGE_PREPROCESS_JPEG_QUALITY"); quality != "" {
+		if n, err := strconv.Atoi(quality); err == nil {
+			config.ImagePreprocess.JPEGQuality = n
+		}
+	}
+
+	// 联网搜索配置
+	if models := os.Getenv("WEB_SEARCH_MODELS"); models != "" {
+		config.WebSearch.Models = splitList(models)
+	}
+
+	// 会话模式配置
+	if enabled := os.Getenv("SESSION_ENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			config.Session.Enabled = b
+		}
+	}
+	if ttl := os.Getenv("SESSION_TTL"); ttl != "" {
+		if t, err := time.ParseDuration(ttl); err == nil {
+			config.Session.TTL = t
+		}
+	}
+	if maxEntries := os.Getenv("SESSION_MAX_ENTRIES"); maxEntries != "" {
+		if n, err := strconv.Atoi(maxEntries); err == nil {
+			config.Session.MaxEntries = n
+		}
+	}
+	if keyFromUser := os.Getenv("SESSION_KEY_FROM_USER"); keyFromUser != "" {
+		if b, err := strconv.ParseBool(keyFromUser); err == nil {
+			config.Session.KeyFromUser = b
+		}
+	}
+
+	// 上下文窗口管理配置
+	if strategy := os.Getenv("CONTEXT_STRATEGY"); strategy != "" {
+		config.Context.Strategy = strategy
+	}
+	if limit := os.Getenv("CONTEXT_DEFAULT_LIMIT"); limit != "" {
+		if n, err := strconv.Atoi(limit); err == nil {
+			config.Context.DefaultLimit = n
+		}
+	}
+	if limits := os.Getenv("CONTEXT_MODEL_LIMITS"); limits != "" {
+		// 格式：model=tokens，逗号分隔
+		config.Context.ModelLimits = make(map[string]int)
+		for _, item := range splitList(limits) {
+			model, limit, ok := strings.Cut(item, "=")
+			if n, err := strconv.Atoi(strings.TrimSpace(limit)); ok && err == nil {
+				config.Context.ModelLimits[strings.TrimSpace(model)] = n
+			}
+		}
+	}
+	if reserve := os.Getenv("CONTEXT_RESERVE_TOKENS"); reserve != "" {
+		if n, err := strconv.Atoi(reserve); err == nil {
+			config.Context.ReserveTokens = n
+		}
+	}
+	if model := os.Getenv("CONTEXT_SUMMARY_MODEL"); model != "" {
+		config.Context.SummaryModel = model
+	}
+
+	// 生成图片的本地存储配置
+	if enabled := os.Getenv("IMAGE_STORE_ENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			config.ImageStore.Enabled = b
+		}
+	}
+	if dir := os.Getenv("IMAGE_STORE_DIR"); dir != "" {
+		config.ImageStore.Dir = dir
+	}
+	if retention := os.Getenv("IMAGE_STORE_RETENTION"); retention != "" {
+		if t, err := time.ParseDuration(retention); err == nil {
+			config.ImageStore.Retention = t
+		}
+	}
+	if maxBytes := os.Getenv("IMAGE_STORE_MAX_BYTES"); maxBytes != "" {
+		if n, err := strconv.ParseInt(maxBytes, 10, 64); err == nil {
+			config.ImageStore.MaxBytes = n
+		}
+	}
+	if ttl := os.Getenv("IMAGE_STORE_URL_TTL"); ttl != "" {
+		if t, err := time.ParseDuration(ttl); err == nil {
+			config.ImageStore.URLTTL = t
+		}
+	}
+	if key := os.Getenv("IMAGE_STORE_SIGNING_KEY"); key != "" {
+		config.ImageStore.SigningKey = key
+	}
+	if url := os.Getenv("IMAGE_STORE_PUBLIC_URL"); url != "" {
+		config.ImageStore.PublicURL = url
+	}
+
+	// 图像生成模型配置
+	if model := os.Getenv("IMAGE_DEFAULT_MODEL"); model != "" {
+		config.ImageModels.Default = model
+	}
+}
+
+// splitList 解析逗号分隔的列表，忽略空白项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // Validate 验证配置
@@ -261,9 +933,35 @@ func (c *Config) Validate() error {
 	var errors []string
 
 	// 验证必要配置
-	if c.Monica.Cookie == "" {
+	if c.Monica.Cookie == "" && len(c.Monica.Accounts) == 0 {
 		errors = append(errors, "MONICA_COOKIE is required")
 	}
+	for i, account := range c.Monica.Accounts {
+		if account.Cookie == "" {
+			errors = append(errors, fmt.Sprintf("monica.accounts[%d].cookie is required", i))
+		}
+		if account.Weight < 0 {
+			errors = append(errors, fmt.Sprintf("monica.accounts[%d].weight must not be negative", i))
+		}
+	}
+	if !contains(AccountStrategies, c.AccountPool.Strategy) {
+		errors = append(errors, fmt.Sprintf("ACCOUNT_STRATEGY must be one of: %s", strings.Join(AccountStrategies, ", ")))
+	}
+	if c.AccountPool.Cooldown <= 0 || c.AccountPool.MaxCooldown < c.AccountPool.Cooldown {
+		errors = append(errors, "ACCOUNT_COOLDOWN must be positive and not greater than ACCOUNT_MAX_COOLDOWN")
+	}
+	if c.AccountPool.StickyTTL <= 0 {
+		errors = append(errors, "ACCOUNT_STICKY_TTL must be positive")
+	}
+	if c.HealthCheck.Enabled && c.HealthCheck.Interval <= 0 {
+		errors = append(errors, "HEALTH_CHECK_INTERVAL must be positive when health check is enabled")
+	}
+	if c.HealthCheck.Timeout <= 0 {
+		errors = append(errors, "HEALTH_CHECK_TIMEOUT must be positive")
+	}
+	if c.HealthCheck.WebhookURL != "" && !strings.HasPrefix(c.HealthCheck.WebhookURL, "http://") && !strings.HasPrefix(c.HealthCheck.WebhookURL, "https://") {
+		errors = append(errors, "HEALTH_CHECK_WEBHOOK_URL must be an http(s) URL")
+	}
 	if c.Security.BearerToken == "" {
 		errors = append(errors, "BEARER_TOKEN is required")
 	}
@@ -298,6 +996,163 @@ func (c *Config) Validate() error {
 		errors = append(errors, "RATE_LIMIT_RPS should not exceed 10000 for performance reasons")
 	}
 
+	// 验证 Responses 存储配置
+	if c.Responses.StoreTTL <= 0 {
+		errors = append(errors, "RESPONSES_STORE_TTL must be positive")
+	}
+	if c.Responses.StoreMaxEntries <= 0 {
+		errors = append(errors, "RESPONSES_STORE_MAX_ENTRIES must be positive")
+	}
+
+	// 验证结构化输出配置
+	if c.StructuredOutput.MaxRetries < 0 {
+		errors = append(errors, "STRUCTURED_OUTPUT_MAX_RETRIES must not be negative")
+	}
+
+	// 验证多候选配置
+	if c.Choices.MaxN < 1 {
+		errors = append(errors, "CHOICES_MAX_N must be positive")
+	}
+	if c.Choices.Concurrency < 1 {
+		errors = append(errors, "CHOICES_CONCURRENCY must be positive")
+	}
+	validPolicies := []string{PartialFailureFail, PartialFailurePartial}
+	if !contains(validPolicies, c.Choices.PartialFailure) {
+		errors = append(errors, fmt.Sprintf("CHOICES_PARTIAL_FAILURE must be one of: %s", strings.Join(validPolicies, ", ")))
+	}
+
+	// 验证思考过程输出配置
+	if !contains(ReasoningModes, c.Reasoning.Mode) {
+		errors = append(errors, fmt.Sprintf("REASONING_MODE must be one of: %s", strings.Join(ReasoningModes, ", ")))
+	}
+
+	// 验证系统提示词配置
+	if !contains(SystemPromptStrategies, c.SystemPrompt.Strategy) {
+		errors = append(errors, fmt.Sprintf("SYSTEM_PROMPT_STRATEGY must be one of: %s", strings.Join(SystemPromptStrategies, ", ")))
+	}
+	if c.SystemPrompt.Strategy == SystemPromptMerge &&
+		(!strings.Contains(c.SystemPrompt.Template, SystemPromptPlaceholder) || !strings.Contains(c.SystemPrompt.Template, UserPromptPlaceholder)) {
+		errors = append(errors, "SYSTEM_PROMPT_TEMPLATE must contain {{system}} and {{user}}")
+	}
+	if c.SystemPrompt.Strategy == SystemPromptCustomBot && c.Monica.BotUID == "" {
+		errors = append(errors, "BOT_UID is required when SYSTEM_PROMPT_STRATEGY is custom_bot")
+	}
+
+	// 验证远程文件下载配置
+	if c.RemoteFetch.Enabled {
+		if c.RemoteFetch.MaxSize <= 0 {
+			errors = append(errors, "REMOTE_FETCH_MAX_SIZE must be positive")
+		}
+		if c.RemoteFetch.Timeout <= 0 {
+			errors = append(errors, "REMOTE_FETCH_TIMEOUT must be positive")
+		}
+		if c.RemoteFetch.MaxRedirects < 0 {
+			errors = append(errors, "REMOTE_FETCH_MAX_REDIRECTS must not be negative")
+		}
+	}
+
+	// 验证文档附件配置
+	if c.Attachments.MaxSize <= 0 {
+		errors = append(errors, "ATTACHMENTS_MAX_SIZE must be positive")
+	}
+	if c.Attachments.IndexTimeout <= 0 {
+		errors = append(errors, "ATTACHMENTS_INDEX_TIMEOUT must be positive")
+	}
+
+	// 验证 Files API 配置
+	if c.Files.MaxSize <= 0 {
+		errors = append(errors, "FILES_MAX_SIZE must be positive")
+	}
+
+	// 验证上传缓存配置
+	if c.ImageCache.MaxEntries <= 0 {
+		errors = append(errors, "IMAGE_CACHE_MAX_ENTRIES must be positive")
+	}
+	if c.ImageCache.MaxBytes <= 0 {
+		errors = append(errors, "IMAGE_CACHE_MAX_BYTES must be positive")
+	}
+	if c.ImageCache.TTL <= 0 {
+		errors = append(errors, "IMAGE_CACHE_TTL must be positive")
+	}
+
+	// 验证图片上传失败处理配置
+	if !contains(ImageUploadPolicies, c.ImageUpload.FailurePolicy) {
+		errors = append(errors, fmt.Sprintf("IMAGE_UPLOAD_FAILURE_POLICY must be one of: %s", strings.Join(ImageUploadPolicies, ", ")))
+	}
+	if c.ImageUpload.MaxRetries < 0 {
+		errors = append(errors, "IMAGE_UPLOAD_MAX_RETRIES must not be negative")
+	}
+	if c.ImageUpload.RetryFallback != ImageUploadStrict && c.ImageUpload.RetryFallback != ImageUploadLenient {
+		errors = append(errors, "IMAGE_UPLOAD_RETRY_FALLBACK must be one of: strict, lenient")
+	}
+
+	// 验证图片预处理配置
+	if c.ImagePreprocess.Enabled {
+		if c.ImagePreprocess.MaxDimension < 0 || c.ImagePreprocess.LowDetailDimension < 0 {
+			errors = append(errors, "IMAGE_PREPROCESS_MAX_DIMENSION and IMAGE_PREPROCESS_LOW_DETAIL_DIMENSION must not be negative")
+		}
+		if c.ImagePreprocess.JPEGQuality < 1 || c.ImagePreprocess.JPEGQuality > 100 {
+			errors = append(errors, "IMAGE_PREPROCESS_JPEG_QUALITY must be between 1 and 100")
+		}
+	}
+
+	if !contains(ContextStrategies, c.Context.Strategy) {
+		errors = append(errors, fmt.Sprintf("CONTEXT_STRATEGY must be one of: %s", strings.Join(ContextStrategies, ", ")))
+	}
+	if c.Context.DefaultLimit <= 0 {
+		errors = append(errors, "CONTEXT_DEFAULT_LIMIT must be positive")
+	}
+	if c.Context.ReserveTokens < 0 {
+		errors = append(errors, "CONTEXT_RESERVE_TOKENS cannot be negative")
+	}
+	if c.Context.Strategy == ContextStrategySummarize && c.Context.SummaryModel == "" {
+		errors = append(errors, "CONTEXT_SUMMARY_MODEL is required when CONTEXT_STRATEGY is summarize")
+	}
+
+	if c.Session.Enabled {
+		if c.Session.TTL <= 0 {
+			errors = append(errors, "SESSION_TTL must be positive")
+		}
+		if c.Session.MaxEntries <= 0 {
+			errors = append(errors, "SESSION_MAX_ENTRIES must be positive")
+		}
+	}
+
+	if c.ImageStore.Enabled {
+		if c.ImageStore.Dir == "" {
+			errors = append(errors, "IMAGE_STORE_DIR is required when IMAGE_STORE_ENABLED is true")
+		}
+		if c.ImageStore.Retention <= 0 {
+			errors = append(errors, "IMAGE_STORE_RETENTION must be positive")
+		}
+		if c.ImageStore.MaxBytes <= 0 {
+			errors = append(errors, "IMAGE_STORE_MAX_BYTES must be positive")
+		}
+		if c.ImageStore.URLTTL <= 0 {
+			errors = append(errors, "IMAGE_STORE_URL_TTL must be positive")
+		}
+	}
+
+	if c.ImageModels.Default == "" {
+		errors = append(errors, "IMAGE_DEFAULT_MODEL is required")
+	}
+	for name, model := range c.ImageModels.Models {
+		if model.ModelType == "" || len(model.AspectRatios) == 0 {
+			errors = append(errors, fmt.Sprintf("image_models.models.%s requires model_type and aspect_ratios", name))
+		}
+		if model.MaxImages < 0 {
+			errors = append(errors, fmt.Sprintf("image_models.models.%s.max_images must not be negative", name))
+		}
+		for _, ratio := range model.AspectRatios {
+			w, h, ok := strings.Cut(ratio, ":")
+			wn, werr := strconv.Atoi(w)
+			hn, herr := strconv.Atoi(h)
+			if !ok || werr != nil || herr != nil || wn <= 0 || hn <= 0 {
+				errors = append(errors, fmt.Sprintf("image_models.models.%s has invalid aspect ratio: %s", name, ratio))
+			}
+		}
+	}
+
 	// 验证日志级别
 	validLevels := []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
 	if !contains(validLevels, c.Logging.Level) {
@@ -25,6 +25,10 @@ const (
 	ErrImageGeneration
 	ErrModelMapping
 	ErrFileUpload
+	ErrStructuredOutput
+	ErrFileIndex
+	ErrContextLength
+	ErrToolChoice
 )
 
 // AppError 应用错误
@@ -87,6 +91,15 @@ func NewUnauthorizedError(message string) *AppError {
 	}
 }
 
+// NewNotFoundError 创建资源不存在错误
+func NewNotFoundError(message string) *AppError {
+	return &AppError{
+		Code:    ErrNotFound,
+		Message: message,
+		Status:  http.StatusNotFound,
+	}
+}
+
 // NewInvalidInputError 创建无效输入错误
 func NewInvalidInputError(message string, err error) *AppError {
 	return &AppError{
@@ -144,3 +157,52 @@ func NewFileUploadError(err error) *AppError {
 		Status:  http.StatusInternalServerError,
 	}
 }
+
+// NewImageUploadError 创建图片上传失败错误，part 为失败的内容段，如 messages[0].content[1]
+func NewImageUploadError(part string, err error) *AppError {
+	return &AppError{
+		Code:    ErrFileUpload,
+		Message: fmt.Sprintf("%s 的图片上传失败", part),
+		Err:     err,
+		Status:  http.StatusBadGateway,
+	}
+}
+
+// NewStructuredOutputError 创建结构化输出校验失败错误
+func NewStructuredOutputError(err error) *AppError {
+	return &AppError{
+		Code:    ErrStructuredOutput,
+		Message: fmt.Sprintf("模型输出不符合 response_format: %v", err),
+		Err:     err,
+		Status:  http.StatusUnprocessableEntity,
+	}
+}
+
+// NewFileIndexError 创建文件解析失败错误，Monica 无法读取上传的文件内容时返回
+func NewFileIndexError(fileName string, err error) *AppError {
+	return &AppError{
+		Code:    ErrFileIndex,
+		Message: fmt.Sprintf("文件 %s 解析失败: %v", fileName, err),
+		Err:     err,
+		Status:  http.StatusUnprocessableEntity,
+	}
+}
+
+// NewContextLengthError 创建上下文超长错误，丢弃较早的对话后仍超出模型上下文窗口时返回
+func NewContextLengthError(model string, tokens, limit int) *AppError {
+	return &AppError{
+		Code:    ErrContextLength,
+		Message: fmt.Sprintf("输入约 %d tokens，超出模型 %s 的上下文窗口 %d tokens", tokens, model, limit),
+		Status:  http.StatusBadRequest,
+	}
+}
+
+// NewToolChoiceError 创建工具调用不满足 tool_choice 错误，重新请求后模型仍未按要求调用工具时返回
+func NewToolChoiceError(err error) *AppError {
+	return &AppError{
+		Code:    ErrToolChoice,
+		Message: fmt.Sprintf("模型未按 tool_choice 调用工具: %v", err),
+		Err:     err,
+		Status:  http.StatusUnprocessableEntity,
+	}
+}
@@ -0,0 +1,168 @@
+// Package imageproc 在上传前对图片做纯 Go 预处理：缩放、重新压缩、格式转换与去除元数据
+package imageproc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+
+	xdraw "golang.org/x/image/draw"
+
+	_ "golang.org/x/image/bmp"  // 注册 BMP 解码器
+	_ "golang.org/x/image/tiff" // 注册 TIFF 解码器
+	_ "golang.org/x/image/webp" // 注册 WebP 解码器
+)
+
+// DetailLow OpenAI image_url 的 detail=low，按更小的尺寸缩放以节省上游 token
+const DetailLow = "low"
+
+const (
+	// maxPixels 允许解码的最大像素数，防止解压炸弹
+	maxPixels = 100_000_000
+	// minJPEGQuality 为满足大小上限逐步降低 JPEG 质量时的下限，低于它改为缩小尺寸
+	minJPEGQuality = 50
+	// minDimension 为满足大小上限逐步缩小尺寸时长边的下限
+	minDimension = 64
+)
+
+// Options 预处理选项
+type Options struct {
+	MaxBytes           int64 // 处理后的最大字节数
+	MaxDimension       int   // 长边的最大像素数，0 表示不限制
+	LowDetailDimension int   // detail=low 时长边的最大像素数
+	JPEGQuality        int   // 重新编码 JPEG 的初始质量
+}
+
+// Result 预处理结果
+type Result struct {
+	Data     []byte
+	MimeType string
+	Changed  bool // 是否与原始数据不同
+}
+
+// formatMimeTypes image 包注册的格式名对应的 MIME 类型
+var formatMimeTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+	"bmp":  "image/bmp",
+	"tiff": "image/tiff",
+}
+
+// Process 预处理图片
+// 超出尺寸或大小上限的图片缩小并重新压缩，BMP/TIFF 转换为 PNG，按 EXIF 方向摆正后去除 EXIF 等元数据；
+// GIF 可能是动图，保持原样
+func Process(data []byte, detail string, opts Options) (*Result, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image failed: %w", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image too large: %dx%d", config.Width, config.Height)
+	}
+	mimeType := formatMimeTypes[format]
+	if format == "gif" {
+		return &Result{Data: data, MimeType: mimeType}, nil
+	}
+
+	limit := opts.MaxDimension
+	if detail == DetailLow && opts.LowDetailDimension > 0 {
+		limit = opts.LowDetailDimension
+	}
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	reencode := format == "bmp" || format == "tiff" || orientation != 1 ||
+		(limit > 0 && max(config.Width, config.Height) > limit) ||
+		(opts.MaxBytes > 0 && int64(len(data)) > opts.MaxBytes)
+	if !reencode {
+		stripped, changed := stripMetadata(data, format)
+		return &Result{Data: stripped, MimeType: mimeType, Changed: changed}, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image failed: %w", err)
+	}
+	img = orient(resize(img, limit), orientation)
+
+	out, mimeType, err := encode(img, format, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Data: out, MimeType: mimeType, Changed: true}, nil
+}
+
+// encode 重新编码图片：JPEG 保持 JPEG，不透明的 WebP 转为 JPEG，其余转为 PNG
+// 超出大小上限时先降低 JPEG 质量，仍然超出则逐步缩小尺寸；不透明的 PNG 在缩小尺寸前改用 JPEG
+func encode(img image.Image, format string, opts Options) ([]byte, string, error) {
+	useJPEG := format == "jpeg" || (format == "webp" && opaque(img))
+	quality := opts.JPEGQuality
+	if quality <= 0 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+
+	for {
+		var buf bytes.Buffer
+		var err error
+		if useJPEG {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+		} else {
+			err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("encode image failed: %w", err)
+		}
+		if opts.MaxBytes <= 0 || int64(buf.Len()) <= opts.MaxBytes {
+			if useJPEG {
+				return buf.Bytes(), "image/jpeg", nil
+			}
+			return buf.Bytes(), "image/png", nil
+		}
+
+		switch {
+		case !useJPEG && opaque(img):
+			useJPEG = true
+		case useJPEG && quality-15 >= minJPEGQuality:
+			quality -= 15
+		default:
+			bounds := img.Bounds()
+			long := max(bounds.Dx(), bounds.Dy()) * 3 / 4
+			if long < minDimension {
+				return nil, "", fmt.Errorf("cannot compress image below %d bytes", opts.MaxBytes)
+			}
+			img = resize(img, long)
+		}
+	}
+}
+
+// resize 等比缩放到长边不超过 limit
+func resize(img image.Image, limit int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if limit <= 0 || max(w, h) <= limit {
+		return img
+	}
+	if w >= h {
+		w, h = limit, max(1, h*limit/w)
+	} else {
+		w, h = max(1, w*limit/h), limit
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, xdraw.Src, nil)
+	return dst
+}
+
+// opaque 图片是否不含透明像素
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
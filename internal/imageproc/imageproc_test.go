@@ -0,0 +1,135 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+// testImage 生成带渐变的不透明图片
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 255})
+		}
+	}
+	return img
+}
+
+// withEXIF 在 JPEG 的 SOI 之后插入只含 Orientation 标签的 EXIF 段
+func withEXIF(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	payload := append(append([]byte("Exif\x00\x00"), tiff...), entry...)
+	payload = append(payload, 0, 0, 0, 0)
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var testOptions = Options{MaxBytes: 10 << 20, MaxDimension: 256, LowDetailDimension: 64, JPEGQuality: 85}
+
+// TestProcessStripEXIF 测试不需要缩放的 JPEG 只去除 EXIF，不重新编码
+func TestProcessStripEXIF(t *testing.T) {
+	original := encodeJPEG(t, testImage(100, 50))
+	data := withEXIF(original, 1)
+	result, err := Process(data, "", testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Changed || !bytes.Equal(result.Data, original) || result.MimeType != "image/jpeg" {
+		t.Errorf("EXIF 未被去除: changed=%v size=%d original=%d", result.Changed, len(result.Data), len(original))
+	}
+}
+
+// TestProcessOrientation 测试按 EXIF 方向摆正图片
+func TestProcessOrientation(t *testing.T) {
+	data := withEXIF(encodeJPEG(t, testImage(100, 50)), 6)
+	result, err := Process(data, "", testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(result.Data))
+	if err != nil || config.Width != 50 || config.Height != 100 {
+		t.Errorf("旋转后尺寸错误: %+v %v", config, err)
+	}
+	if jpegOrientation(result.Data) != 1 {
+		t.Error("处理后的图片不应再带方向信息")
+	}
+}
+
+// TestProcessResize 测试按 detail 缩放
+func TestProcessResize(t *testing.T) {
+	data := encodeJPEG(t, testImage(512, 256))
+	for detail, want := range map[string]int{"": 256, "high": 256, DetailLow: 64} {
+		result, err := Process(data, detail, testOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, _, _ := image.DecodeConfig(bytes.NewReader(result.Data))
+		if config.Width != want || config.Height != want/2 {
+			t.Errorf("detail=%q 缩放后尺寸 %dx%d, 期望长边 %d", detail, config.Width, config.Height, want)
+		}
+	}
+}
+
+// TestProcessConvertBMP 测试 BMP 转换为 PNG
+func TestProcessConvertBMP(t *testing.T) {
+	var buf bytes.Buffer
+	if err := bmp.Encode(&buf, testImage(32, 32)); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Process(buf.Bytes(), "", testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(bytes.NewReader(result.Data)); err != nil || result.MimeType != "image/png" {
+		t.Errorf("BMP 应转换为 PNG: %s %v", result.MimeType, err)
+	}
+}
+
+// TestProcessMaxBytes 测试超出大小上限时重新压缩
+func TestProcessMaxBytes(t *testing.T) {
+	// 噪点图片几乎无法被 PNG 压缩
+	img := testImage(256, 256)
+	rng := rand.New(rand.NewSource(1))
+	rng.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions
+	opts.MaxBytes = int64(buf.Len() / 4)
+	result, err := Process(buf.Bytes(), "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(result.Data)) > opts.MaxBytes || result.MimeType != "image/jpeg" {
+		t.Errorf("压缩结果 %d 字节 (%s)，上限 %d", len(result.Data), result.MimeType, opts.MaxBytes)
+	}
+}
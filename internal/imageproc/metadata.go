@@ -0,0 +1,208 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// stripMetadata 在不重新编码的情况下去除 EXIF、XMP 与文本等元数据，返回是否有修改
+func stripMetadata(data []byte, format string) ([]byte, bool) {
+	var out []byte
+	switch format {
+	case "jpeg":
+		out = stripJPEG(data)
+	case "png":
+		out = stripPNG(data)
+	case "webp":
+		out = stripWebP(data)
+	}
+	if out == nil || len(out) == len(data) {
+		return data, false
+	}
+	return out, true
+}
+
+// stripJPEG 去除 APP1（EXIF、XMP）与 APP13（IPTC）段，保留 JFIF、ICC 等其余段；结构异常时返回 nil
+func stripJPEG(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil
+		}
+		marker := data[i+1]
+		// SOS 之后为压缩数据，原样保留
+		if marker == 0xDA {
+			return append(out, data[i:]...)
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		if marker != 0xE1 && marker != 0xED {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return nil
+}
+
+// pngMetadataChunks 需要去除的 PNG 元数据块
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+// stripPNG 去除 EXIF、文本与时间块；结构异常时返回 nil
+func stripPNG(data []byte) []byte {
+	const sigLen = 8
+	if len(data) < sigLen {
+		return nil
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:sigLen]...)
+	for i := sigLen; i < len(data); {
+		if i+8 > len(data) {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length // 长度、类型、数据、CRC
+		if length < 0 || end > len(data) {
+			return nil
+		}
+		if !pngMetadataChunks[string(data[i+4:i+8])] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out
+}
+
+// stripWebP 去除 EXIF 与 XMP 块并清除 VP8X 中对应的标志位；结构异常时返回 nil
+func stripWebP(data []byte) []byte {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil
+	}
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2 // 块按偶数字节对齐
+		if size < 0 || end > len(data) {
+			return nil
+		}
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[i:end]...)
+			if size > 0 {
+				out[start+8] &^= 0x08 | 0x04 // EXIF、XMP 标志位
+			}
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
+
+// jpegOrientation 读取 EXIF 中的方向（1-8），没有或无法解析时返回 1
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF || data[i+1] == 0xDA {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		if segment := data[i+4 : end]; data[i+1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i = end
+	}
+	return 1
+}
+
+// exifOrientation 在 TIFF 结构的 IFD0 中查找 Orientation（0x0112）标签
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for j := 0; j < count; j++ {
+		entry := ifd + 2 + j*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient 按 EXIF 方向摆正图片
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转 90°
+				dx, dy = h-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转 90°
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"monica-proxy/internal/account"
+	"monica-proxy/internal/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AccountLease 为每个请求创建账号租约，请求内的所有上游调用使用同一个账号，请求结束后释放
+// 请求头指定了会话键时同一会话固定使用同一个账号，保持 Monica 侧的会话与记忆连贯
+func AccountLease() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, release := account.WithLease(c.Request().Context(), c.Request().Header.Get(types.SessionKeyHeader))
+			defer release()
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"monica-proxy/internal/config"
 	"monica-proxy/internal/logger"
 	"net/http"
@@ -10,12 +11,25 @@ import (
 	"go.uber.org/zap"
 )
 
+// publicPathPrefixes 不需要 Bearer Token 的路径，如自带签名的本地图片链接
+var publicPathPrefixes = []string{"/v1/images/files/"}
+
 // BearerAuth 创建一个Bearer Token认证中间件
 func BearerAuth(cfg *config.Config) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			for _, prefix := range publicPathPrefixes {
+				if c.Request().Method == http.MethodGet && strings.HasPrefix(c.Request().URL.Path, prefix) {
+					return next(c)
+				}
+			}
+
 			// 获取Authorization header
 			auth := c.Request().Header.Get("Authorization")
+			// Anthropic SDK 使用 x-api-key 传递密钥
+			if apiKey := c.Request().Header.Get("x-api-key"); auth == "" && apiKey != "" {
+				auth = "Bearer " + apiKey
+			}
 
 			// 检查header格式
 			if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
@@ -62,3 +76,27 @@ func BearerAuth(cfg *config.Config) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// AdminTokenHeader 运维接口使用的令牌请求头
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminAuth 运维接口（/admin/*）在 Bearer Token 之外还需要 X-Admin-Token，未配置 ADMIN_TOKEN 时拒绝所有请求
+func AdminAuth(cfg *config.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Security.AdminToken == "" {
+				return echo.NewHTTPError(http.StatusForbidden, "admin token is not configured")
+			}
+			token := c.Request().Header.Get(AdminTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Security.AdminToken)) != 1 {
+				logger.Warn("无效的运维令牌",
+					zap.String("method", c.Request().Method),
+					zap.String("uri", c.Request().RequestURI),
+					zap.String("remote_addr", c.RealIP()),
+				)
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid admin token")
+			}
+			return next(c)
+		}
+	}
+}
@@ -20,16 +20,61 @@ func buildErrorResponse(code any, message, requestID string) map[string]any {
 	}
 }
 
+// anthropicErrorPaths 返回 Anthropic 格式错误的路由，客户端 SDK 按该格式解析错误
+var anthropicErrorPaths = map[string]bool{
+	"/v1/messages": true,
+}
+
+// buildAnthropicErrorResponse 构建 Anthropic 格式的错误响应
+func buildAnthropicErrorResponse(status int, message string) map[string]any {
+	return map[string]any{
+		"type": "error",
+		"error": map[string]any{
+			"type":    anthropicErrorType(status),
+			"message": message,
+		},
+	}
+}
+
+// anthropicErrorType 将 HTTP 状态码映射为 Anthropic 的错误类型
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	}
+	if status >= http.StatusInternalServerError {
+		return "api_error"
+	}
+	return "invalid_request_error"
+}
+
 // ErrorHandler 创建统一的错误处理中间件
 func ErrorHandler() echo.HTTPErrorHandler {
 	return func(err error, c echo.Context) {
 		// 获取请求ID
 		requestID := c.Request().Header.Get(echo.HeaderXRequestID)
+		// 按路由选择错误格式，Anthropic 接口不带错误码与请求ID
+		respond := func(status int, code any, message string) {
+			if anthropicErrorPaths[c.Path()] {
+				c.JSON(status, buildAnthropicErrorResponse(status, message))
+				return
+			}
+			c.JSON(status, buildErrorResponse(code, message, requestID))
+		}
 
 		// 处理应用错误
 		if appErr, ok := err.(*errors.AppError); ok {
 			status, _ := appErr.HTTPResponse()
-			response := buildErrorResponse(appErr.Code, appErr.Message, requestID)
 
 			// 记录错误日志
 			logger.Error("应用错误",
@@ -40,7 +85,7 @@ func ErrorHandler() echo.HTTPErrorHandler {
 				zap.String("request_id", requestID),
 			)
 
-			c.JSON(status, response)
+			respond(status, appErr.Code, appErr.Message)
 			return
 		}
 
@@ -52,9 +97,6 @@ func ErrorHandler() echo.HTTPErrorHandler {
 				message = m
 			}
 
-			// 构建响应
-			response := buildErrorResponse(echoErr.Code, message, requestID)
-
 			// 记录错误日志
 			logger.Error("框架错误",
 				zap.Int("status", status),
@@ -63,13 +105,12 @@ func ErrorHandler() echo.HTTPErrorHandler {
 				zap.String("request_id", requestID),
 			)
 
-			c.JSON(status, response)
+			respond(status, echoErr.Code, message)
 			return
 		}
 
 		// 处理其他错误
 		status := http.StatusInternalServerError
-		response := buildErrorResponse(status, "服务器内部错误", requestID)
 
 		// 记录错误日志
 		logger.Error("未分类错误",
@@ -78,6 +119,6 @@ func ErrorHandler() echo.HTTPErrorHandler {
 			zap.String("request_id", requestID),
 		)
 
-		c.JSON(status, response)
+		respond(status, status, "服务器内部错误")
 	}
 }
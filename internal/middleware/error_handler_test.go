@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"monica-proxy/internal/errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TestErrorHandlerAnthropic 测试 /v1/messages 的错误按 Anthropic 格式返回，其余接口保持原格式
+func TestErrorHandlerAnthropic(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler()
+	fail := func(c echo.Context) error {
+		return errors.NewBadRequestError("无效的请求数据", nil)
+	}
+	e.POST("/v1/messages", fail)
+	e.POST("/v1/chat/completions", fail)
+
+	request := func(path string) map[string]any {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}")))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s 状态码 %d, 期望 400", path, rec.Code)
+		}
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	body := request("/v1/messages")
+	detail, _ := body["error"].(map[string]any)
+	if body["type"] != "error" || detail["type"] != "invalid_request_error" || detail["message"] != "无效的请求数据" {
+		t.Errorf("Anthropic 错误格式不符: %v", body)
+	}
+
+	body = request("/v1/chat/completions")
+	detail, _ = body["error"].(map[string]any)
+	if _, ok := body["type"]; ok || detail["code"] != float64(errors.ErrBadRequest) {
+		t.Errorf("OpenAI 错误格式不应改变: %v", body)
+	}
+}
+
+// TestAnthropicErrorType 测试状态码到 Anthropic 错误类型的映射
+func TestAnthropicErrorType(t *testing.T) {
+	tests := map[int]string{
+		http.StatusBadRequest:          "invalid_request_error",
+		http.StatusUnauthorized:        "authentication_error",
+		http.StatusNotFound:            "not_found_error",
+		http.StatusTooManyRequests:     "rate_limit_error",
+		http.StatusBadGateway:          "api_error",
+		http.StatusInternalServerError: "api_error",
+	}
+	for status, want := range tests {
+		if got := anthropicErrorType(status); got != want {
+			t.Errorf("anthropicErrorType(%d) = %s, 期望 %s", status, got, want)
+		}
+	}
+}
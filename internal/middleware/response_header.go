@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"monica-proxy/internal/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ResponseHeaders 为每个请求创建响应头收集器，服务层写入的响应头在响应开始写出前附加到响应中
+func ResponseHeaders() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, headers := utils.WithResponseHeaders(c.Request().Context())
+			c.SetRequest(c.Request().WithContext(ctx))
+			c.Response().Before(func() {
+				headers.CopyTo(c.Response().Header())
+			})
+			return next(c)
+		}
+	}
+}
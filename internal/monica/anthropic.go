@@ -0,0 +1,311 @@
+package monica
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	anthropicStopEndTurn      = "end_turn"
+	anthropicStopToolUse      = "tool_use"
+	anthropicStopMaxTokens    = "max_tokens"
+	anthropicStopStopSequence = "stop_sequence"
+
+	anthropicBlockText     = "text"
+	anthropicBlockThinking = "thinking"
+	anthropicBlockToolUse  = "tool_use"
+)
+
+// newAnthropicMessageID 生成 Anthropic 风格的消息ID
+func newAnthropicMessageID() string {
+	return "msg_" + utils.RandStringUsingMathRand(24)
+}
+
+// anthropicStopReason 将结束事件映射为 Anthropic stop_reason 与 stop_sequence
+func anthropicStopReason(reason openai.FinishReason, stopSequence string) (string, *string) {
+	switch {
+	case reason == openai.FinishReasonToolCalls:
+		return anthropicStopToolUse, nil
+	case reason == openai.FinishReasonLength:
+		return anthropicStopMaxTokens, nil
+	case stopSequence != "":
+		return anthropicStopStopSequence, &stopSequence
+	default:
+		return anthropicStopEndTurn, nil
+	}
+}
+
+// anthropicUsage 将本地统计的用量转换为 Anthropic usage
+func anthropicUsage(usage openai.Usage) types.AnthropicUsage {
+	return types.AnthropicUsage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+	}
+}
+
+// anthropicToolInput 将工具调用参数转换为 tool_use 块的 input
+func anthropicToolInput(arguments string) json.RawMessage {
+	if !json.Valid([]byte(arguments)) {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// thinkingSignature 生成思考块的签名
+// Monica 不提供思考签名，这里用思考内容的摘要作为不透明的签名，满足要求签名非空的客户端；请求中回传的 thinking 块会被丢弃，不校验签名
+func thinkingSignature(thinking string) string {
+	sum := sha256.Sum256([]byte(thinking))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// CollectMonicaSSEToAnthropic 将 Monica SSE 转换为完整的 Anthropic Messages 响应
+// thinking 为请求是否开启扩展思考，未开启时丢弃上游的思考过程
+func CollectMonicaSSEToAnthropic(ctx context.Context, stream *CompletionStream, thinking bool) (*types.AnthropicMessagesResponse, error) {
+	var reasoning, text strings.Builder
+	var toolCalls []openai.ToolCall
+	finishReason := openai.FinishReasonStop
+	var stopSequence string
+	var usage openai.Usage
+
+	processor := &processMonicaSSE{
+		reader: bufio.NewReaderSize(stream.Body, bufferSize),
+		model:  stream.Options.Model,
+		ctx:    ctx,
+	}
+	err := processor.processCompletionEvents(stream.Options, func(ev sseEvent) error {
+		switch ev.typ {
+		case eventReasoning:
+			if thinking {
+				reasoning.WriteString(ev.text)
+			}
+		case eventText:
+			text.WriteString(ev.text)
+		case eventToolCalls:
+			toolCalls = append(toolCalls, ev.toolCalls...)
+		case eventFinish:
+			finishReason = ev.finishReason
+			stopSequence = ev.stopSequence
+			if ev.usage != nil {
+				usage = *ev.usage
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	content := make([]types.AnthropicContentBlock, 0, 2)
+	if reasoning.Len() > 0 {
+		content = append(content, types.AnthropicContentBlock{
+			Type:      anthropicBlockThinking,
+			Thinking:  reasoning.String(),
+			Signature: thinkingSignature(reasoning.String()),
+		})
+	}
+	if text.Len() > 0 || len(toolCalls) == 0 {
+		content = append(content, types.AnthropicContentBlock{
+			Type: anthropicBlockText,
+			Text: text.String(),
+		})
+	}
+	for _, call := range toolCalls {
+		content = append(content, types.AnthropicContentBlock{
+			Type:  anthropicBlockToolUse,
+			ID:    call.ID,
+			Name:  call.Function.Name,
+			Input: anthropicToolInput(call.Function.Arguments),
+		})
+	}
+
+	stopReason, matched := anthropicStopReason(finishReason, stopSequence)
+	return &types.AnthropicMessagesResponse{
+		ID:           newAnthropicMessageID(),
+		Type:         "message",
+		Role:         "assistant",
+		Model:        stream.Options.Model,
+		Content:      content,
+		StopReason:   &stopReason,
+		StopSequence: matched,
+		Usage:        anthropicUsage(usage),
+	}, nil
+}
+
+// anthropicStream Anthropic 流式输出状态，负责内容块的开启与关闭
+type anthropicStream struct {
+	writer     *sseWriter
+	blockType  string // 当前打开的内容块类型，空表示没有打开的块
+	blockIndex int
+	thinking   strings.Builder // 当前思考块已输出的内容，用于在关闭时生成签名
+}
+
+// openBlock 确保当前打开的内容块为指定类型，必要时关闭旧块并开启新块
+func (s *anthropicStream) openBlock(blockType string) error {
+	if s.blockType == blockType {
+		return nil
+	}
+
+	contentBlock := map[string]any{"type": blockType}
+	switch blockType {
+	case anthropicBlockThinking:
+		contentBlock["thinking"] = ""
+		contentBlock["signature"] = ""
+	case anthropicBlockText:
+		contentBlock["text"] = ""
+	}
+	return s.startBlock(blockType, contentBlock)
+}
+
+// startBlock 关闭当前块并开启一个新的内容块
+func (s *anthropicStream) startBlock(blockType string, contentBlock map[string]any) error {
+	if err := s.closeBlock(); err != nil {
+		return err
+	}
+	s.blockType = blockType
+	return s.writer.WriteEvent("content_block_start", types.AnthropicContentBlockStartEvent{
+		Type:         "content_block_start",
+		Index:        s.blockIndex,
+		ContentBlock: contentBlock,
+	})
+}
+
+// toolUse 输出一个完整的 tool_use 块，参数以单个 input_json_delta 发送
+func (s *anthropicStream) toolUse(call openai.ToolCall) error {
+	err := s.startBlock(anthropicBlockToolUse, map[string]any{
+		"type":  anthropicBlockToolUse,
+		"id":    call.ID,
+		"name":  call.Function.Name,
+		"input": map[string]any{},
+	})
+	if err != nil {
+		return err
+	}
+	input := anthropicToolInput(call.Function.Arguments)
+	if err := s.delta(map[string]string{"type": "input_json_delta", "partial_json": string(input)}); err != nil {
+		return err
+	}
+	return s.closeBlock()
+}
+
+// closeBlock 关闭当前打开的内容块
+func (s *anthropicStream) closeBlock() error {
+	if s.blockType == "" {
+		return nil
+	}
+	if s.blockType == anthropicBlockThinking {
+		signature := thinkingSignature(s.thinking.String())
+		s.thinking.Reset()
+		if err := s.delta(map[string]string{"type": "signature_delta", "signature": signature}); err != nil {
+			return err
+		}
+	}
+	err := s.writer.WriteEvent("content_block_stop", types.AnthropicContentBlockStopEvent{
+		Type:  "content_block_stop",
+		Index: s.blockIndex,
+	})
+	s.blockType = ""
+	s.blockIndex++
+	return err
+}
+
+// delta 向当前内容块写入增量
+func (s *anthropicStream) delta(delta map[string]string) error {
+	return s.writer.WriteEvent("content_block_delta", types.AnthropicContentBlockDeltaEvent{
+		Type:  "content_block_delta",
+		Index: s.blockIndex,
+		Delta: delta,
+	})
+}
+
+// StreamMonicaSSEToAnthropic 将 Monica SSE 转成 Anthropic Messages 流式事件
+// 请求开启扩展思考时 thinking_detail_stream 输出为 thinking 块，否则丢弃；正文输出为 text 块，工具调用输出为 tool_use 块
+func StreamMonicaSSEToAnthropic(ctx context.Context, w io.Writer, stream *CompletionStream, thinking bool) error {
+	writer := newSSEWriter(w)
+	defer writer.Close()
+
+	out := &anthropicStream{writer: writer}
+
+	err := writer.WriteEvent("message_start", types.AnthropicMessageStartEvent{
+		Type: "message_start",
+		Message: types.AnthropicMessagesResponse{
+			ID:      newAnthropicMessageID(),
+			Type:    "message",
+			Role:    "assistant",
+			Model:   stream.Options.Model,
+			Content: []types.AnthropicContentBlock{},
+			Usage:   types.AnthropicUsage{InputTokens: stream.Options.PromptTokens},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+
+	processor := &processMonicaSSE{
+		reader: bufio.NewReaderSize(stream.Body, bufferSize),
+		model:  stream.Options.Model,
+		ctx:    ctx,
+	}
+	return processor.processCompletionEvents(stream.Options, func(ev sseEvent) error {
+		switch ev.typ {
+		case eventReasoning:
+			if !thinking {
+				return nil
+			}
+			if err := out.openBlock(anthropicBlockThinking); err != nil {
+				return err
+			}
+			out.thinking.WriteString(ev.text)
+			return out.delta(map[string]string{"type": "thinking_delta", "thinking": ev.text})
+		case eventText:
+			if err := out.openBlock(anthropicBlockText); err != nil {
+				return err
+			}
+			return out.delta(map[string]string{"type": "text_delta", "text": ev.text})
+		case eventToolCalls:
+			for _, call := range ev.toolCalls {
+				if err := out.toolUse(call); err != nil {
+					return err
+				}
+			}
+			return nil
+		case eventSources:
+			// 引用来源只在 ChatCompletion 中以 annotations 返回
+			return nil
+		}
+
+		// eventFinish：保证至少输出一个内容块，然后结束消息
+		if out.blockIndex == 0 && out.blockType == "" {
+			if err := out.openBlock(anthropicBlockText); err != nil {
+				return err
+			}
+		}
+		if err := out.closeBlock(); err != nil {
+			return err
+		}
+
+		messageDelta := types.AnthropicMessageDeltaEvent{Type: "message_delta"}
+		messageDelta.Delta.StopReason, messageDelta.Delta.StopSequence = anthropicStopReason(ev.finishReason, ev.stopSequence)
+		if ev.usage != nil {
+			messageDelta.Usage = anthropicUsage(*ev.usage)
+		}
+		if err := writer.WriteEvent("message_delta", messageDelta); err != nil {
+			return err
+		}
+		if err := writer.WriteEvent("message_stop", types.AnthropicMessageStopEvent{Type: "message_stop"}); err != nil {
+			return err
+		}
+		writer.Flush()
+		return nil
+	})
+}
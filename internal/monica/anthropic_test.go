@@ -0,0 +1,105 @@
+package monica
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// anthropicEvents 解析 Anthropic 流式输出，返回事件名与对应的数据
+func anthropicEvents(t *testing.T, out string) ([]string, []map[string]any) {
+	t.Helper()
+	var names []string
+	var payloads []map[string]any
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			names = append(names, strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			var payload map[string]any
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &payload); err != nil {
+				t.Fatalf("无效的事件数据 %q: %v", line, err)
+			}
+			payloads = append(payloads, payload)
+		}
+	}
+	if len(names) != len(payloads) {
+		t.Fatalf("事件名与数据数量不一致:\n%s", out)
+	}
+	return names, payloads
+}
+
+// TestStreamAnthropic 测试流式事件顺序、thinking 块签名以及未开启思考时丢弃思考过程
+func TestStreamAnthropic(t *testing.T) {
+	var buf bytes.Buffer
+	stream := &CompletionStream{Body: fakeReasoningSSE(), Options: CompletionOptions{Model: "claude-3-7-sonnet-thinking"}}
+	if err := StreamMonicaSSEToAnthropic(context.Background(), &buf, stream, true); err != nil {
+		t.Fatal(err)
+	}
+	names, payloads := anthropicEvents(t, buf.String())
+	want := []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("事件顺序错误:\n%v\nwant %v", names, want)
+	}
+	if block := payloads[1]["content_block"].(map[string]any); block["type"] != "thinking" {
+		t.Errorf("第一个内容块应为 thinking: %v", block)
+	}
+	if delta := payloads[2]["delta"].(map[string]any); delta["thinking"] != "think" {
+		t.Errorf("thinking_delta 错误: %v", delta)
+	}
+	if delta := payloads[3]["delta"].(map[string]any); delta["type"] != "signature_delta" || delta["signature"] != thinkingSignature("think") {
+		t.Errorf("signature_delta 错误: %v", delta)
+	}
+	if delta := payloads[6]["delta"].(map[string]any); delta["text"] != "answer" || payloads[6]["index"] != float64(1) {
+		t.Errorf("text_delta 错误: %v", payloads[6])
+	}
+	if delta := payloads[8]["delta"].(map[string]any); delta["stop_reason"] != "end_turn" {
+		t.Errorf("stop_reason 错误: %v", delta)
+	}
+
+	buf.Reset()
+	stream = &CompletionStream{Body: fakeReasoningSSE(), Options: CompletionOptions{Model: "claude-3-7-sonnet-thinking"}}
+	if err := StreamMonicaSSEToAnthropic(context.Background(), &buf, stream, false); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `"type":"thinking`) {
+		t.Errorf("未开启思考时不应输出 thinking 块:\n%s", buf.String())
+	}
+	if names, _ := anthropicEvents(t, buf.String()); len(names) != 6 {
+		t.Errorf("未开启思考时应只有一个 text 块: %v", names)
+	}
+}
+
+// TestCollectAnthropic 测试非流式响应的内容块
+func TestCollectAnthropic(t *testing.T) {
+	stream := &CompletionStream{Body: fakeReasoningSSE(), Options: CompletionOptions{Model: "claude-3-7-sonnet-thinking"}}
+	resp, err := CollectMonicaSSEToAnthropic(context.Background(), stream, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Content) != 2 || resp.Content[0].Thinking != "think" || resp.Content[0].Signature == "" || resp.Content[1].Text != "answer" {
+		t.Errorf("开启思考时内容块错误: %+v", resp.Content)
+	}
+	if resp.StopReason == nil || *resp.StopReason != "end_turn" {
+		t.Errorf("stop_reason 错误: %v", resp.StopReason)
+	}
+
+	stream = &CompletionStream{Body: fakeReasoningSSE(), Options: CompletionOptions{Model: "claude-3-7-sonnet-thinking"}}
+	resp, err = CollectMonicaSSEToAnthropic(context.Background(), stream, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Type != "text" {
+		t.Errorf("未开启思考时应只有 text 块: %+v", resp.Content)
+	}
+}
@@ -0,0 +1,108 @@
+package monica
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"monica-proxy/internal/types"
+)
+
+// Source 联网搜索返回的来源网页
+type Source struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// SourceList 上游 agent_status 中的来源列表
+// 不同模型的字段格式不完全一致，无法识别时忽略，不影响正文的解析
+type SourceList []Source
+
+// UnmarshalJSON 兼容 url/link 与 title/name 两种字段名，丢弃没有链接的条目
+func (l *SourceList) UnmarshalJSON(data []byte) error {
+	var items []struct {
+		Title string `json:"title"`
+		Name  string `json:"name"`
+		URL   string `json:"url"`
+		Link  string `json:"link"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil
+	}
+	for _, item := range items {
+		source := Source{Title: item.Title, URL: item.URL}
+		if source.Title == "" {
+			source.Title = item.Name
+		}
+		if source.URL == "" {
+			source.URL = item.Link
+		}
+		if source.URL != "" {
+			*l = append(*l, source)
+		}
+	}
+	return nil
+}
+
+// mergeSources 追加新的来源，按链接去重并保持首次出现的顺序，序号与正文中的 [n] 标记对应
+func mergeSources(sources []Source, more ...Source) []Source {
+	for _, source := range more {
+		duplicated := false
+		for _, existing := range sources {
+			if existing.URL == source.URL {
+				duplicated = true
+				break
+			}
+		}
+		if !duplicated {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// urlCitations 将来源转换为 url_citation 标注
+// 正文中的 [n] 标记指向第 n 个来源，每处标记生成一条标注；正文未引用的来源以文末的空区间标注
+// 位置按字符计算，offset 为 content 中位于正文之前的字符数
+func urlCitations(text string, sources []Source, offset int) []types.ChatCompletionAnnotation {
+	if len(sources) == 0 {
+		return nil
+	}
+	annotations := make([]types.ChatCompletionAnnotation, 0, len(sources))
+	end := offset + utf8.RuneCountInString(text)
+	for i, source := range sources {
+		marker := "[" + strconv.Itoa(i+1) + "]"
+		cited := false
+		for from := 0; ; {
+			idx := strings.Index(text[from:], marker)
+			if idx < 0 {
+				break
+			}
+			start := offset + utf8.RuneCountInString(text[:from+idx])
+			annotations = append(annotations, newURLCitation(source, start, start+len(marker)))
+			from += idx + len(marker)
+			cited = true
+		}
+		if !cited {
+			annotations = append(annotations, newURLCitation(source, end, end))
+		}
+	}
+	slices.SortStableFunc(annotations, func(a, b types.ChatCompletionAnnotation) int {
+		return a.URLCitation.StartIndex - b.URLCitation.StartIndex
+	})
+	return annotations
+}
+
+func newURLCitation(source Source, start, end int) types.ChatCompletionAnnotation {
+	return types.ChatCompletionAnnotation{
+		Type: "url_citation",
+		URLCitation: &types.URLCitation{
+			StartIndex: start,
+			EndIndex:   end,
+			URL:        source.URL,
+			Title:      source.Title,
+		},
+	}
+}
@@ -0,0 +1,132 @@
+package monica
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/types"
+)
+
+// fakeSearchSSE 构造带搜索来源的 Monica SSE 响应体，重复的来源只保留一次
+func fakeSearchSSE() io.ReadCloser {
+	return io.NopCloser(strings.NewReader(
+		`data: {"agent_status":{"type":"search","metadata":{"sources":[{"title":"A","url":"https://a.example"},{"name":"B","link":"https://b.example"}]}}}` + "\n\n" +
+			`data: {"agent_status":{"type":"reference","metadata":{"references":[{"title":"A","url":"https://a.example"}]}}}` + "\n\n" +
+			`data: {"text":"天气晴[1]"}` + "\n\n" +
+			`data: {"text":"，气温 20 度[1]","finished":true}` + "\n\n"))
+}
+
+// TestCompletionCitations 测试非流式响应中的 url_citation 标注
+func TestCompletionCitations(t *testing.T) {
+	completion, err := CollectCompletion(context.Background(), &CompletionStream{Body: fakeSearchSSE(), Options: CompletionOptions{Model: "sonar"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completion.Sources) != 2 || completion.Sources[1].Title != "B" {
+		t.Fatalf("来源解析错误: %+v", completion.Sources)
+	}
+
+	resp := NewCompletionResponse("sonar", completion)
+	annotations := resp.Choices[0].Message.Annotations
+	if len(annotations) != 3 {
+		t.Fatalf("标注数量 %d, 期望 3", len(annotations))
+	}
+	// 天气晴[1]，气温 20 度[1]：两处引用第一个来源，第二个来源未被引用，标注在文末
+	want := [][2]int{{3, 6}, {14, 17}, {17, 17}}
+	for i, annotation := range annotations {
+		citation := annotation.URLCitation
+		if annotation.Type != "url_citation" || citation.StartIndex != want[i][0] || citation.EndIndex != want[i][1] {
+			t.Errorf("第 %d 条标注错误: %+v", i, citation)
+		}
+	}
+
+	data, err := json.Marshal(resp.Choices[0].Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"annotations":[{"type":"url_citation"`) || !strings.Contains(string(data), `"content":"天气晴[1]`) {
+		t.Errorf("序列化结果缺少 annotations: %s", data)
+	}
+}
+
+// TestStreamCitations 测试流式响应在结束前输出 url_citation 标注
+func TestStreamCitations(t *testing.T) {
+	stream := &CompletionStream{Body: fakeSearchSSE(), Options: CompletionOptions{Model: "sonar"}}
+	stream.SetReasoningMode(config.ReasoningModeContent)
+
+	var buf bytes.Buffer
+	if err := StreamMonicaSSEToClient(context.Background(), &buf, stream); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	annotations := strings.Index(out, `"annotations"`)
+	finish := strings.Index(out, `"finish_reason":"stop"`)
+	if annotations < 0 || finish < annotations {
+		t.Errorf("标注应在结束 chunk 之前输出:\n%s", out)
+	}
+	if !strings.Contains(out, `"url":"https://b.example"`) {
+		t.Errorf("缺少第二个来源:\n%s", out)
+	}
+}
+
+// TestCitationsWithReasoningTags 测试 tags 模式下引用位置跳过 <think></think>，思考过程中的 [n] 不生成标注
+func TestCitationsWithReasoningTags(t *testing.T) {
+	newStream := func() *CompletionStream {
+		body := io.NopCloser(strings.NewReader(
+			`data: {"agent_status":{"type":"thinking_detail_stream","metadata":{"reasoning_detail":"查阅[2]"}}}` + "\n\n" +
+				`data: {"agent_status":{"type":"search","metadata":{"sources":[{"title":"A","url":"https://a.example"},{"title":"B","url":"https://b.example"}]}}}` + "\n\n" +
+				`data: {"text":"天气晴[1]","finished":true}` + "\n\n"))
+		stream := &CompletionStream{Body: body, Options: CompletionOptions{Model: "sonar"}}
+		stream.SetReasoningMode(config.ReasoningModeTags)
+		return stream
+	}
+	// <think>查阅[2]</think>天气晴[1]：正文从第 20 个字符开始，[1] 位于 23-26，未被正文引用的 B 标注在文末
+	want := [][2]int{{23, 26}, {26, 26}}
+	check := func(annotations []types.ChatCompletionAnnotation) {
+		t.Helper()
+		if len(annotations) != len(want) {
+			t.Fatalf("标注数量 %d, 期望 %d: %+v", len(annotations), len(want), annotations)
+		}
+		for i, annotation := range annotations {
+			citation := annotation.URLCitation
+			if citation.StartIndex != want[i][0] || citation.EndIndex != want[i][1] {
+				t.Errorf("第 %d 条标注错误: %+v", i, citation)
+			}
+		}
+	}
+
+	stream := newStream()
+	completion, err := CollectCompletion(context.Background(), stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := NewCompletionResponse("sonar", completion)
+	ApplyReasoningMode(resp, stream.Options.ReasoningMode)
+	if content := resp.Choices[0].Message.Content; content != "<think>查阅[2]</think>天气晴[1]" {
+		t.Fatalf("content = %q", content)
+	}
+	check(resp.Choices[0].Message.Annotations)
+
+	var buf bytes.Buffer
+	if err := StreamMonicaSSEToClient(context.Background(), &buf, newStream()); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || !strings.Contains(data, `"annotations"`) {
+			continue
+		}
+		var chunk types.ChatCompletionStreamResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		check(chunk.Choices[0].Delta.Annotations)
+		return
+	}
+	t.Fatalf("流式响应缺少标注:\n%s", buf.String())
+}
@@ -2,6 +2,8 @@ package monica
 
 import (
 	"context"
+	"fmt"
+	"monica-proxy/internal/account"
 	"monica-proxy/internal/config"
 	"monica-proxy/internal/errors"
 	"monica-proxy/internal/logger"
@@ -12,15 +14,40 @@ import (
 	"go.uber.org/zap"
 )
 
+// failoverRequest 可以换用其他账号重试的上游请求
+type failoverRequest interface {
+	Portable() bool
+	SetAccount(name string)
+}
+
+// sendWithFailover 发起上游对话请求，账号 Cookie 失效或额度耗尽时换用下一个可用账号重试
+// 此时还没有向客户端写入任何内容；接续已有会话或引用了已上传附件的请求只属于原账号，不重试
+func sendWithFailover(ctx context.Context, req failoverRequest, url string) (*resty.Response, error) {
+	for {
+		name, cookie := account.Current(ctx)
+		resp, err := utils.RestySSEClient.R().
+			SetContext(ctx).
+			SetHeader("cookie", cookie).
+			SetBody(req).
+			Post(url)
+		if err != nil {
+			return nil, err
+		}
+		if !req.Portable() {
+			account.Report(ctx, resp.StatusCode())
+			return resp, nil
+		}
+		if !account.Failover(ctx, name, resp.StatusCode()) {
+			return resp, nil
+		}
+		resp.RawBody().Close()
+		req.SetAccount(account.Name(ctx))
+	}
+}
+
 // SendMonicaRequest 发起对 Monica AI 的请求(使用 resty)
 func SendMonicaRequest(ctx context.Context, cfg *config.Config, mReq *types.MonicaRequest) (*resty.Response, error) {
-	// 发起请求
-	resp, err := utils.RestySSEClient.R().
-		SetContext(ctx).
-		SetHeader("cookie", cfg.Monica.Cookie).
-		SetBody(mReq).
-		Post(types.BotChatURL)
-
+	resp, err := sendWithFailover(ctx, mReq, types.BotChatURL)
 	if err != nil {
 		logger.Error("Monica API请求失败", zap.Error(err))
 		return nil, errors.NewRequestFailedError("Monica API调用失败", err)
@@ -32,13 +59,7 @@ func SendMonicaRequest(ctx context.Context, cfg *config.Config, mReq *types.Moni
 
 // SendCustomBotRequest 发送custom bot请求
 func SendCustomBotRequest(ctx context.Context, cfg *config.Config, customBotReq *types.CustomBotRequest) (*resty.Response, error) {
-	// 发起请求
-	resp, err := utils.RestySSEClient.R().
-		SetContext(ctx).
-		SetHeader("cookie", cfg.Monica.Cookie).
-		SetBody(customBotReq).
-		Post(types.CustomBotChatURL)
-
+	resp, err := sendWithFailover(ctx, customBotReq, types.CustomBotChatURL)
 	if err != nil {
 		logger.Error("Custom Bot API请求失败", zap.Error(err))
 		return nil, errors.NewRequestFailedError("Custom Bot API调用失败", err)
@@ -46,3 +67,24 @@ func SendCustomBotRequest(ctx context.Context, cfg *config.Config, customBotReq
 
 	return resp, nil
 }
+
+// CheckCookie 用指定的 Cookie 查询一个空的文件列表，作为账号健康检查的开销很小的上游请求，返回 HTTP 状态码
+func CheckCookie(ctx context.Context, cookie string) (int, error) {
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	resp, err := utils.RestyDefaultClient.R().
+		SetContext(ctx).
+		SetHeader("cookie", cookie).
+		SetBody(map[string][]string{"file_uids": {}}).
+		SetResult(&result).
+		Post(types.FileGetURL)
+	if err != nil {
+		return 0, err
+	}
+	if resp.IsSuccess() && result.Code != 0 {
+		return resp.StatusCode(), fmt.Errorf("code %d: %s", result.Code, result.Msg)
+	}
+	return resp.StatusCode(), nil
+}
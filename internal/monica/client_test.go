@@ -0,0 +1,79 @@
+package monica
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"monica-proxy/internal/account"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// fakeChatTransport 模拟对话接口，expired 中的 Cookie 返回 401，其余返回一条回复
+type fakeChatTransport struct {
+	expired map[string]bool
+	cookies []string
+}
+
+func (f *fakeChatTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cookie := req.Header.Get("cookie")
+	f.cookies = append(f.cookies, cookie)
+	status, body := http.StatusOK, `data: {"text":"ok","finished":true}`+"\n\n"
+	if f.expired[cookie] {
+		status, body = http.StatusUnauthorized, `{"code":401}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// TestSendFailover 测试账号失效时换用下一个账号重试，引用了已上传附件的请求不重试
+func TestSendFailover(t *testing.T) {
+	transport := &fakeChatTransport{expired: map[string]bool{"cookie-a": true}}
+	original := utils.RestySSEClient
+	t.Cleanup(func() { utils.RestySSEClient = original })
+	utils.RestySSEClient = resty.New().SetDoNotParseResponse(true).SetTransport(transport)
+
+	cfg := &config.Config{Monica: config.MonicaConfig{Accounts: []config.AccountConfig{
+		{Name: "a", Cookie: "cookie-a"},
+		{Name: "b", Cookie: "cookie-b"},
+	}}}
+	pool, err := account.NewPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, release := pool.WithLease(context.Background(), "")
+	defer release()
+	resp, err := SendMonicaRequest(ctx, cfg, types.NewTextRequest("gpt-4o", "hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.RawBody().Close()
+	if strings.Join(transport.cookies, ",") != "cookie-a,cookie-b" || account.Name(ctx) != "b" {
+		t.Errorf("应换用下一个账号重试: %v", transport.cookies)
+	}
+
+	// 附件只属于上传它的账号，不换号重试
+	transport.cookies = nil
+	transport.expired["cookie-b"] = true
+	req := types.NewTextRequest("gpt-4o", "hi")
+	req.Data.Items[1].Data.FileInfos = []types.FileInfo{{FileName: "a.pdf"}}
+	resp, err = SendMonicaRequest(ctx, cfg, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.RawBody().Close()
+	if resp.StatusCode() != http.StatusUnauthorized || len(transport.cookies) != 1 {
+		t.Errorf("引用附件的请求不应重试: %d %v", resp.StatusCode(), transport.cookies)
+	}
+}
@@ -3,53 +3,69 @@ package monica
 import (
 	"context"
 	"fmt"
+	"monica-proxy/internal/account"
 	"monica-proxy/internal/config"
 	"monica-proxy/internal/types"
 	"monica-proxy/internal/utils"
 	"time"
 
 	"github.com/bytedance/sonic"
-	"github.com/google/uuid"
 )
 
-// GenerateImage 使用 Monica 的文生图 API 生成图片
-func GenerateImage(ctx context.Context, cfg *config.Config, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
-	// 1. 参数验证和默认值设置
-	if req.Model == "" {
-		req.Model = "dall-e-3" // 默认使用 dall-e-3
-	}
-	if req.N <= 0 {
-		req.N = 1 // 默认生成1张图片
-	}
-	if req.Size == "" {
-		req.Size = "1024x1024" // 默认尺寸
+const (
+	imagePollMinInterval = time.Second      // 轮询的初始间隔
+	imagePollMaxInterval = 5 * time.Second  // 轮询间隔的上限
+	imageMinTimeout      = 30 * time.Second // 等待生成结果的最短时间，上游预估时间较短或缺失时使用
+)
+
+// ImageTask 已提交的 Monica image_tools 任务
+type ImageTask struct {
+	ID           int           // Monica 的 image_tools_id
+	Prompt       string        // 提示词，作为 revised_prompt 返回
+	ExpectedTime time.Duration // 上游预估的生成时间
+	SubmittedAt  time.Time
+}
+
+// Deadline 等待生成结果的截止时间，为预估时间的 2 倍，不少于 imageMinTimeout
+func (t *ImageTask) Deadline() time.Time {
+	return t.SubmittedAt.Add(max(2*t.ExpectedTime, imageMinTimeout))
+}
+
+// Progress 按已用时间与预估时间估算的进度（0-99），上游不提供实际进度
+func (t *ImageTask) Progress() int {
+	if t.ExpectedTime <= 0 {
+		return 0
 	}
+	return min(99, int(time.Since(t.SubmittedAt)*100/t.ExpectedTime))
+}
 
-	// 2. 转换尺寸为 Monica 的格式
-	aspectRatio := sizeToAspectRatio(req.Size)
-
-	// 3. 构建请求体
-	monicaReq := &types.MonicaImageRequest{
-		TaskUID:     uuid.New().String(),
-		ImageCount:  req.N,
-		Prompt:      req.Prompt,
-		ModelType:   "sdxl", // Monica 目前只支持 sdxl
-		AspectRatio: aspectRatio,
-		TaskType:    "text_to_image",
+// GenerateImage 使用 Monica 的 image_tools API 生成图片，提交任务后等待生成完成
+// 请求由 types.NewMonicaImageRequest 或 types.NewMonicaImageEditRequest 校验并转换
+func GenerateImage(ctx context.Context, cfg *config.Config, monicaReq *types.MonicaImageRequest) (*types.ImageGenerationResponse, error) {
+	task, err := SubmitImageTask(ctx, cfg, monicaReq)
+	if err != nil {
+		return nil, err
+	}
+	urls, err := WaitImageTask(ctx, cfg, task)
+	if err != nil {
+		return nil, err
 	}
+	return NewImageResponse(task.Prompt, urls), nil
+}
 
-	// 4. 发送请求生成图片
+// SubmitImageTask 按任务类型提交任务，立即返回 image_tools_id 与预估时间
+func SubmitImageTask(ctx context.Context, cfg *config.Config, monicaReq *types.MonicaImageRequest) (*ImageTask, error) {
 	resp, err := utils.RestyDefaultClient.R().
 		SetContext(ctx).
 		SetBody(monicaReq).
-		SetHeader("cookie", cfg.Monica.Cookie).
-		Post(types.ImageGenerateURL)
+		SetHeader("cookie", account.Cookie(ctx)).
+		Post(types.ImageToolsURL + monicaReq.TaskType)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to send image generation request: %v", err)
 	}
+	account.Report(ctx, resp.StatusCode())
 
-	// 5. 解析响应
 	var monicaResp struct {
 		Code int    `json:"code"`
 		Msg  string `json:"msg"`
@@ -67,83 +83,90 @@ func GenerateImage(ctx context.Context, cfg *config.Config, req *types.ImageGene
 		return nil, fmt.Errorf("image generation failed: %s", monicaResp.Msg)
 	}
 
-	// 6. 轮询获取生成结果
-	imageToolsID := monicaResp.Data.ImageToolsID
-	expectedTime := monicaResp.Data.ExpectedTime
+	return &ImageTask{
+		ID:           monicaResp.Data.ImageToolsID,
+		Prompt:       monicaReq.Prompt,
+		ExpectedTime: time.Duration(monicaResp.Data.ExpectedTime) * time.Second,
+		SubmittedAt:  time.Now(),
+	}, nil
+}
 
-	// 设置轮询超时时间为预期时间的2倍
-	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(expectedTime*2)*time.Second)
+// WaitImageTask 轮询任务直到生成完成，返回图片的 CDN 链接
+// 预估时间过半后开始轮询，间隔从 1 秒翻倍到 5 秒，等待期间响应 ctx 取消
+func WaitImageTask(ctx context.Context, cfg *config.Config, task *ImageTask) ([]string, error) {
+	ctx, cancel := context.WithDeadline(ctx, task.Deadline())
 	defer cancel()
 
-	var generatedImages []types.ImageGenerationData
+	timer := time.NewTimer(max(task.ExpectedTime/2, imagePollMinInterval))
+	defer timer.Stop()
+	interval := imagePollMinInterval
 	for {
 		select {
-		case <-timeoutCtx.Done():
-			return nil, fmt.Errorf("timeout waiting for image generation")
-		default:
-			var resultData struct {
-				Code int    `json:"code"`
-				Msg  string `json:"msg"`
-				Data struct {
-					Record struct {
-						Result struct {
-							CDNURLList []string `json:"cdn_url_list"`
-						} `json:"result"`
-					} `json:"record"`
-				} `json:"data"`
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, fmt.Errorf("timeout waiting for image generation")
 			}
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
 
-			// 查询生成结果
-			_, err := utils.RestyDefaultClient.R().
-				SetContext(ctx).
-				SetBody(map[string]any{
-					"image_tools_id": imageToolsID,
-				}).
-				SetHeader("cookie", cfg.Monica.Cookie).
-				SetResult(&resultData).
-				Post(types.ImageResultURL)
-
-			if err != nil {
-				return nil, fmt.Errorf("failed to get image generation result: %v", err)
-			}
+		urls, err := PollImageTask(ctx, cfg, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(urls) > 0 {
+			return urls, nil
+		}
+		timer.Reset(interval)
+		interval = min(interval*2, imagePollMaxInterval)
+	}
+}
 
-			if resultData.Code != 0 {
-				return nil, fmt.Errorf("failed to get image result: %s", resultData.Msg)
-			}
+// PollImageTask 查询一次任务结果，未完成时返回空列表
+func PollImageTask(ctx context.Context, cfg *config.Config, imageToolsID int) ([]string, error) {
+	var resultData struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			Record struct {
+				Result struct {
+					CDNURLList []string `json:"cdn_url_list"`
+				} `json:"result"`
+			} `json:"record"`
+		} `json:"data"`
+	}
 
-			// 检查是否有图片生成完成
-			if len(resultData.Data.Record.Result.CDNURLList) > 0 {
-				// 构建返回数据
-				for _, url := range resultData.Data.Record.Result.CDNURLList {
-					generatedImages = append(generatedImages, types.ImageGenerationData{
-						URL:           url,
-						RevisedPrompt: req.Prompt, // Monica 不提供修改后的提示词
-					})
-				}
-
-				// 返回结果
-				return &types.ImageGenerationResponse{
-					Created: time.Now().Unix(),
-					Data:    generatedImages,
-				}, nil
-			}
+	resp, err := utils.RestyDefaultClient.R().
+		SetContext(ctx).
+		SetBody(map[string]any{
+			"image_tools_id": imageToolsID,
+		}).
+		SetHeader("cookie", account.Cookie(ctx)).
+		SetResult(&resultData).
+		Post(types.ImageResultURL)
 
-			// 等待一段时间后继续轮询
-			time.Sleep(time.Second)
-		}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image generation result: %v", err)
+	}
+	account.Report(ctx, resp.StatusCode())
+
+	if resultData.Code != 0 {
+		return nil, fmt.Errorf("failed to get image result: %s", resultData.Msg)
 	}
+	return resultData.Data.Record.Result.CDNURLList, nil
 }
 
-// sizeToAspectRatio 将 OpenAI 的尺寸格式转换为 Monica 的宽高比格式
-func sizeToAspectRatio(size string) string {
-	switch size {
-	case "1024x1024":
-		return "1:1"
-	case "1792x1024":
-		return "16:9"
-	case "1024x1792":
-		return "9:16"
-	default:
-		return "1:1" // 默认使用1:1
+// NewImageResponse 构建图片生成响应
+func NewImageResponse(prompt string, urls []string) *types.ImageGenerationResponse {
+	images := make([]types.ImageGenerationData, 0, len(urls))
+	for _, url := range urls {
+		images = append(images, types.ImageGenerationData{
+			URL:           url,
+			RevisedPrompt: prompt, // Monica 不提供修改后的提示词
+		})
+	}
+	return &types.ImageGenerationResponse{
+		Created: time.Now().Unix(),
+		Data:    images,
 	}
 }
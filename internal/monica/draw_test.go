@@ -0,0 +1,97 @@
+package monica
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// fakeImageTransport 模拟文生图接口，第 ready 次查询时返回图片
+type fakeImageTransport struct {
+	ready int32
+	polls atomic.Int32
+}
+
+func (f *fakeImageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := `{"code":0,"data":{"image_tools_id":42,"expected_time":0}}`
+	if req.URL.String() == types.ImageResultURL {
+		body = `{"code":0,"data":{"record":{"result":{"cdn_url_list":[]}}}}`
+		if f.polls.Add(1) >= f.ready {
+			body = `{"code":0,"data":{"record":{"result":{"cdn_url_list":["https://cdn.example.com/a.png"]}}}}`
+		}
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func useFakeImageTransport(t *testing.T, ready int32) *fakeImageTransport {
+	original := utils.RestyDefaultClient
+	t.Cleanup(func() { utils.RestyDefaultClient = original })
+	transport := &fakeImageTransport{ready: ready}
+	utils.RestyDefaultClient = resty.New().SetTransport(transport)
+	return transport
+}
+
+// TestGenerateImage 测试提交任务后轮询到结果
+func TestGenerateImage(t *testing.T) {
+	transport := useFakeImageTransport(t, 2)
+
+	resp, err := GenerateImage(context.Background(), &config.Config{}, &types.MonicaImageRequest{Prompt: "cat", TaskType: types.ImageTaskTextToImage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].URL != "https://cdn.example.com/a.png" || resp.Data[0].RevisedPrompt != "cat" {
+		t.Errorf("生成结果错误: %+v", resp.Data)
+	}
+	if transport.polls.Load() != 2 {
+		t.Errorf("轮询次数错误: %d", transport.polls.Load())
+	}
+}
+
+// TestWaitImageTaskCancel 测试等待期间 ctx 取消时立即返回
+func TestWaitImageTaskCancel(t *testing.T) {
+	useFakeImageTransport(t, 100)
+
+	task := &ImageTask{ID: 42, ExpectedTime: time.Minute, SubmittedAt: time.Now()}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := WaitImageTask(ctx, &config.Config{}, task); err == nil {
+		t.Fatal("取消后未返回错误")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("取消后等待过久: %v", elapsed)
+	}
+}
+
+// TestImageTaskDeadline 测试截止时间与进度估算
+func TestImageTaskDeadline(t *testing.T) {
+	now := time.Now()
+	task := &ImageTask{ExpectedTime: 20 * time.Second, SubmittedAt: now.Add(-10 * time.Second)}
+	if got := task.Deadline(); !got.Equal(now.Add(30 * time.Second)) {
+		t.Errorf("截止时间错误: %v", got.Sub(now))
+	}
+	if p := task.Progress(); p < 49 || p > 51 {
+		t.Errorf("进度错误: %d", p)
+	}
+
+	task = &ImageTask{ExpectedTime: time.Minute, SubmittedAt: now}
+	if got := task.Deadline(); !got.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("截止时间错误: %v", got.Sub(now))
+	}
+}
@@ -0,0 +1,146 @@
+package monica
+
+import (
+	"bytes"
+	"io"
+
+	"monica-proxy/internal/toolcall"
+
+	"github.com/bytedance/sonic"
+	"github.com/sashabaranov/go-openai"
+)
+
+// CompletionOptions 请求级的响应转换选项，由服务层根据请求构造
+type CompletionOptions struct {
+	Model string
+
+	// Tools 是否从正文中解析工具调用
+	Tools bool
+	// ParallelToolCalls 是否允许一次返回多个工具调用
+	ParallelToolCalls bool
+
+	// Stop 停止序列，命中后截断正文并提前结束
+	Stop []string
+	// MaxTokens 正文的最大 token 数，超出后截断并以 length 结束
+	MaxTokens int
+
+	// PromptTokens 本地计算的输入 token 数，用于填充 usage
+	PromptTokens int
+	// IncludeUsage 流式响应结束前是否额外输出一条 usage chunk
+	IncludeUsage bool
+	// ReasoningMode 流式输出思考过程的方式，为空时按 reasoning_content 输出
+	ReasoningMode string
+	// OnReply 上游回复完整结束后回调，参数为上游返回的回复条目 ID（可能为空）与返回给客户端的正文，用于会话模式记录会话
+	OnReply func(itemID, text string)
+
+	// finish 回放已收集的回复时沿用原来的结束原因
+	finish *sseEvent
+}
+
+// NewCompletionOptions 根据 ChatCompletion 请求构造转换选项
+func NewCompletionOptions(req *openai.ChatCompletionRequest) CompletionOptions {
+	maxTokens := req.MaxCompletionTokens
+	if maxTokens <= 0 {
+		maxTokens = req.MaxTokens
+	}
+	var stops []string
+	for _, stop := range req.Stop {
+		if stop != "" {
+			stops = append(stops, stop)
+		}
+	}
+	return CompletionOptions{
+		Model:             req.Model,
+		Tools:             toolcall.Enabled(req),
+		ParallelToolCalls: toolcall.ParallelAllowed(req),
+		Stop:              stops,
+		MaxTokens:         maxTokens,
+		IncludeUsage:      req.Stream && req.StreamOptions != nil && req.StreamOptions.IncludeUsage,
+	}
+}
+
+// CompletionStream 上游 Monica SSE 响应体及其转换选项
+// n > 1 时 Body 为空，每个候选对应 Choices 中的一个独立上游流
+type CompletionStream struct {
+	Body    io.ReadCloser
+	Options CompletionOptions
+
+	// Choices 多候选的上游流，下标即候选序号
+	Choices []*CompletionStream
+	// AllowPartial 某个候选中途失败时是否继续输出其余候选
+	AllowPartial bool
+}
+
+// Close 关闭上游响应体
+func (s *CompletionStream) Close() error {
+	var firstErr error
+	if s.Body != nil {
+		firstErr = s.Body.Close()
+	}
+	for _, choice := range s.Choices {
+		if err := choice.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// SetReasoningMode 设置思考过程的输出方式，多候选时同时作用于每个候选
+func (s *CompletionStream) SetReasoningMode(mode string) {
+	s.Options.ReasoningMode = mode
+	for _, choice := range s.Choices {
+		choice.SetReasoningMode(mode)
+	}
+}
+
+// NewReplayStream 将已收集完成的回复重新编码为 Monica SSE，供需要先缓冲再输出的场景复用流式转换逻辑
+// 工具调用会渲染回 <tool_call> 块，由 Options 中的过滤器重新解析
+func NewReplayStream(completion *Completion, opts CompletionOptions) *CompletionStream {
+	var buf bytes.Buffer
+	writeEvent := func(data SSEData) {
+		payload, _ := sonic.Marshal(data)
+		buf.WriteString(dataPrefix)
+		buf.Write(payload)
+		buf.WriteString("\n\n")
+	}
+
+	if completion.Reasoning != "" {
+		var data SSEData
+		data.AgentStatus.Type = "thinking_detail_stream"
+		data.AgentStatus.Metadata.ReasoningDetail = completion.Reasoning
+		writeEvent(data)
+	}
+	if len(completion.Sources) > 0 {
+		var data SSEData
+		data.AgentStatus.Type = "web_search"
+		data.AgentStatus.Metadata.Sources = completion.Sources
+		writeEvent(data)
+	}
+	text := completion.Text
+	if len(completion.ToolCalls) > 0 {
+		if text != "" {
+			text += "\n\n"
+		}
+		text += toolcall.FormatCalls(completion.ToolCalls)
+	}
+	if text != "" {
+		writeEvent(SSEData{Text: text})
+	}
+	writeEvent(SSEData{Finished: true})
+
+	// 停止序列与长度限制在收集时已经生效，回放时不再重复截断；会话已在收集时记录
+	opts.Stop = nil
+	opts.OnReply = nil
+	opts.MaxTokens = 0
+	opts.PromptTokens = completion.Usage.PromptTokens
+	opts.finish = &sseEvent{
+		typ:          eventFinish,
+		finishReason: completion.FinishReason,
+		stopSequence: completion.StopSequence,
+		usage:        &completion.Usage,
+	}
+	return &CompletionStream{
+		Body:    io.NopCloser(&buf),
+		Options: opts,
+	}
+}
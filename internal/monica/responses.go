@@ -0,0 +1,378 @@
+package monica
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	responseStatusInProgress = "in_progress"
+	responseStatusCompleted  = "completed"
+	responseStatusIncomplete = "incomplete"
+)
+
+// finishResponse 根据结束原因与用量设置响应的最终状态，返回对应的终止事件类型
+func finishResponse(resp *types.Response, reason openai.FinishReason, usage *openai.Usage) string {
+	resp.Usage = &types.ResponseUsage{}
+	if usage != nil {
+		resp.Usage.InputTokens = usage.PromptTokens
+		resp.Usage.OutputTokens = usage.CompletionTokens
+		resp.Usage.TotalTokens = usage.TotalTokens
+		if usage.CompletionTokensDetails != nil {
+			resp.Usage.OutputTokensDetails.ReasoningTokens = usage.CompletionTokensDetails.ReasoningTokens
+		}
+	}
+	if reason == openai.FinishReasonLength {
+		resp.Status = responseStatusIncomplete
+		resp.IncompleteDetails = &types.ResponseIncomplete{Reason: "max_output_tokens"}
+		return "response.incomplete"
+	}
+	resp.Status = responseStatusCompleted
+	return "response.completed"
+}
+
+// newResponseItemID 生成 Responses 输出项ID
+func newResponseItemID(prefix string) string {
+	return prefix + "_" + utils.RandStringUsingMathRand(24)
+}
+
+// CollectMonicaSSEToResponse 将 Monica SSE 收集为完整的 Responses 输出，结果写入 resp
+func CollectMonicaSSEToResponse(ctx context.Context, resp *types.Response, stream *CompletionStream) error {
+	var reasoning, text strings.Builder
+	var toolCalls []openai.ToolCall
+	finishReason := openai.FinishReasonStop
+	var usage *openai.Usage
+
+	processor := &processMonicaSSE{
+		reader: bufio.NewReaderSize(stream.Body, bufferSize),
+		model:  resp.Model,
+		ctx:    ctx,
+	}
+	err := processor.processCompletionEvents(stream.Options, func(ev sseEvent) error {
+		switch ev.typ {
+		case eventReasoning:
+			reasoning.WriteString(ev.text)
+		case eventText:
+			text.WriteString(ev.text)
+		case eventToolCalls:
+			toolCalls = append(toolCalls, ev.toolCalls...)
+		case eventFinish:
+			finishReason = ev.finishReason
+			usage = ev.usage
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	resp.Output = resp.Output[:0]
+	if reasoning.Len() > 0 {
+		resp.Output = append(resp.Output, types.ResponseOutputItem{
+			Type:    "reasoning",
+			ID:      newResponseItemID("rs"),
+			Summary: []types.ResponseSummaryPart{{Type: "summary_text", Text: reasoning.String()}},
+		})
+	}
+	if text.Len() > 0 || len(toolCalls) == 0 {
+		resp.Output = append(resp.Output, newResponseMessageItem(text.String()))
+	}
+	for _, call := range toolCalls {
+		resp.Output = append(resp.Output, newResponseFunctionCallItem(call))
+	}
+	finishResponse(resp, finishReason, usage)
+	return nil
+}
+
+// newResponseMessageItem 构造已完成的 assistant 消息输出项
+func newResponseMessageItem(text string) types.ResponseOutputItem {
+	return types.ResponseOutputItem{
+		Type:   "message",
+		ID:     newResponseItemID("msg"),
+		Status: responseStatusCompleted,
+		Role:   "assistant",
+		Content: []types.ResponseOutputContent{{
+			Type:        "output_text",
+			Text:        text,
+			Annotations: []any{},
+		}},
+	}
+}
+
+// newResponseFunctionCallItem 构造已完成的 function_call 输出项
+func newResponseFunctionCallItem(call openai.ToolCall) types.ResponseOutputItem {
+	return types.ResponseOutputItem{
+		Type:      "function_call",
+		ID:        newResponseItemID("fc"),
+		Status:    responseStatusCompleted,
+		CallID:    call.ID,
+		Name:      call.Function.Name,
+		Arguments: call.Function.Arguments,
+	}
+}
+
+// responsesStream Responses 流式输出状态
+type responsesStream struct {
+	writer   *sseWriter
+	resp     *types.Response
+	sequence int
+
+	// 当前打开的输出项
+	item    *types.ResponseOutputItem
+	itemBuf strings.Builder
+}
+
+// emit 写入一条语义事件，自动补充 type 与 sequence_number
+func (s *responsesStream) emit(eventType string, fields map[string]any) error {
+	if fields == nil {
+		fields = make(map[string]any, 2)
+	}
+	fields["type"] = eventType
+	fields["sequence_number"] = s.sequence
+	s.sequence++
+	return s.writer.WriteEvent(eventType, fields)
+}
+
+// outputIndex 当前输出项的下标
+func (s *responsesStream) outputIndex() int {
+	return len(s.resp.Output)
+}
+
+// openItem 确保当前打开的输出项为指定类型，必要时关闭旧项并开启新项
+func (s *responsesStream) openItem(itemType string) error {
+	if s.item != nil && s.item.Type == itemType {
+		return nil
+	}
+	if err := s.closeItem(); err != nil {
+		return err
+	}
+
+	switch itemType {
+	case "reasoning":
+		s.item = &types.ResponseOutputItem{Type: "reasoning", ID: newResponseItemID("rs")}
+		if err := s.emit("response.output_item.added", map[string]any{
+			"output_index": s.outputIndex(),
+			"item":         s.item,
+		}); err != nil {
+			return err
+		}
+		return s.emit("response.reasoning_summary_part.added", map[string]any{
+			"item_id":       s.item.ID,
+			"output_index":  s.outputIndex(),
+			"summary_index": 0,
+			"part":          types.ResponseSummaryPart{Type: "summary_text"},
+		})
+	default:
+		s.item = &types.ResponseOutputItem{
+			Type:   "message",
+			ID:     newResponseItemID("msg"),
+			Status: responseStatusInProgress,
+			Role:   "assistant",
+		}
+		if err := s.emit("response.output_item.added", map[string]any{
+			"output_index": s.outputIndex(),
+			"item":         s.item,
+		}); err != nil {
+			return err
+		}
+		return s.emit("response.content_part.added", map[string]any{
+			"item_id":       s.item.ID,
+			"output_index":  s.outputIndex(),
+			"content_index": 0,
+			"part":          types.ResponseOutputContent{Type: "output_text", Annotations: []any{}},
+		})
+	}
+}
+
+// delta 向当前输出项写入增量
+func (s *responsesStream) delta(text string) error {
+	s.itemBuf.WriteString(text)
+	if s.item.Type == "reasoning" {
+		return s.emit("response.reasoning_summary_text.delta", map[string]any{
+			"item_id":       s.item.ID,
+			"output_index":  s.outputIndex(),
+			"summary_index": 0,
+			"delta":         text,
+		})
+	}
+	return s.emit("response.output_text.delta", map[string]any{
+		"item_id":       s.item.ID,
+		"output_index":  s.outputIndex(),
+		"content_index": 0,
+		"delta":         text,
+	})
+}
+
+// closeItem 关闭当前输出项并追加到响应输出
+func (s *responsesStream) closeItem() error {
+	if s.item == nil {
+		return nil
+	}
+	item := s.item
+	text := s.itemBuf.String()
+	s.item = nil
+	s.itemBuf.Reset()
+
+	if item.Type == "reasoning" {
+		part := types.ResponseSummaryPart{Type: "summary_text", Text: text}
+		item.Summary = []types.ResponseSummaryPart{part}
+		if err := s.emit("response.reasoning_summary_text.done", map[string]any{
+			"item_id":       item.ID,
+			"output_index":  s.outputIndex(),
+			"summary_index": 0,
+			"text":          text,
+		}); err != nil {
+			return err
+		}
+		if err := s.emit("response.reasoning_summary_part.done", map[string]any{
+			"item_id":       item.ID,
+			"output_index":  s.outputIndex(),
+			"summary_index": 0,
+			"part":          part,
+		}); err != nil {
+			return err
+		}
+	} else {
+		part := types.ResponseOutputContent{Type: "output_text", Text: text, Annotations: []any{}}
+		item.Status = responseStatusCompleted
+		item.Content = []types.ResponseOutputContent{part}
+		if err := s.emit("response.output_text.done", map[string]any{
+			"item_id":       item.ID,
+			"output_index":  s.outputIndex(),
+			"content_index": 0,
+			"text":          text,
+		}); err != nil {
+			return err
+		}
+		if err := s.emit("response.content_part.done", map[string]any{
+			"item_id":       item.ID,
+			"output_index":  s.outputIndex(),
+			"content_index": 0,
+			"part":          part,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := s.emit("response.output_item.done", map[string]any{
+		"output_index": s.outputIndex(),
+		"item":         item,
+	}); err != nil {
+		return err
+	}
+	s.resp.Output = append(s.resp.Output, *item)
+	return nil
+}
+
+// functionCall 输出一个完整的 function_call 输出项，参数以单个增量发送
+func (s *responsesStream) functionCall(call openai.ToolCall) error {
+	if err := s.closeItem(); err != nil {
+		return err
+	}
+
+	item := newResponseFunctionCallItem(call)
+	added := item
+	added.Status = responseStatusInProgress
+	added.Arguments = ""
+	if err := s.emit("response.output_item.added", map[string]any{
+		"output_index": s.outputIndex(),
+		"item":         added,
+	}); err != nil {
+		return err
+	}
+	if err := s.emit("response.function_call_arguments.delta", map[string]any{
+		"item_id":      item.ID,
+		"output_index": s.outputIndex(),
+		"delta":        item.Arguments,
+	}); err != nil {
+		return err
+	}
+	if err := s.emit("response.function_call_arguments.done", map[string]any{
+		"item_id":      item.ID,
+		"output_index": s.outputIndex(),
+		"arguments":    item.Arguments,
+	}); err != nil {
+		return err
+	}
+	if err := s.emit("response.output_item.done", map[string]any{
+		"output_index": s.outputIndex(),
+		"item":         item,
+	}); err != nil {
+		return err
+	}
+	s.resp.Output = append(s.resp.Output, item)
+	return nil
+}
+
+// StreamMonicaSSEToResponses 将 Monica SSE 转成 Responses API 语义事件流
+// 思考过程输出为 reasoning 摘要，正文输出为 output_text，工具调用输出为 function_call，流结束后 resp 即为完整响应
+func StreamMonicaSSEToResponses(ctx context.Context, resp *types.Response, w io.Writer, upstream *CompletionStream) error {
+	writer := newSSEWriter(w)
+	defer writer.Close()
+
+	stream := &responsesStream{writer: writer, resp: resp}
+
+	resp.Status = responseStatusInProgress
+	resp.Output = []types.ResponseOutputItem{}
+	if err := stream.emit("response.created", map[string]any{"response": resp}); err != nil {
+		return err
+	}
+	if err := stream.emit("response.in_progress", map[string]any{"response": resp}); err != nil {
+		return err
+	}
+	writer.Flush()
+
+	processor := &processMonicaSSE{
+		reader: bufio.NewReaderSize(upstream.Body, bufferSize),
+		model:  resp.Model,
+		ctx:    ctx,
+	}
+	return processor.processCompletionEvents(upstream.Options, func(ev sseEvent) error {
+		switch ev.typ {
+		case eventReasoning:
+			if err := stream.openItem("reasoning"); err != nil {
+				return err
+			}
+			return stream.delta(ev.text)
+		case eventText:
+			if err := stream.openItem("message"); err != nil {
+				return err
+			}
+			return stream.delta(ev.text)
+		case eventToolCalls:
+			for _, call := range ev.toolCalls {
+				if err := stream.functionCall(call); err != nil {
+					return err
+				}
+			}
+			return nil
+		case eventSources:
+			// 引用来源只在 ChatCompletion 中以 annotations 返回
+			return nil
+		}
+
+		// eventFinish：保证至少输出一条 message，然后结束响应
+		if stream.item == nil && len(resp.Output) == 0 {
+			if err := stream.openItem("message"); err != nil {
+				return err
+			}
+		}
+		if err := stream.closeItem(); err != nil {
+			return err
+		}
+
+		event := finishResponse(resp, ev.finishReason, ev.usage)
+		if err := stream.emit(event, map[string]any{"response": resp}); err != nil {
+			return err
+		}
+		writer.Flush()
+		return nil
+	})
+}
@@ -10,13 +10,17 @@ import (
 	"sync"
 	"time"
 
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/logger"
 	"monica-proxy/internal/types"
 	"monica-proxy/internal/utils"
 	"net/http"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/bytedance/sonic"
 	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
 )
 
 const (
@@ -34,6 +38,7 @@ const (
 type SSEData struct {
 	Text        string      `json:"text"`
 	Finished    bool        `json:"finished"`
+	ItemID      string      `json:"item_id,omitempty"` // 回复条目的 ID，会话模式下一轮请求接在它之后
 	AgentStatus AgentStatus `json:"agent_status,omitempty"`
 }
 
@@ -44,6 +49,10 @@ type AgentStatus struct {
 	Metadata struct {
 		Title           string `json:"title"`
 		ReasoningDetail string `json:"reasoning_detail"`
+		// 联网搜索的来源，不同模型使用的字段名不同
+		Sources       SourceList `json:"sources,omitempty"`
+		SearchResults SourceList `json:"search_results,omitempty"`
+		References    SourceList `json:"references,omitempty"`
 	} `json:"metadata"`
 }
 
@@ -141,218 +150,523 @@ func (p *processMonicaSSE) processSSEStream(handler handleSSEData) error {
 	}
 }
 
-// CollectMonicaSSEToCompletion 将 Monica SSE 转换为完整的 ChatCompletion 响应
-func CollectMonicaSSEToCompletion(model string, r io.Reader) (*openai.ChatCompletionResponse, error) {
-	ctx := context.Background()
-	
+// eventType Monica SSE 语义事件类型
+type eventType int
+
+const (
+	eventText      eventType = iota // 正文增量
+	eventReasoning                  // 思考过程增量
+	eventToolCalls                  // 从正文中解析出的工具调用
+	eventSources                    // 联网搜索的来源
+	eventFinish                     // 回复结束
+)
+
+// sseEvent 从 Monica SSE 数据中提取出的语义事件，与下游输出协议无关
+type sseEvent struct {
+	typ          eventType
+	text         string
+	toolCalls    []openai.ToolCall
+	sources      []Source
+	finishReason openai.FinishReason
+	stopSequence string        // 因停止序列结束时命中的序列
+	usage        *openai.Usage // 结束事件携带的本地 token 统计
+	itemID       string        // 结束事件携带的上游回复条目 ID，上游未返回时为空
+}
+
+// processEventStream 将 Monica SSE 数据归一化为语义事件
+// thinking_detail_stream 转为思考增量，带来源列表的 agent_status（搜索、引用等）转为来源事件，其余 agent_status 忽略
+// 上游未发送 finished 时在流结束后补发结束事件
+func (p *processMonicaSSE) processEventStream(handler func(sseEvent) error) error {
+	var finished bool
+	var itemID string
+	err := p.processSSEStream(func(sseData *SSEData) error {
+		if sseData.ItemID != "" {
+			itemID = sseData.ItemID
+		}
+		switch {
+		case sseData.AgentStatus.Type == "thinking_detail_stream":
+			if sseData.AgentStatus.Metadata.ReasoningDetail == "" {
+				return nil
+			}
+			return handler(sseEvent{typ: eventReasoning, text: sseData.AgentStatus.Metadata.ReasoningDetail})
+		case sseData.AgentStatus.Type != "":
+			metadata := sseData.AgentStatus.Metadata
+			sources := mergeSources(nil, metadata.Sources...)
+			sources = mergeSources(sources, metadata.SearchResults...)
+			sources = mergeSources(sources, metadata.References...)
+			if len(sources) == 0 {
+				return nil
+			}
+			return handler(sseEvent{typ: eventSources, sources: sources})
+		}
+
+		if sseData.Text != "" {
+			if err := handler(sseEvent{typ: eventText, text: sseData.Text}); err != nil {
+				return err
+			}
+		}
+		if sseData.Finished && !finished {
+			finished = true
+			return handler(sseEvent{typ: eventFinish, finishReason: openai.FinishReasonStop, itemID: itemID})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !finished {
+		return handler(sseEvent{typ: eventFinish, finishReason: openai.FinishReasonStop, itemID: itemID})
+	}
+	return nil
+}
+
+// processCompletionEvents 在语义事件之上按请求选项叠加后处理
+// 原始正文依次经过停止序列、max_tokens 截断、用量统计与工具调用解析，本地截断后提前结束读取
+func (p *processMonicaSSE) processCompletionEvents(opts CompletionOptions, handler func(sseEvent) error) error {
+	if opts.OnReply != nil {
+		handler = withReply(opts.OnReply, handler)
+	}
+	if opts.finish != nil {
+		handler = withFinish(*opts.finish, handler)
+	}
+	if opts.Tools {
+		handler = withToolCalls(opts.ParallelToolCalls, handler)
+	}
+	handler = withUsage(opts.Model, opts.PromptTokens, handler)
+	if opts.MaxTokens > 0 {
+		handler = withMaxTokens(opts.Model, opts.MaxTokens, handler)
+	}
+	if len(opts.Stop) > 0 {
+		handler = withStop(opts.Stop, handler)
+	}
+
+	err := p.processEventStream(handler)
+	if errors.Is(err, errStopped) {
+		return nil
+	}
+	return err
+}
+
+// withReply 收集返回给客户端的正文，上游回复完整结束后回调 onReply
+// 因停止序列或 max_tokens 在本地截断时 Monica 保存的回复与客户端看到的不一致，不回调
+func withReply(onReply func(itemID, text string), next func(sseEvent) error) func(sseEvent) error {
+	var text strings.Builder
+	return func(ev sseEvent) error {
+		if ev.typ == eventText {
+			text.WriteString(ev.text)
+		}
+		if err := next(ev); err != nil {
+			return err
+		}
+		if ev.typ == eventFinish && ev.stopSequence == "" && ev.finishReason != openai.FinishReasonLength {
+			onReply(ev.itemID, text.String())
+		}
+		return nil
+	}
+}
+
+// sseWriter 带缓冲的 SSE 写入器，后台定时将缓冲区推送给客户端
+// 写入与定时刷新共用同一把锁，避免并发操作 bufio.Writer
+type sseWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	writer *bufio.Writer
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+// newSSEWriter 创建 SSE 写入器并启动定时刷新
+func newSSEWriter(w io.Writer) *sseWriter {
+	sw := &sseWriter{
+		w:      w,
+		writer: bufio.NewWriterSize(w, bufferSize),
+		ticker: time.NewTicker(flushInterval),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		for {
+			select {
+			case <-sw.ticker.C:
+				sw.Flush()
+			case <-sw.done:
+				return
+			}
+		}
+	}()
+
+	return sw
+}
+
+// WriteString 写入字符串到缓冲区
+func (sw *sseWriter) WriteString(s string) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if _, err := sw.writer.WriteString(s); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	return nil
+}
+
+// WriteEvent 写入一条 SSE 消息，event 为空时只写 data 行
+func (sw *sseWriter) WriteEvent(event string, data any) error {
+	payload, err := sonic.MarshalString(data)
+	if err != nil {
+		return fmt.Errorf("marshal error: %w", err)
+	}
+
+	sb := stringBuilderPool.Get().(*strings.Builder)
+	defer func() {
+		sb.Reset()
+		stringBuilderPool.Put(sb)
+	}()
+	if event != "" {
+		sb.WriteString("event: ")
+		sb.WriteString(event)
+		sb.WriteString("\n")
+	}
+	sb.WriteString(dataPrefix)
+	sb.WriteString(payload)
+	sb.WriteString(lineEnd)
+	return sw.WriteString(sb.String())
+}
+
+// Flush 将缓冲区内容推送给客户端
+func (sw *sseWriter) Flush() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.writer.Flush()
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close 停止定时刷新并推送剩余内容
+func (sw *sseWriter) Close() {
+	sw.ticker.Stop()
+	close(sw.done)
+	sw.Flush()
+}
+
+// Completion 收集完成的一次回复
+type Completion struct {
+	Text         string
+	Reasoning    string
+	ToolCalls    []openai.ToolCall
+	Sources      []Source
+	FinishReason openai.FinishReason
+	StopSequence string
+	Usage        openai.Usage
+}
+
+// CollectCompletion 读取完整的上游 SSE 并经过请求级过滤后收集为 Completion
+func CollectCompletion(ctx context.Context, stream *CompletionStream) (*Completion, error) {
 	// 从池中获取字符串构建器
 	fullContentBuilder := stringBuilderPool.Get().(*strings.Builder)
 	defer func() {
 		fullContentBuilder.Reset()
 		stringBuilderPool.Put(fullContentBuilder)
 	}()
-	
+
 	processor := &processMonicaSSE{
-		reader: bufio.NewReaderSize(r, bufferSize),
-		model:  model,
+		reader: bufio.NewReaderSize(stream.Body, bufferSize),
+		model:  stream.Options.Model,
 		ctx:    ctx,
 	}
 
-	// 处理SSE数据
-	err := processor.processSSEStream(func(sseData *SSEData) error {
-		// 如果是 agent_status，跳过
-		if sseData.AgentStatus.Type != "" {
-			return nil
+	var reasoning strings.Builder
+	completion := &Completion{FinishReason: openai.FinishReasonStop}
+	err := processor.processCompletionEvents(stream.Options, func(ev sseEvent) error {
+		switch ev.typ {
+		case eventText:
+			// 累积内容
+			fullContentBuilder.WriteString(ev.text)
+		case eventReasoning:
+			reasoning.WriteString(ev.text)
+		case eventToolCalls:
+			completion.ToolCalls = append(completion.ToolCalls, ev.toolCalls...)
+		case eventSources:
+			completion.Sources = mergeSources(completion.Sources, ev.sources...)
+		case eventFinish:
+			completion.FinishReason = ev.finishReason
+			completion.StopSequence = ev.stopSequence
+			if ev.usage != nil {
+				completion.Usage = *ev.usage
+			}
 		}
-		// 累积内容
-		fullContentBuilder.WriteString(sseData.Text)
 		return nil
 	})
-
 	if err != nil {
 		return nil, err
 	}
 
-	// 构造完整的响应
-	response := &openai.ChatCompletionResponse{
+	completion.Text = fullContentBuilder.String()
+	completion.Reasoning = reasoning.String()
+	return completion, nil
+}
+
+// NewCompletionResponse 根据收集完成的回复构造 ChatCompletion 响应，每个回复对应一个候选
+func NewCompletionResponse(model string, completions ...*Completion) *types.ChatCompletionResponse {
+	choices := make([]types.ChatCompletionChoice, 0, len(completions))
+	usages := make([]openai.Usage, 0, len(completions))
+	for i, completion := range completions {
+		usages = append(usages, completion.Usage)
+		choices = append(choices, types.ChatCompletionChoice{
+			Index: i,
+			Message: types.ChatCompletionMessage{
+				ChatCompletionMessage: openai.ChatCompletionMessage{
+					Role:             "assistant",
+					Content:          completion.Text,
+					ReasoningContent: completion.Reasoning,
+					ToolCalls:        completion.ToolCalls,
+				},
+				Annotations: urlCitations(completion.Text, completion.Sources, 0),
+			},
+			FinishReason: completion.FinishReason,
+		})
+	}
+
+	return &types.ChatCompletionResponse{
 		ID:      fmt.Sprintf("chatcmpl-%s", utils.RandStringUsingMathRand(29)),
 		Object:  "chat.completion",
 		Created: time.Now().Unix(),
 		Model:   model,
-		Choices: []openai.ChatCompletionChoice{
+		Choices: choices,
+		Usage:   SumUsage(usages...),
+	}
+}
+
+// ApplyReasoningMode 按输出方式调整非流式响应中的思考过程
+// NewCompletionResponse 默认通过 reasoning_content 返回，tags 模式移入 content，hidden 模式移除
+func ApplyReasoningMode(resp *types.ChatCompletionResponse, mode string) {
+	for i := range resp.Choices {
+		message := &resp.Choices[i].Message
+		if message.ReasoningContent == "" {
+			continue
+		}
+		switch mode {
+		case config.ReasoningModeTags:
+			think := "<think>" + message.ReasoningContent + "</think>"
+			message.Content = think + message.Content
+			message.ReasoningContent = ""
+			// 引用位置随正文一起后移
+			shift := utf8.RuneCountInString(think)
+			for _, annotation := range message.Annotations {
+				annotation.URLCitation.StartIndex += shift
+				annotation.URLCitation.EndIndex += shift
+			}
+		case config.ReasoningModeHidden:
+			message.ReasoningContent = ""
+		}
+	}
+}
+
+// CollectMonicaSSEToCompletion 将 Monica SSE 转换为完整的 ChatCompletion 响应
+func CollectMonicaSSEToCompletion(ctx context.Context, stream *CompletionStream) (*types.ChatCompletionResponse, error) {
+	completion, err := CollectCompletion(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+	return NewCompletionResponse(stream.Options.Model, completion), nil
+}
+
+// chunkWriter 按 ChatCompletion chunk 格式写出某个候选的增量
+type chunkWriter struct {
+	writer      *sseWriter
+	id          string
+	created     int64
+	model       string
+	fingerprint string
+	index       int
+	usage       openai.Usage // 候选结束时记录的用量
+}
+
+// write 写出一条 chunk
+func (cw *chunkWriter) write(delta openai.ChatCompletionStreamChoiceDelta, finishReason openai.FinishReason) error {
+	return cw.writeDelta(types.ChatCompletionStreamChoiceDelta{ChatCompletionStreamChoiceDelta: delta}, finishReason)
+}
+
+// writeDelta 写出一条可携带引用标注的 chunk
+func (cw *chunkWriter) writeDelta(delta types.ChatCompletionStreamChoiceDelta, finishReason openai.FinishReason) error {
+	delta.Role = openai.ChatMessageRoleAssistant
+	return cw.writer.WriteEvent("", types.ChatCompletionStreamResponse{
+		ID:                cw.id,
+		Object:            sseObject,
+		SystemFingerprint: cw.fingerprint,
+		Created:           cw.created,
+		Model:             cw.model,
+		Choices: []types.ChatCompletionStreamChoice{
 			{
-				Index: 0,
-				Message: openai.ChatCompletionMessage{
-					Role:    "assistant",
-					Content: fullContentBuilder.String(),
-				},
-				FinishReason: "stop",
+				Index:        cw.index,
+				Delta:        delta,
+				FinishReason: finishReason,
 			},
 		},
-		Usage: openai.Usage{
-			// Monica API 不提供 token 使用信息，这里暂时填 0
-			PromptTokens:     0,
-			CompletionTokens: 0,
-			TotalTokens:      0,
-		},
-	}
-
-	return response, nil
+	})
 }
 
 // StreamMonicaSSEToClient 将 Monica SSE 转成前端可用的流
-func StreamMonicaSSEToClient(model string, w io.Writer, r io.Reader) error {
-	ctx := context.Background()
-	writer := bufio.NewWriterSize(w, bufferSize)
-	defer writer.Flush()
+// 多候选时并发读取各上游流，chunk 按到达顺序交错输出并带上各自的候选序号
+func StreamMonicaSSEToClient(ctx context.Context, w io.Writer, stream *CompletionStream) error {
+	writer := newSSEWriter(w)
+	defer writer.Close()
+
+	chunks := chunkWriter{
+		writer:      writer,
+		id:          "chatcmpl-" + utils.RandStringUsingMathRand(29),
+		created:     time.Now().Unix(),
+		model:       stream.Options.Model,
+		fingerprint: utils.RandStringUsingMathRand(10),
+	}
 
-	chatId := utils.RandStringUsingMathRand(29)
-	now := time.Now().Unix()
-	fingerprint := utils.RandStringUsingMathRand(10)
+	var usage openai.Usage
+	if len(stream.Choices) == 0 {
+		if err := streamChoice(ctx, &chunks, stream); err != nil {
+			return err
+		}
+		usage = chunks.usage
+	} else {
+		usages, err := streamChoices(ctx, chunks, stream)
+		if err != nil {
+			return err
+		}
+		usage = SumUsage(usages...)
+	}
 
-	// 创建一个定时刷新的 ticker
-	ticker := time.NewTicker(flushInterval)
-	defer ticker.Stop()
+	// stream_options.include_usage：最后一条 chunk 不带候选，只携带整个请求的用量
+	if stream.Options.IncludeUsage {
+		if err := writer.WriteEvent("", types.ChatCompletionStreamResponse{
+			ID:                chunks.id,
+			Object:            sseObject,
+			SystemFingerprint: chunks.fingerprint,
+			Created:           chunks.created,
+			Model:             chunks.model,
+			Choices:           []types.ChatCompletionStreamChoice{},
+			Usage:             &usage,
+		}); err != nil {
+			return err
+		}
+	}
 
-	// 创建一个 done channel 用于清理
-	done := make(chan struct{})
-	defer close(done)
+	if err := writer.WriteString(dataPrefix + sseFinish + lineEnd); err != nil {
+		return err
+	}
+	writer.Flush()
+	return nil
+}
 
-	// 启动一个 goroutine 定期刷新缓冲区
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				if f, ok := w.(http.Flusher); ok {
-					writer.Flush()
-					f.Flush()
-				}
-			case <-done:
+// streamChoices 并发输出多个候选
+// 不允许部分失败时，任一候选出错会关闭其余上游流并返回该错误
+// 返回各候选的用量，失败的候选不计入
+func streamChoices(ctx context.Context, base chunkWriter, stream *CompletionStream) ([]openai.Usage, error) {
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	usages := make([]openai.Usage, len(stream.Choices))
+
+	for i, choice := range stream.Choices {
+		chunks := base
+		chunks.index = i
+		wg.Add(1)
+		go func(choice *CompletionStream) {
+			defer wg.Done()
+			err := streamChoice(ctx, &chunks, choice)
+			if err == nil {
+				usages[chunks.index] = chunks.usage
 				return
 			}
-		}
-	}()
+			if stream.AllowPartial {
+				logger.Warn("候选输出失败，继续输出其余候选", zap.Int("index", chunks.index), zap.Error(err))
+				return
+			}
+			once.Do(func() {
+				firstErr = err
+				stream.Close()
+			})
+		}(choice)
+	}
+	wg.Wait()
+	return usages, firstErr
+}
 
+// streamChoice 输出单个候选的全部 chunk，不写结束标记
+func streamChoice(ctx context.Context, chunks *chunkWriter, stream *CompletionStream) error {
 	processor := &processMonicaSSE{
-		reader: bufio.NewReaderSize(r, bufferSize),
-		model:  model,
+		reader: bufio.NewReaderSize(stream.Body, bufferSize),
+		model:  stream.Options.Model,
 		ctx:    ctx,
 	}
 
+	// tags 模式下思考过程以 <think></think> 包裹输出到 content 中
+	mode := stream.Options.ReasoningMode
 	var thinkFlag bool
-	return processor.processSSEStream(func(sseData *SSEData) error {
-		var sseMsg types.ChatCompletionStreamResponse
-		switch {
-		case sseData.Finished:
-			sseMsg = types.ChatCompletionStreamResponse{
-				ID:      "chatcmpl-" + chatId,
-				Object:  sseObject,
-				Created: now,
-				Model:   model,
-				Choices: []types.ChatCompletionStreamChoice{
-					{
-						Index: 0,
-						Delta: openai.ChatCompletionStreamChoiceDelta{
-							Role: openai.ChatMessageRoleAssistant,
-						},
-						FinishReason: openai.FinishReasonStop,
-					},
-				},
-			}
-		case sseData.AgentStatus.Type == "thinking":
-			thinkFlag = true
-			sseMsg = types.ChatCompletionStreamResponse{
-				ID:                "chatcmpl-" + chatId,
-				Object:            sseObject,
-				SystemFingerprint: fingerprint,
-				Created:           now,
-				Model:             model,
-				Choices: []types.ChatCompletionStreamChoice{
-					{
-						Index: 0,
-						Delta: openai.ChatCompletionStreamChoiceDelta{
-							Role:    openai.ChatMessageRoleAssistant,
-							Content: `<think>`,
-						},
-						FinishReason: openai.FinishReasonNull,
-					},
-				},
-			}
-		case sseData.AgentStatus.Type == "thinking_detail_stream":
-			sseMsg = types.ChatCompletionStreamResponse{
-				ID:                "chatcmpl-" + chatId,
-				Object:            sseObject,
-				SystemFingerprint: fingerprint,
-				Created:           now,
-				Model:             model,
-				Choices: []types.ChatCompletionStreamChoice{
-					{
-						Index: 0,
-						Delta: openai.ChatCompletionStreamChoiceDelta{
-							Role:    openai.ChatMessageRoleAssistant,
-							Content: sseData.AgentStatus.Metadata.ReasoningDetail,
-						},
-						FinishReason: openai.FinishReasonNull,
-					},
-				},
-			}
-		default:
-			if thinkFlag {
-				sseData.Text = "</think>" + sseData.Text
-				thinkFlag = false
-			}
-			sseMsg = types.ChatCompletionStreamResponse{
-				ID:                "chatcmpl-" + chatId,
-				Object:            sseObject,
-				SystemFingerprint: fingerprint,
-				Created:           now,
-				Model:             model,
-				Choices: []types.ChatCompletionStreamChoice{
-					{
-						Index: 0,
-						Delta: openai.ChatCompletionStreamChoiceDelta{
-							Role:    openai.ChatMessageRoleAssistant,
-							Content: sseData.Text,
-						},
-						FinishReason: openai.FinishReasonNull,
-					},
-				},
-			}
+	closeThink := func(text string) string {
+		if thinkFlag {
+			thinkFlag = false
+			return "</think>" + text
 		}
+		return text
+	}
 
-		// 从池中获取字符串构建器
-		sb := stringBuilderPool.Get().(*strings.Builder)
-		sb.WriteString("data: ")
-		sendLine, _ := sonic.MarshalString(sseMsg)
-		sb.WriteString(sendLine)
-		sb.WriteString("\n\n")
-
-		// 写入缓冲区
-		if _, err := writer.WriteString(sb.String()); err != nil {
-			// 归还字符串构建器到池中
-			sb.Reset()
-			stringBuilderPool.Put(sb)
-			return fmt.Errorf("write error: %w", err)
-		}
-		
-		// 使用完毕，归还字符串构建器到池中
-		sb.Reset()
-		stringBuilderPool.Put(sb)
+	// 引用标注在结束前一次性输出，需要记录已输出的 content 以计算引用位置
+	// tags 模式下 content 以思考过程开头，引用标记只在正文 answer 中查找，位置再后移思考过程的长度
+	var content, answer strings.Builder
+	var sources []Source
+	writeContent := func(delta openai.ChatCompletionStreamChoiceDelta) error {
+		content.WriteString(delta.Content)
+		return chunks.write(delta, openai.FinishReasonNull)
+	}
 
-		// 如果发现 finished=true，就可以结束
-		if sseData.Finished {
-			writer.WriteString(dataPrefix)
-			writer.WriteString(sseFinish)
-			writer.WriteString(lineEnd)
-			writer.Flush()
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
-			}
+	return processor.processCompletionEvents(stream.Options, func(ev sseEvent) error {
+		switch ev.typ {
+		case eventSources:
+			sources = mergeSources(sources, ev.sources...)
 			return nil
+		case eventReasoning:
+			switch mode {
+			case config.ReasoningModeHidden:
+				return nil
+			case config.ReasoningModeTags:
+				text := ev.text
+				if !thinkFlag {
+					thinkFlag = true
+					text = "<think>" + text
+				}
+				return writeContent(openai.ChatCompletionStreamChoiceDelta{Content: text})
+			default:
+				return chunks.write(openai.ChatCompletionStreamChoiceDelta{ReasoningContent: ev.text}, openai.FinishReasonNull)
+			}
+		case eventText:
+			answer.WriteString(ev.text)
+			return writeContent(openai.ChatCompletionStreamChoiceDelta{Content: closeThink(ev.text)})
+		case eventToolCalls:
+			return writeContent(openai.ChatCompletionStreamChoiceDelta{
+				Content:   closeThink(""),
+				ToolCalls: ev.toolCalls,
+			})
 		}
 
-		sseData.AgentStatus.Type = ""
-		sseData.Finished = false
+		// eventFinish
+		if ev.usage != nil {
+			chunks.usage = *ev.usage
+		}
+		if thinkFlag {
+			if err := writeContent(openai.ChatCompletionStreamChoiceDelta{Content: closeThink("")}); err != nil {
+				return err
+			}
+		}
+		if len(sources) > 0 {
+			offset := utf8.RuneCountInString(content.String()) - utf8.RuneCountInString(answer.String())
+			if err := chunks.writeDelta(types.ChatCompletionStreamChoiceDelta{
+				Annotations: urlCitations(answer.String(), sources, offset),
+			}, openai.FinishReasonNull); err != nil {
+				return err
+			}
+		}
+		if err := chunks.write(openai.ChatCompletionStreamChoiceDelta{}, ev.finishReason); err != nil {
+			return err
+		}
+		chunks.writer.Flush()
 		return nil
 	})
 }
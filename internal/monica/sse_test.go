@@ -0,0 +1,111 @@
+package monica
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/types"
+)
+
+// fakeReasoningSSE 构造先输出思考过程再输出正文的 Monica SSE 响应体
+func fakeReasoningSSE() io.ReadCloser {
+	return io.NopCloser(strings.NewReader(
+		`data: {"agent_status":{"type":"thinking_detail_stream","metadata":{"reasoning_detail":"think"}}}` + "\n\n" +
+			`data: {"text":"answer"}` + "\n\n" +
+			`data: {"text":"","finished":true}` + "\n\n"))
+}
+
+// TestStreamReasoningMode 测试流式输出中思考过程的三种输出方式
+func TestStreamReasoningMode(t *testing.T) {
+	tests := map[string]struct {
+		contains, excludes string
+	}{
+		config.ReasoningModeContent: {contains: `"reasoning_content":"think"`, excludes: "<think>"},
+		config.ReasoningModeTags:    {contains: `"content":"\u003cthink\u003ethink"`, excludes: "reasoning_content"},
+		config.ReasoningModeHidden:  {excludes: "think"},
+	}
+	for mode, tt := range tests {
+		stream := &CompletionStream{Body: fakeReasoningSSE(), Options: CompletionOptions{Model: "deepseek-reasoner"}}
+		stream.SetReasoningMode(mode)
+
+		var buf bytes.Buffer
+		if err := StreamMonicaSSEToClient(context.Background(), &buf, stream); err != nil {
+			t.Fatal(err)
+		}
+		out := strings.ReplaceAll(buf.String(), "<think>", `\u003cthink\u003e`)
+		if tt.contains != "" && !strings.Contains(out, tt.contains) {
+			t.Errorf("%s 模式输出缺少 %s:\n%s", mode, tt.contains, out)
+		}
+		if strings.Contains(out, tt.excludes) {
+			t.Errorf("%s 模式输出不应包含 %s:\n%s", mode, tt.excludes, out)
+		}
+		if !strings.Contains(out, `answer"`) {
+			t.Errorf("%s 模式输出缺少正文:\n%s", mode, out)
+		}
+	}
+}
+
+// TestApplyReasoningMode 测试非流式响应中思考过程的输出方式
+func TestApplyReasoningMode(t *testing.T) {
+	newResponse := func() *types.ChatCompletionResponse {
+		completion, err := CollectCompletion(context.Background(), &CompletionStream{
+			Body:    fakeReasoningSSE(),
+			Options: CompletionOptions{Model: "deepseek-reasoner"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewCompletionResponse("deepseek-reasoner", completion)
+	}
+
+	resp := newResponse()
+	ApplyReasoningMode(resp, config.ReasoningModeContent)
+	if msg := resp.Choices[0].Message; msg.ReasoningContent != "think" || msg.Content != "answer" {
+		t.Errorf("reasoning_content 模式结果错误: %+v", msg)
+	}
+
+	resp = newResponse()
+	ApplyReasoningMode(resp, config.ReasoningModeTags)
+	if msg := resp.Choices[0].Message; msg.ReasoningContent != "" || msg.Content != "<think>think</think>answer" {
+		t.Errorf("tags 模式结果错误: %+v", msg)
+	}
+
+	resp = newResponse()
+	ApplyReasoningMode(resp, config.ReasoningModeHidden)
+	if msg := resp.Choices[0].Message; msg.ReasoningContent != "" || msg.Content != "answer" {
+		t.Errorf("hidden 模式结果错误: %+v", msg)
+	}
+}
+
+// TestOnReply 测试回复完整结束时回调上游的回复条目 ID 与正文，本地截断时不回调
+func TestOnReply(t *testing.T) {
+	body := `data: {"text":"Hello ","item_id":"msg:reply"}` + "\n\n" +
+		`data: {"text":"world END more"}` + "\n\n" +
+		`data: {"text":"","finished":true}` + "\n\n"
+	for _, stop := range []string{"", "END"} {
+		var calls []string
+		stream := &CompletionStream{
+			Body: io.NopCloser(strings.NewReader(body)),
+			Options: CompletionOptions{Model: "gpt-4o", Stop: []string{stop}, OnReply: func(itemID, text string) {
+				calls = append(calls, itemID+"|"+text)
+			}},
+		}
+		if stop == "" {
+			stream.Options.Stop = nil
+		}
+		if err := StreamMonicaSSEToClient(context.Background(), io.Discard, stream); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"msg:reply|Hello world END more"}
+		if stop != "" {
+			want = nil
+		}
+		if strings.Join(calls, ",") != strings.Join(want, ",") {
+			t.Errorf("stop=%q 时回调错误: %q", stop, calls)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package monica
+
+import (
+	"errors"
+	"strings"
+
+	"monica-proxy/internal/tokenizer"
+	"monica-proxy/internal/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// errStopped 本地截断后中止读取上游，调用方关闭响应体即可提前断开 Monica 请求
+var errStopped = errors.New("completion stopped")
+
+// withStop 包装事件处理函数，遇到停止序列时截断正文并提前结束
+// 可能构成停止序列前缀的尾部文本会暂存，直到能确定是否命中，因此可以跨 chunk 匹配
+func withStop(stops []string, next func(sseEvent) error) func(sseEvent) error {
+	var pending string
+	return func(ev sseEvent) error {
+		switch ev.typ {
+		case eventText:
+			pending += ev.text
+			if idx, stop := firstStop(pending, stops); idx >= 0 {
+				if text := pending[:idx]; text != "" {
+					if err := next(sseEvent{typ: eventText, text: text}); err != nil {
+						return err
+					}
+				}
+				pending = ""
+				if err := next(sseEvent{typ: eventFinish, finishReason: openai.FinishReasonStop, stopSequence: stop}); err != nil {
+					return err
+				}
+				return errStopped
+			}
+
+			keep := 0
+			for _, stop := range stops {
+				keep = max(keep, utils.PartialSuffixLen(pending, stop))
+			}
+			text := pending[:len(pending)-keep]
+			pending = pending[len(pending)-keep:]
+			if text == "" {
+				return nil
+			}
+			return next(sseEvent{typ: eventText, text: text})
+		case eventFinish:
+			if pending != "" {
+				if err := next(sseEvent{typ: eventText, text: pending}); err != nil {
+					return err
+				}
+				pending = ""
+			}
+		}
+		return next(ev)
+	}
+}
+
+// firstStop 查找最早出现的停止序列，返回位置与命中的序列
+func firstStop(text string, stops []string) (int, string) {
+	idx, matched := -1, ""
+	for _, stop := range stops {
+		if i := strings.Index(text, stop); i >= 0 && (idx < 0 || i < idx) {
+			idx, matched = i, stop
+		}
+	}
+	return idx, matched
+}
+
+// withMaxTokens 包装事件处理函数，正文超过 maxTokens 时截断并以 length 结束
+// 只统计正文，思考过程不计入
+func withMaxTokens(model string, maxTokens int, next func(sseEvent) error) func(sseEvent) error {
+	used := 0
+	return func(ev sseEvent) error {
+		if ev.typ != eventText {
+			return next(ev)
+		}
+
+		tokens := tokenizer.Count(model, ev.text)
+		if used+tokens <= maxTokens {
+			used += tokens
+			return next(ev)
+		}
+
+		text, n := tokenizer.Truncate(model, ev.text, maxTokens-used)
+		used += n
+		if text != "" {
+			if err := next(sseEvent{typ: eventText, text: text}); err != nil {
+				return err
+			}
+		}
+		if err := next(sseEvent{typ: eventFinish, finishReason: openai.FinishReasonLength}); err != nil {
+			return err
+		}
+		return errStopped
+	}
+}
+
+// withFinish 包装事件处理函数，用预先确定的结束原因替换结束事件，用于回放已收集的回复
+func withFinish(finish sseEvent, next func(sseEvent) error) func(sseEvent) error {
+	return func(ev sseEvent) error {
+		if ev.typ == eventFinish {
+			ev.finishReason = finish.finishReason
+			ev.stopSequence = finish.stopSequence
+			if finish.usage != nil {
+				ev.usage = finish.usage
+			}
+		}
+		return next(ev)
+	}
+}
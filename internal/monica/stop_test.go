@@ -0,0 +1,96 @@
+package monica
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeMonicaSSE 按 chunk 构造 Monica SSE 响应体
+func fakeMonicaSSE(chunks ...string) *strings.Reader {
+	var sb strings.Builder
+	for _, chunk := range chunks {
+		sb.WriteString(`data: {"text":"` + chunk + `"}` + "\n\n")
+	}
+	sb.WriteString(`data: {"text":"","finished":true}` + "\n\n")
+	return strings.NewReader(sb.String())
+}
+
+// TestStopSequenceAcrossChunks 测试停止序列跨 chunk 命中
+func TestStopSequenceAcrossChunks(t *testing.T) {
+	stream := &CompletionStream{
+		Body:    io.NopCloser(fakeMonicaSSE("Hello wor", "ld. EN", "D more", " text")),
+		Options: CompletionOptions{Model: "gpt-4o", Stop: []string{"END"}},
+	}
+	completion, err := CollectCompletion(context.Background(), stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Text != "Hello world. " {
+		t.Errorf("正文应在停止序列前截断，实际 %q", completion.Text)
+	}
+	if completion.FinishReason != openai.FinishReasonStop || completion.StopSequence != "END" {
+		t.Errorf("结束原因错误: %s %q", completion.FinishReason, completion.StopSequence)
+	}
+}
+
+// TestStopSequencePartialPrefix 测试停止序列前缀未命中时正文完整输出
+func TestStopSequencePartialPrefix(t *testing.T) {
+	stream := &CompletionStream{
+		Body:    io.NopCloser(fakeMonicaSSE("a EN", "d b E")),
+		Options: CompletionOptions{Model: "gpt-4o", Stop: []string{"END"}},
+	}
+	completion, err := CollectCompletion(context.Background(), stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Text != "a ENd b E" || completion.StopSequence != "" {
+		t.Errorf("未命中停止序列时正文错误: %q %q", completion.Text, completion.StopSequence)
+	}
+}
+
+// TestMaxTokens 测试超过 max_tokens 时截断并以 length 结束
+func TestMaxTokens(t *testing.T) {
+	stream := &CompletionStream{
+		Body:    io.NopCloser(fakeMonicaSSE("一二三", "四五六")),
+		Options: CompletionOptions{Model: "gpt-4o", MaxTokens: 4},
+	}
+	completion, err := CollectCompletion(context.Background(), stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Text != "一二三四" {
+		t.Errorf("正文应截断为 4 个 token，实际 %q", completion.Text)
+	}
+	if completion.FinishReason != openai.FinishReasonLength {
+		t.Errorf("结束原因应为 length，实际 %s", completion.FinishReason)
+	}
+}
+
+// TestUsage 测试结束时统计输入与输出 token
+func TestUsage(t *testing.T) {
+	stream := &CompletionStream{
+		Body:    io.NopCloser(fakeMonicaSSE("hello", " world")),
+		Options: CompletionOptions{Model: "gpt-4o", PromptTokens: 10},
+	}
+	completion, err := CollectCompletion(context.Background(), stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := openai.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}
+	if completion.Usage != want {
+		t.Errorf("用量错误: %+v", completion.Usage)
+	}
+
+	replay := NewReplayStream(completion, CompletionOptions{Model: "gpt-4o"})
+	replayed, err := CollectCompletion(context.Background(), replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Usage != want {
+		t.Errorf("回放应沿用原来的用量: %+v", replayed.Usage)
+	}
+}
@@ -0,0 +1,39 @@
+package monica
+
+import (
+	"monica-proxy/internal/toolcall"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// withToolCalls 包装事件处理函数，从正文中提取工具调用
+// 正文实时放行，遇到 <tool_call> 后缓存剩余输出，结束时转换为 eventToolCalls 并把结束原因改为 tool_calls
+func withToolCalls(parallel bool, next func(sseEvent) error) func(sseEvent) error {
+	parser := &toolcall.StreamParser{}
+	return func(ev sseEvent) error {
+		switch ev.typ {
+		case eventText:
+			if text := parser.Feed(ev.text); text != "" {
+				return next(sseEvent{typ: eventText, text: text})
+			}
+			return nil
+		case eventFinish:
+			text, calls := parser.Finish()
+			if text != "" {
+				if err := next(sseEvent{typ: eventText, text: text}); err != nil {
+					return err
+				}
+			}
+			if len(calls) > 0 {
+				if !parallel {
+					calls = calls[:1]
+				}
+				if err := next(sseEvent{typ: eventToolCalls, toolCalls: calls}); err != nil {
+					return err
+				}
+				ev.finishReason = openai.FinishReasonToolCalls
+			}
+		}
+		return next(ev)
+	}
+}
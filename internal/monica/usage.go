@@ -0,0 +1,54 @@
+package monica
+
+import (
+	"strings"
+
+	"monica-proxy/internal/tokenizer"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// withUsage 包装事件处理函数，统计正文与思考过程的 token 数并附加到结束事件上
+// 位于工具调用解析之前，<tool_call> 块按原始文本计入输出 token
+func withUsage(model string, promptTokens int, next func(sseEvent) error) func(sseEvent) error {
+	var text, reasoning strings.Builder
+	return func(ev sseEvent) error {
+		switch ev.typ {
+		case eventText:
+			text.WriteString(ev.text)
+		case eventReasoning:
+			reasoning.WriteString(ev.text)
+		case eventFinish:
+			reasoningTokens := tokenizer.Count(model, reasoning.String())
+			completionTokens := tokenizer.Count(model, text.String()) + reasoningTokens
+			usage := &openai.Usage{
+				PromptTokens:     promptTokens,
+				CompletionTokens: completionTokens,
+				TotalTokens:      promptTokens + completionTokens,
+			}
+			if reasoningTokens > 0 {
+				usage.CompletionTokensDetails = &openai.CompletionTokensDetails{ReasoningTokens: reasoningTokens}
+			}
+			ev.usage = usage
+		}
+		return next(ev)
+	}
+}
+
+// SumUsage 合并多个候选的用量，各候选共享同一份输入，输入 token 只计一次
+func SumUsage(usages ...openai.Usage) openai.Usage {
+	var total openai.Usage
+	reasoningTokens := 0
+	for _, usage := range usages {
+		total.PromptTokens = max(total.PromptTokens, usage.PromptTokens)
+		total.CompletionTokens += usage.CompletionTokens
+		if usage.CompletionTokensDetails != nil {
+			reasoningTokens += usage.CompletionTokensDetails.ReasoningTokens
+		}
+	}
+	total.TotalTokens = total.PromptTokens + total.CompletionTokens
+	if reasoningTokens > 0 {
+		total.CompletionTokensDetails = &openai.CompletionTokensDetails{ReasoningTokens: reasoningTokens}
+	}
+	return total
+}
@@ -21,12 +21,15 @@ type ChatService interface {
 // chatService 聊天服务实现
 type chatService struct {
 	config *config.Config
+	// customBot 系统提示词策略为 custom_bot 时，带系统提示词的请求改走 Custom Bot 接口
+	customBot *customBotService
 }
 
 // NewChatService 创建聊天服务实例
 func NewChatService(cfg *config.Config) ChatService {
 	return &chatService{
-		config: cfg,
+		config:    cfg,
+		customBot: &customBotService{config: cfg},
 	}
 }
 
@@ -44,35 +47,32 @@ func (s *chatService) HandleChatCompletion(ctx context.Context, req *openai.Chat
 	// 	zap.Bool("stream", req.Stream),
 	// )
 
-	// 转换请求格式
-	monicaReq, err := types.ChatGPTToMonica(s.config, *req)
-	if err != nil {
-		logger.Error("转换请求失败", zap.Error(err))
-		return nil, errors.NewInternalError(err)
+	// n > 1 时为每个候选发起独立的上游请求
+	if req.N > 1 {
+		return completeChoices(ctx, s.config, req, s.openStream)
 	}
 
-	// 调用Monica API
-	stream, err := monica.SendMonicaRequest(ctx, s.config, monicaReq)
+	// 结构化输出与强制工具调用需要先收集完整回复并校验
+	if validated(req) {
+		return completeStructured(ctx, s.config, req, s.openStream)
+	}
+
+	completionStream, err := s.openStream(ctx, req)
 	if err != nil {
-		logger.Error("调用Monica API失败", zap.Error(err))
-		// 如果已经是AppError，直接返回，否则包装为内部错误
-		if appErr, ok := err.(*errors.AppError); ok {
-			return nil, appErr
-		}
-		return nil, errors.NewInternalError(err)
+		return nil, err
 	}
 	// 根据是否使用流式响应处理结果
 	if req.Stream {
 		// 这里只返回stream，实际的流处理在handler层
 		// 流式响应时不关闭响应体，让handler层负责关闭
-		return stream.RawBody(), nil
+		return completionStream, nil
 	}
 
 	// 非流式响应，确保在此函数结束时关闭响应体
-	defer stream.RawBody().Close()
+	defer completionStream.Close()
 
 	// 处理非流式响应
-	response, err := monica.CollectMonicaSSEToCompletion(req.Model, stream.RawBody())
+	response, err := monica.CollectMonicaSSEToCompletion(ctx, completionStream)
 	if err != nil {
 		logger.Error("处理Monica响应失败", zap.Error(err))
 		return nil, errors.NewInternalError(err)
@@ -80,3 +80,47 @@ func (s *chatService) HandleChatCompletion(ctx context.Context, req *openai.Chat
 
 	return response, nil
 }
+
+// openStream 转换请求并调用 Monica API，返回上游 SSE 流
+func (s *chatService) openStream(ctx context.Context, req *openai.ChatCompletionRequest) (*monica.CompletionStream, error) {
+	if s.config.SystemPrompt.Strategy == config.SystemPromptCustomBot && types.SystemPrompt(req.Messages) != "" {
+		return s.customBot.openStream(ctx, req, s.config.Monica.BotUID)
+	}
+
+	// 转换请求格式
+	monicaReq, err := types.ChatGPTToMonica(ctx, s.config, *req)
+	if err != nil {
+		logger.Error("转换请求失败", zap.Error(err))
+		// 附件校验、解析失败等已是AppError，直接返回
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.NewInternalError(err)
+	}
+
+	// 超出模型上下文窗口时按策略截断或摘要较早的对话
+	opts := monica.NewCompletionOptions(req)
+	items, err := fitContext(ctx, s.config, req.Model, monicaReq.Data.Items, monicaReq.PinnedItems(), opts.MaxTokens, 0)
+	if err != nil {
+		return nil, err
+	}
+	monicaReq.Data.Items = items
+
+	// 调用Monica API
+	stream, err := monica.SendMonicaRequest(ctx, s.config, monicaReq)
+	if err != nil {
+		logger.Error("调用Monica API失败", zap.Error(err))
+		// 如果已经是AppError，直接返回，否则包装为内部错误
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.NewInternalError(err)
+	}
+	// 回复完整结束后再记录会话，下一轮接在 Monica 的回复条目之后
+	opts.OnReply = monicaReq.SaveSession
+	opts.PromptTokens = monicaReq.PromptTokens(req.Model)
+	return &monica.CompletionStream{
+		Body:    stream.RawBody(),
+		Options: opts,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+	"monica-proxy/internal/logger"
+	"monica-proxy/internal/monica"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// completeChoices 处理 n > 1 的请求，每个候选发起一次独立的上游请求
+// 上游请求并发数受 Choices.Concurrency 限制，部分失败按 Choices.PartialFailure 处理
+// 所有候选共用请求的账号租约，不在多个账号之间分摊；账号失效换号后其余候选同样使用新账号
+func completeChoices(ctx context.Context, cfg *config.Config, req *openai.ChatCompletionRequest, open streamOpener) (interface{}, error) {
+	if req.N > cfg.Choices.MaxN {
+		return nil, errors.NewInvalidInputError(fmt.Sprintf("n 不能超过 %d", cfg.Choices.MaxN), nil)
+	}
+	allowPartial := cfg.Choices.PartialFailure == config.PartialFailurePartial
+
+	// 流式请求直接返回多个上游流，由 handler 交错输出
+	if req.Stream && !validated(req) {
+		streams, err := fanOut(ctx, req.N, cfg.Choices.Concurrency, allowPartial, func(ctx context.Context) (*monica.CompletionStream, error) {
+			return open(ctx, req)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &monica.CompletionStream{
+			Options:      monica.NewCompletionOptions(req),
+			Choices:      streams,
+			AllowPartial: allowPartial,
+		}, nil
+	}
+
+	completions, err := fanOut(ctx, req.N, cfg.Choices.Concurrency, allowPartial, func(ctx context.Context) (*monica.Completion, error) {
+		if validated(req) {
+			return collectStructured(ctx, cfg, req, open)
+		}
+		return collectCompletion(ctx, req, open)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Stream {
+		// 需要校验的回复先收集校验，再按流式格式回放
+		streams := make([]*monica.CompletionStream, 0, len(completions))
+		for _, completion := range completions {
+			streams = append(streams, monica.NewReplayStream(completion, monica.NewCompletionOptions(req)))
+		}
+		return &monica.CompletionStream{
+			Options: monica.NewCompletionOptions(req),
+			Choices: streams,
+		}, nil
+	}
+	return monica.NewCompletionResponse(req.Model, completions...), nil
+}
+
+// errChoiceSkipped 已有候选失败时跳过尚未开始的上游请求
+var errChoiceSkipped = fmt.Errorf("已有候选失败，跳过该候选")
+
+// fanOut 以最多 concurrency 的并发执行 n 次 fn，按下标顺序返回成功的结果
+// allowPartial 为 false 时任一失败即整体失败；为 true 时只有全部失败才返回错误
+// fn 使用调用方的 ctx，返回的流在 fanOut 结束后仍可继续读取
+func fanOut[T any](ctx context.Context, n, concurrency int, allowPartial bool, fn func(ctx context.Context) (T, error)) ([]T, error) {
+	results := make([]T, n)
+	errs := make([]error, n)
+	sem := make(chan struct{}, concurrency)
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			if failed.Load() {
+				errs[i] = errChoiceSkipped
+				return
+			}
+
+			results[i], errs[i] = fn(ctx)
+			if errs[i] != nil && !allowPartial {
+				failed.Store(true)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := make([]T, 0, n)
+	var firstErr error
+	for i := range results {
+		if errs[i] == nil {
+			succeeded = append(succeeded, results[i])
+			continue
+		}
+		// 汇总错误时优先返回真正的失败原因
+		if firstErr == nil || firstErr == errChoiceSkipped {
+			firstErr = errs[i]
+		}
+	}
+	if firstErr == nil {
+		return succeeded, nil
+	}
+
+	if allowPartial && len(succeeded) > 0 {
+		logger.Warn("部分候选失败，返回成功的候选",
+			zap.Int("n", n),
+			zap.Int("succeeded", len(succeeded)),
+			zap.Error(firstErr),
+		)
+		return succeeded, nil
+	}
+
+	// 整体失败时释放已经打开的上游流
+	for _, result := range succeeded {
+		if c, ok := any(result).(io.Closer); ok {
+			c.Close()
+		}
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestFanOutConcurrency 测试并发数限制与结果顺序
+func TestFanOutConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	var next atomic.Int32
+	results, err := fanOut(context.Background(), 6, 2, false, func(ctx context.Context) (int, error) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return int(next.Add(1)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 6 {
+		t.Errorf("期望 6 个结果，实际 %d 个", len(results))
+	}
+	if peak.Load() > 2 {
+		t.Errorf("并发数超过限制: %d", peak.Load())
+	}
+}
+
+// TestFanOutPartialFailure 测试部分失败策略
+func TestFanOutPartialFailure(t *testing.T) {
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (int, error) {
+		if calls.Add(1) == 1 {
+			return 0, fmt.Errorf("boom")
+		}
+		return 1, nil
+	}
+
+	results, err := fanOut(context.Background(), 3, 1, true, fn)
+	if err != nil || len(results) != 2 {
+		t.Errorf("partial 策略应返回成功的候选: %v %v", results, err)
+	}
+
+	calls.Store(0)
+	if _, err := fanOut(context.Background(), 3, 1, false, fn); err == nil || err.Error() != "boom" {
+		t.Errorf("fail 策略应返回第一个失败原因，实际 %v", err)
+	}
+}
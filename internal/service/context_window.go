@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"monica-proxy/internal/cache"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+	"monica-proxy/internal/logger"
+	"monica-proxy/internal/monica"
+	"monica-proxy/internal/tokenizer"
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// modelContextLimits 内置的模型上下文窗口（token），可通过 Context.ModelLimits 覆盖
+var modelContextLimits = map[string]int{
+	"gpt-5":        272000,
+	"gpt-4o":       128000,
+	"gpt-4o-mini":  128000,
+	"gpt-4.1":      1047576,
+	"gpt-4.1-mini": 1047576,
+	"gpt-4.1-nano": 1047576,
+	"gpt-4-5":      128000,
+	"o3":           200000,
+	"o3-mini":      200000,
+	"o4-mini":      200000,
+
+	"claude-haiku-4-5":                  200000,
+	"claude-sonnet-4-5":                 200000,
+	"claude-4-sonnet":                   200000,
+	"claude-4-sonnet-thinking":          200000,
+	"claude-4-opus":                     200000,
+	"claude-4-opus-thinking":            200000,
+	"claude-opus-4-1-20250805-thinking": 200000,
+	"claude-3-7-sonnet-thinking":        200000,
+	"claude-3-7-sonnet":                 200000,
+	"claude-3-5-haiku":                  200000,
+
+	"gemini-3-pro-preview-thinking": 1048576,
+	"gemini-2.5-pro":                1048576,
+	"gemini-2.5-flash":              1048576,
+	"gemini-2.0-flash":              1048576,
+
+	"deepseek-v3.1":     128000,
+	"deepseek-reasoner": 64000,
+	"deepseek-chat":     64000,
+	"deepclaude":        64000,
+
+	"sonar":               127000,
+	"sonar-reasoning-pro": 127000,
+
+	"grok-3-beta":      131072,
+	"grok-4":           256000,
+	"grok-code-fast-1": 256000,
+}
+
+const (
+	// summaryMaxTokens 摘要的最大长度，截断时为摘要预留的空间
+	summaryMaxTokens = 1024
+	// summaryCacheEntries 最多缓存的摘要数
+	summaryCacheEntries = 1000
+	summaryCacheTTL     = 24 * time.Hour
+	summaryPrompt       = "请用简洁的中文概括下面这段对话，保留关键事实、结论、用户的偏好与尚未解决的问题，不要添加对话中没有的内容，直接输出摘要。\n\n"
+	summaryQuestion     = "以下是此前对话的摘要：\n\n"
+	summaryReply        = "好的，我会基于这些内容继续对话。"
+)
+
+// summaries 历史对话摘要缓存，键为摘要模型与被摘要条目前缀的指纹
+// 长对话每轮都会丢弃同一段开头，命中时无需重复请求摘要模型
+var summaries, _ = cache.New[string](cache.Options{MaxEntries: summaryCacheEntries, TTL: summaryCacheTTL})
+
+// contextLimit 模型的上下文窗口，配置优先于内置表
+func contextLimit(cfg *config.Config, model string) int {
+	if limit, ok := cfg.Context.ModelLimits[model]; ok {
+		return limit
+	}
+	if limit, ok := modelContextLimits[model]; ok {
+		return limit
+	}
+	return cfg.Context.DefaultLimit
+}
+
+// fitContext 检查转换后的会话条目是否超出模型的上下文窗口，超出时按策略丢弃或摘要最早的对话
+// 开头的 pinned 个条目（欢迎消息与系统提示词）与最后一个条目始终保留，reserve 为回复预留的 token，extra 为条目之外计入输入的 token
+// 丢弃按整轮进行，问题之后的回答一并丢弃；处理结果通过 X-Monica-Proxy-Context 响应头返回
+func fitContext(ctx context.Context, cfg *config.Config, model string, items []types.Item, pinned, reserve, extra int) ([]types.Item, error) {
+	if cfg.Context.Strategy == config.ContextStrategyNone || len(items) == 0 {
+		return items, nil
+	}
+	if reserve <= 0 {
+		reserve = cfg.Context.ReserveTokens
+	}
+	limit := contextLimit(cfg, model)
+	budget := limit - reserve - extra - tokenizer.TokensPerReply
+
+	tokens := make([]int, len(items))
+	total := 0
+	for i, item := range items {
+		tokens[i] = types.ItemTokens(model, item)
+		total += tokens[i]
+	}
+	if total <= budget {
+		return items, nil
+	}
+
+	summarize := cfg.Context.Strategy == config.ContextStrategySummarize
+	target := budget
+	if summarize {
+		target -= summaryMaxTokens + 2*tokenizer.TokensPerMessage + tokenizer.Count(model, summaryQuestion+summaryReply)
+	}
+
+	// 从最早的可丢弃条目开始按整轮丢弃
+	pinned = min(pinned, len(items)-1)
+	end := pinned
+	for end < len(items)-1 && total > target {
+		total -= tokens[end]
+		end++
+		for end < len(items)-1 && items[end].ItemType == "reply" {
+			total -= tokens[end]
+			end++
+		}
+	}
+	if total > target {
+		return nil, errors.NewContextLengthError(model, total+reserve+extra, limit)
+	}
+	dropped := items[pinned:end]
+
+	kept := make([]types.Item, 0, len(items)-len(dropped)+2)
+	kept = append(kept, items[:pinned]...)
+	report := fmt.Sprintf("truncated; dropped_items=%d", len(dropped))
+	truncated := true
+	if summarize {
+		summary, err := summarizeItems(ctx, cfg, dropped)
+		if err != nil {
+			// 摘要失败时退回为直接丢弃
+			logger.Warn("摘要历史对话失败，改为直接丢弃", zap.Error(err))
+		} else {
+			conversationID := items[len(items)-1].ConversationID
+			kept = append(kept,
+				types.NewTextItem(conversationID, "question", summaryQuestion+summary),
+				types.NewTextItem(conversationID, "reply", summaryReply),
+			)
+			report = fmt.Sprintf("summarized; summarized_items=%d", len(dropped))
+			truncated = false
+		}
+	}
+	kept = append(kept, items[end:]...)
+
+	// 重新串联父条目，第一个条目保持原来的父条目（复用会话时指向上一轮的最后一条）
+	for i := 1; i < len(kept); i++ {
+		kept[i].ParentItemID = kept[i-1].ItemID
+	}
+	if len(kept) > 0 && pinned == 0 {
+		kept[0].ParentItemID = items[0].ParentItemID
+	}
+
+	logger.Warn("对话超出上下文窗口",
+		zap.String("model", model),
+		zap.Int("limit", limit),
+		zap.String("result", report),
+	)
+	utils.AddResponseHeader(ctx, utils.ContextHeader, report)
+	if truncated {
+		utils.AddResponseHeader(ctx, utils.WarningHeader, fmt.Sprintf("context window exceeded, %d earlier items were dropped", len(dropped)))
+	}
+	return kept, nil
+}
+
+// summarizeItems 用摘要模型概括被丢弃的条目，附件只保留文件名
+// 摘要按条目前缀缓存：完全命中时直接复用，命中较短的前缀时只把之前的摘要与新增条目交给摘要模型
+func summarizeItems(ctx context.Context, cfg *config.Config, items []types.Item) (string, error) {
+	model := cfg.Context.SummaryModel
+	keys := summaryKeys(model, items)
+	if summary, ok := summaries.Get(keys[len(items)]); ok {
+		return summary, nil
+	}
+
+	var transcript strings.Builder
+	start := 0
+	for i := len(items) - 1; i > 0; i-- {
+		if summary, ok := summaries.Get(keys[i]); ok {
+			transcript.WriteString(summaryQuestion)
+			transcript.WriteString(summary)
+			transcript.WriteString("\n\n")
+			start = i
+			break
+		}
+	}
+	for _, item := range items[start:] {
+		transcript.WriteString(itemTranscript(item))
+	}
+
+	// 摘要请求本身也不能超出摘要模型的上下文窗口，过长时只保留开头部分
+	text, _ := tokenizer.Truncate(model, transcript.String(), contextLimit(cfg, model)-cfg.Context.ReserveTokens)
+
+	resp, err := monica.SendMonicaRequest(ctx, cfg, types.NewTextRequest(model, summaryPrompt+text))
+	if err != nil {
+		return "", err
+	}
+	stream := &monica.CompletionStream{Body: resp.RawBody(), Options: monica.CompletionOptions{Model: model}}
+	defer stream.Close()
+	completion, err := monica.CollectCompletion(ctx, stream)
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(completion.Text)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	summary, _ = tokenizer.Truncate(model, summary, summaryMaxTokens)
+	summaries.Set(keys[len(items)], summary, 0)
+	return summary, nil
+}
+
+// itemTranscript 把条目渲染为摘要请求中的一段对话
+func itemTranscript(item types.Item) string {
+	var sb strings.Builder
+	role := "用户"
+	if item.ItemType == "reply" {
+		role = "助手"
+	}
+	sb.WriteString(role)
+	sb.WriteString("：")
+	sb.WriteString(item.Data.Content)
+	for _, file := range item.Data.FileInfos {
+		sb.WriteString("\n[附件 ")
+		sb.WriteString(file.FileName)
+		sb.WriteString("]")
+	}
+	sb.WriteString("\n\n")
+	return sb.String()
+}
+
+// summaryKeys 计算条目各前缀的摘要缓存键，第 i 项对应前 i 个条目；条目 ID 每次请求随机生成，只按内容计算
+func summaryKeys(model string, items []types.Item) []string {
+	keys := make([]string, 0, len(items)+1)
+	sum := sha256.Sum256([]byte(model))
+	keys = append(keys, hex.EncodeToString(sum[:]))
+	for _, item := range items {
+		h := sha256.New()
+		h.Write(sum[:])
+		h.Write([]byte(itemTranscript(item)))
+		copy(sum[:], h.Sum(nil))
+		keys = append(keys, hex.EncodeToString(sum[:]))
+	}
+	return keys
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"monica-proxy/internal/cache"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// testItems 构造欢迎消息之后的 n 条问答交替的条目，每条约 words 个 token
+func testItems(n, words int) []types.Item {
+	items := []types.Item{types.NewTextItem("conv:test", "reply", "__RENDER_BOT_WELCOME_MSG__")}
+	for i := 0; i < n; i++ {
+		itemType := "question"
+		if i%2 == 1 {
+			itemType = "reply"
+		}
+		item := types.NewTextItem("conv:test", itemType, strings.Repeat("word ", words))
+		item.ParentItemID = items[len(items)-1].ItemID
+		items = append(items, item)
+	}
+	return items
+}
+
+func contextConfig(strategy string, limit int) *config.Config {
+	return &config.Config{Context: config.ContextConfig{
+		Strategy:     strategy,
+		DefaultLimit: limit,
+		SummaryModel: "gpt-4.1-nano",
+	}}
+}
+
+// TestFitContextTruncate 测试按整轮丢弃最早的对话并重新串联父条目
+func TestFitContextTruncate(t *testing.T) {
+	ctx, headers := utils.WithResponseHeaders(context.Background())
+	items := testItems(5, 100)
+
+	kept, err := fitContext(ctx, contextConfig(config.ContextStrategyTruncate, 350), "test-model", items, 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 欢迎消息 + 最近一轮问答 + 最后的问题
+	if len(kept) != 4 || kept[0].ItemID != items[0].ItemID || kept[1].ItemID != items[3].ItemID || kept[3].ItemID != items[5].ItemID {
+		t.Fatalf("保留的条目错误: %d 条", len(kept))
+	}
+	for i := 1; i < len(kept); i++ {
+		if kept[i].ParentItemID != kept[i-1].ItemID {
+			t.Errorf("第 %d 条的父条目未重新串联", i)
+		}
+	}
+
+	header := make(http.Header)
+	headers.CopyTo(header)
+	if got := header.Get(utils.ContextHeader); got != "truncated; dropped_items=2" {
+		t.Errorf("响应头错误: %q", got)
+	}
+	if header.Get(utils.WarningHeader) == "" {
+		t.Errorf("丢弃历史时应返回警告响应头")
+	}
+
+	// 不超出时原样返回
+	if kept, _ := fitContext(ctx, contextConfig(config.ContextStrategyTruncate, 100000), "test-model", items, 1, 1, 0); len(kept) != len(items) {
+		t.Errorf("未超出上下文窗口时不应丢弃条目")
+	}
+	// 只剩最后一条仍然超出时返回错误
+	if _, err := fitContext(ctx, contextConfig(config.ContextStrategyTruncate, 100), "test-model", items, 1, 1, 0); err == nil {
+		t.Errorf("最后一条超出上下文窗口时应返回错误")
+	}
+}
+
+// fakeSummaryTransport 模拟摘要模型的 Monica SSE 响应
+type fakeSummaryTransport struct {
+	request  string
+	requests int
+}
+
+func (f *fakeSummaryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	f.request = string(body)
+	f.requests++
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader("data: {\"text\":\"用户问了两个问题\",\"finished\":true}\n\n")),
+		Request:    req,
+	}, nil
+}
+
+// TestFitContextSummarize 测试用摘要替换最早的对话，相同的历史复用缓存的摘要
+func TestFitContextSummarize(t *testing.T) {
+	transport := &fakeSummaryTransport{}
+	original := utils.RestySSEClient
+	utils.RestySSEClient = resty.New().SetDoNotParseResponse(true).SetTransport(transport)
+	defer func() { utils.RestySSEClient = original }()
+	summaries, _ = cache.New[string](cache.Options{MaxEntries: summaryCacheEntries})
+
+	ctx, headers := utils.WithResponseHeaders(context.Background())
+	items := testItems(5, 600)
+	kept, err := fitContext(ctx, contextConfig(config.ContextStrategySummarize, 2900), "test-model", items, 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(transport.request, "gpt_4_1_nano") {
+		t.Errorf("摘要应使用配置的模型: %s", transport.request)
+	}
+	// 欢迎消息 + 摘要问答 + 最近一轮问答 + 最后的问题
+	if len(kept) != 6 || !strings.Contains(kept[1].Data.Content, "用户问了两个问题") || kept[len(kept)-1].ItemID != items[5].ItemID {
+		t.Fatalf("摘要结果错误: %+v", kept)
+	}
+
+	header := make(http.Header)
+	headers.CopyTo(header)
+	if got := header.Get(utils.ContextHeader); !strings.HasPrefix(got, "summarized;") {
+		t.Errorf("响应头错误: %q", got)
+	}
+
+	// 下一轮请求丢弃同一段历史时复用摘要，条目 ID 不同不影响命中
+	cfg := contextConfig(config.ContextStrategySummarize, 2900)
+	if _, err := fitContext(ctx, cfg, "test-model", testItems(5, 600), 1, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("相同的历史应复用缓存的摘要，实际请求摘要 %d 次", transport.requests)
+	}
+
+	// 丢弃更长的历史时只把之前的摘要与新增部分交给摘要模型
+	if _, err := fitContext(ctx, cfg, "test-model", testItems(7, 600), 1, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if transport.requests != 2 || !strings.Contains(transport.request, "用户问了两个问题") {
+		t.Errorf("摘要请求应包含之前的摘要: %s", transport.request)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"monica-proxy/internal/errors"
 	"monica-proxy/internal/logger"
 	"monica-proxy/internal/monica"
+	"monica-proxy/internal/tokenizer"
 	"monica-proxy/internal/types"
 
 	"github.com/sashabaranov/go-openai"
@@ -43,35 +44,36 @@ func (s *customBotService) HandleCustomBotChat(ctx context.Context, req *openai.
 		zap.Bool("stream", req.Stream),
 	)
 
-	// 转换请求格式
-	customBotReq, err := types.ChatGPTToCustomBot(s.config, *req, botUID)
-	if err != nil {
-		logger.Error("转换Custom Bot请求失败", zap.Error(err))
-		return nil, errors.NewInternalError(err)
+	openStream := func(ctx context.Context, req *openai.ChatCompletionRequest) (*monica.CompletionStream, error) {
+		return s.openStream(ctx, req, botUID)
 	}
 
-	// 调用Monica Custom Bot API
-	stream, err := monica.SendCustomBotRequest(ctx, s.config, customBotReq)
+	// n > 1 时为每个候选发起独立的上游请求
+	if req.N > 1 {
+		return completeChoices(ctx, s.config, req, openStream)
+	}
+
+	// 结构化输出与强制工具调用需要先收集完整回复并校验
+	if validated(req) {
+		return completeStructured(ctx, s.config, req, openStream)
+	}
+
+	completionStream, err := openStream(ctx, req)
 	if err != nil {
-		logger.Error("调用Custom Bot API失败", zap.Error(err))
-		// 如果已经是AppError，直接返回，否则包装为内部错误
-		if appErr, ok := err.(*errors.AppError); ok {
-			return nil, appErr
-		}
-		return nil, errors.NewInternalError(err)
+		return nil, err
 	}
 
 	// 根据是否使用流式响应处理结果
 	if req.Stream {
 		// 流式响应时不关闭响应体，让handler层负责关闭
-		return stream.RawBody(), nil
+		return completionStream, nil
 	}
 
 	// 非流式响应，确保在此函数结束时关闭响应体
-	defer stream.RawBody().Close()
+	defer completionStream.Close()
 
 	// 处理非流式响应
-	response, err := monica.CollectMonicaSSEToCompletion(req.Model, stream.RawBody())
+	response, err := monica.CollectMonicaSSEToCompletion(ctx, completionStream)
 	if err != nil {
 		logger.Error("处理Custom Bot响应失败", zap.Error(err))
 		return nil, errors.NewInternalError(err)
@@ -79,3 +81,47 @@ func (s *customBotService) HandleCustomBotChat(ctx context.Context, req *openai.
 
 	return response, nil
 }
+
+// openStream 转换请求并调用 Monica Custom Bot API，返回上游 SSE 流
+func (s *customBotService) openStream(ctx context.Context, req *openai.ChatCompletionRequest, botUID string) (*monica.CompletionStream, error) {
+	// 转换请求格式
+	customBotReq, err := types.ChatGPTToCustomBot(ctx, s.config, *req, botUID)
+	if err != nil {
+		logger.Error("转换Custom Bot请求失败", zap.Error(err))
+		// 附件校验、解析失败等已是AppError，直接返回
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.NewInternalError(err)
+	}
+
+	// 超出模型上下文窗口时按策略截断或摘要较早的对话，bot 提示词始终完整发送
+	opts := monica.NewCompletionOptions(req)
+	var promptTokens int
+	if prompt := customBotReq.BotData.Prompt; prompt != "" {
+		promptTokens = tokenizer.Count(req.Model, prompt) + tokenizer.TokensPerMessage
+	}
+	items, err := fitContext(ctx, s.config, req.Model, customBotReq.Data.Items, customBotReq.PinnedItems(), opts.MaxTokens, promptTokens)
+	if err != nil {
+		return nil, err
+	}
+	customBotReq.Data.Items = items
+
+	// 调用Monica Custom Bot API
+	stream, err := monica.SendCustomBotRequest(ctx, s.config, customBotReq)
+	if err != nil {
+		logger.Error("调用Custom Bot API失败", zap.Error(err))
+		// 如果已经是AppError，直接返回，否则包装为内部错误
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.NewInternalError(err)
+	}
+	// 回复完整结束后再记录会话，下一轮接在 Monica 的回复条目之后
+	opts.OnReply = customBotReq.SaveSession
+	opts.PromptTokens = customBotReq.PromptTokens(req.Model)
+	return &monica.CompletionStream{
+		Body:    stream.RawBody(),
+		Options: opts,
+	}, nil
+}
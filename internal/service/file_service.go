@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"monica-proxy/internal/account"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+	"monica-proxy/internal/logger"
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// FileService Files API 服务接口
+type FileService interface {
+	// UploadFile 上传文件到Monica并保存文件信息
+	UploadFile(ctx context.Context, file *multipart.FileHeader, purpose string) (*types.FileObject, error)
+	// ListFiles 列出已上传的文件
+	ListFiles(purpose string) *types.FileList
+	// GetFile 获取文件信息
+	GetFile(id string) (*types.FileObject, error)
+	// DeleteFile 删除本地保存的文件信息，Monica 没有提供删除文件的接口，已上传到 Monica 的文件不会被删除
+	DeleteFile(id string) (*types.FileDeleted, error)
+	// GetFileContent 下载文件内容，返回内容与 MIME 类型
+	GetFileContent(ctx context.Context, id string) ([]byte, string, error)
+}
+
+// fileService Files API 服务实现
+type fileService struct {
+	config *config.Config
+}
+
+// NewFileService 创建 Files API 服务实例
+func NewFileService(cfg *config.Config) FileService {
+	return &fileService{
+		config: cfg,
+	}
+}
+
+// UploadFile 上传文件，等待 Monica 解析完成后保存文件信息，之后的对话可通过 file_id 引用而无需重新上传
+func (s *fileService) UploadFile(ctx context.Context, file *multipart.FileHeader, purpose string) (*types.FileObject, error) {
+	if purpose == "" {
+		return nil, errors.NewInvalidInputError("purpose 不能为空", nil)
+	}
+	if file.Size > s.config.Files.MaxSize {
+		return nil, errors.NewInvalidInputError("文件过大", fmt.Errorf("file size exceeds limit: %d > %d", file.Size, s.config.Files.MaxSize))
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, errors.NewBadRequestError("读取上传文件失败", err)
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, s.config.Files.MaxSize+1))
+	if err != nil {
+		return nil, errors.NewBadRequestError("读取上传文件失败", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, types.ImageUploadTimeout+s.config.Attachments.IndexTimeout)
+	defer cancel()
+
+	info, err := types.UploadFileBytes(ctx, s.config, data, file.Header.Get("Content-Type"), file.Filename, s.config.Files.MaxSize)
+	if err != nil {
+		logger.Error("上传文件失败", zap.String("filename", file.Filename), zap.Error(err))
+		return nil, err
+	}
+
+	stored := &types.StoredFile{
+		ID:        "file-" + utils.RandStringUsingMathRand(24),
+		Filename:  file.Filename,
+		Purpose:   purpose,
+		CreatedAt: time.Now().Unix(),
+		Info:      *info,
+		Account:   account.Name(ctx),
+	}
+	if err := types.Files().Put(stored); err != nil {
+		return nil, errors.NewInternalError(err)
+	}
+
+	logger.Info("文件上传成功",
+		zap.String("file_id", stored.ID),
+		zap.String("file_uid", info.FileUID),
+		zap.Int64("file_tokens", info.FileTokens),
+	)
+	object := stored.Object()
+	return &object, nil
+}
+
+// ListFiles 列出已上传的文件
+func (s *fileService) ListFiles(purpose string) *types.FileList {
+	files := types.Files().List(purpose)
+	list := &types.FileList{Object: "list", Data: make([]types.FileObject, 0, len(files))}
+	for _, f := range files {
+		list.Data = append(list.Data, f.Object())
+	}
+	return list
+}
+
+// GetFile 获取文件信息
+func (s *fileService) GetFile(id string) (*types.FileObject, error) {
+	stored, ok := types.Files().Get(id)
+	if !ok {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("文件不存在: %s", id))
+	}
+	object := stored.Object()
+	return &object, nil
+}
+
+// DeleteFile 删除本地保存的文件信息，删除后无法再通过 file_id 引用或下载该文件
+// Monica 没有提供删除文件的接口，Monica 侧的文件仍然存在，直到 Monica 自行清理
+func (s *fileService) DeleteFile(id string) (*types.FileDeleted, error) {
+	deleted, err := types.Files().Delete(id)
+	if err != nil {
+		return nil, errors.NewInternalError(err)
+	}
+	if !deleted {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("文件不存在: %s", id))
+	}
+	return &types.FileDeleted{
+		ID:      id,
+		Object:  "file",
+		Deleted: true,
+	}, nil
+}
+
+// GetFileContent 从 Monica CDN 下载文件内容，使用按配置创建的 HTTP 客户端（超时、TLS 与重试）
+func (s *fileService) GetFileContent(ctx context.Context, id string) ([]byte, string, error) {
+	stored, ok := types.Files().Get(id)
+	if !ok {
+		return nil, "", errors.NewNotFoundError(fmt.Sprintf("文件不存在: %s", id))
+	}
+
+	resp, err := utils.RestyDefaultClient.R().
+		SetContext(ctx).
+		Get(stored.Info.FileURL)
+	if err != nil {
+		return nil, "", errors.NewRequestFailedError("下载文件失败", err)
+	}
+	if resp.IsError() {
+		return nil, "", errors.NewRequestFailedError("下载文件失败", fmt.Errorf("status %d", resp.StatusCode()))
+	}
+	return resp.Body(), stored.Info.FileType, nil
+}
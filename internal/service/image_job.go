@@ -0,0 +1,103 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"monica-proxy/internal/account"
+	"monica-proxy/internal/cache"
+	"monica-proxy/internal/monica"
+	"monica-proxy/internal/types"
+)
+
+const (
+	imageJobIDPrefix   = "imgjob_"
+	imageJobTTL        = time.Hour // 任务结束后保留的时长，进行中的任务从提交时起计算
+	imageJobMaxEntries = 1000
+	// imageJobProgressInterval SSE 推送估算进度的间隔
+	imageJobProgressInterval = time.Second
+)
+
+// imageJobEntry 保存的任务，updated 在任务变更时关闭并替换，用于通知等待中的 SSE 连接
+type imageJobEntry struct {
+	job     types.ImageJob
+	task    *monica.ImageTask
+	updated chan struct{}
+}
+
+// imageJobStore 异步图像生成任务的本地存储
+// 任务在提交与结束时写入缓存，结束后保留 imageJobTTL；超出容量时淘汰最久未查询的任务
+type imageJobStore struct {
+	mu      sync.Mutex // 保护条目中的任务状态与通知通道
+	entries *cache.LRU[*imageJobEntry]
+}
+
+// newImageJobStore 创建任务存储
+func newImageJobStore() *imageJobStore {
+	// 不配置持久化路径时创建缓存不会失败
+	entries, _ := cache.New[*imageJobEntry](cache.Options{MaxEntries: imageJobMaxEntries, TTL: imageJobTTL})
+	return &imageJobStore{entries: entries}
+}
+
+// Put 保存新任务
+func (s *imageJobStore) Put(job types.ImageJob, task *monica.ImageTask) {
+	s.entries.Set(job.ID, &imageJobEntry{
+		job:     job,
+		task:    task,
+		updated: make(chan struct{}),
+	}, 0)
+}
+
+// Get 获取任务的当前状态，进行中的任务按已用时间更新进度，返回的通道在任务下次变更时关闭
+func (s *imageJobStore) Get(id string) (types.ImageJob, <-chan struct{}, bool) {
+	entry, exists := s.entries.Get(id)
+	if !exists {
+		return types.ImageJob{}, nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := entry.job
+	if !job.Done() {
+		job.Progress = entry.task.Progress()
+	}
+	return job, entry.updated, true
+}
+
+// Finish 记录任务结果并通知等待者，结果从结束时起保留 imageJobTTL
+func (s *imageJobStore) Finish(id string, result *types.ImageGenerationResponse, err error) {
+	entry, exists := s.entries.Get(id)
+	if !exists {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		entry.job.Status = types.ImageJobFailed
+		entry.job.Error = &types.ImageJobError{Message: err.Error()}
+	} else {
+		entry.job.Status = types.ImageJobSucceeded
+		entry.job.Progress = 100
+		entry.job.Result = result
+	}
+	close(entry.updated)
+	entry.updated = make(chan struct{})
+	s.entries.Set(id, entry, 0)
+}
+
+// imageJobScope 多账号时任务 ID 中标识账号的令牌，由账号名散列得到，不在 ID 中暴露账号名
+func imageJobScope(name string) string {
+	sum := sha256.Sum256([]byte("imgjob:" + name))
+	return hex.EncodeToString(sum[:8])
+}
+
+// resolveImageJobScope 找到令牌对应的账号名，找不到时返回空
+func resolveImageJobScope(scope string) string {
+	for _, stats := range account.AccountStats() {
+		if imageJobScope(stats.Name) == scope {
+			return stats.Name
+		}
+	}
+	return ""
+}
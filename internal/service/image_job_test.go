@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"monica-proxy/internal/errors"
+	"monica-proxy/internal/monica"
+	"monica-proxy/internal/types"
+)
+
+// TestWatchImageJob 测试任务结束时通知等待者并推送最终状态
+func TestWatchImageJob(t *testing.T) {
+	s := &imageService{jobs: newImageJobStore()}
+	task := &monica.ImageTask{ID: 1, ExpectedTime: time.Minute, SubmittedAt: time.Now()}
+	s.jobs.Put(types.ImageJob{ID: "imgjob_1", Status: types.ImageJobInProgress}, task)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.jobs.Finish("imgjob_1", &types.ImageGenerationResponse{Data: []types.ImageGenerationData{{URL: "u"}}}, nil)
+	}()
+
+	var statuses []string
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := s.WatchImageJob(ctx, "imgjob_1", func(job *types.ImageJob) error {
+		statuses = append(statuses, job.Status)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0] != types.ImageJobInProgress || statuses[1] != types.ImageJobSucceeded {
+		t.Errorf("推送的状态错误: %v", statuses)
+	}
+
+	job, err := s.GetImageJob(ctx, "imgjob_1")
+	if err != nil || job.Progress != 100 || job.Result == nil {
+		t.Errorf("任务结果错误: %+v, %v", job, err)
+	}
+
+	s.jobs.Put(types.ImageJob{ID: "imgjob_2", Status: types.ImageJobInProgress}, task)
+	s.jobs.Finish("imgjob_2", nil, fmt.Errorf("boom"))
+	if job, _ := s.GetImageJob(ctx, "imgjob_2"); job.Status != types.ImageJobFailed || job.Error.Message != "boom" {
+		t.Errorf("失败任务的状态错误: %+v", job)
+	}
+	if _, err := s.GetImageJob(ctx, "unknown"); err == nil {
+		t.Error("不存在的任务未返回错误")
+	}
+}
+
+// TestImageJobScope 测试任务 ID 中的账号令牌不暴露账号名，无法识别的令牌按任务不存在处理
+func TestImageJobScope(t *testing.T) {
+	scope := imageJobScope("account-1")
+	if strings.Contains(scope, "account") || scope != imageJobScope("account-1") || scope == imageJobScope("account-2") {
+		t.Errorf("账号令牌错误: %s", scope)
+	}
+
+	s := &imageService{jobs: newImageJobStore()}
+	_, err := s.GetImageJob(context.Background(), "imgjob_1-"+scope)
+	if appErr, ok := err.(*errors.AppError); !ok || appErr.Code != errors.ErrNotFound {
+		t.Errorf("无法识别的账号令牌应返回任务不存在: %v", err)
+	}
+}
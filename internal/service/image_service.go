@@ -2,11 +2,17 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"monica-proxy/internal/account"
 	"monica-proxy/internal/config"
 	"monica-proxy/internal/errors"
 	"monica-proxy/internal/logger"
 	"monica-proxy/internal/monica"
 	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -15,51 +21,333 @@ import (
 type ImageService interface {
 	// GenerateImage 生成图像
 	GenerateImage(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error)
+	// GetImageFile 读取本地保存的图片，校验链接的签名与有效期
+	GetImageFile(ctx context.Context, id, expires, signature string) ([]byte, string, error)
+	// CreateImageJob 提交异步图像生成任务
+	CreateImageJob(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageJob, error)
+	// GetImageJob 查询异步任务的状态
+	GetImageJob(ctx context.Context, id string) (*types.ImageJob, error)
+	// WatchImageJob 持续推送任务状态，直到任务结束或 ctx 取消
+	WatchImageJob(ctx context.Context, id string, fn func(*types.ImageJob) error) error
+	// EditImage 按提示词编辑图像，有蒙版时只重绘蒙版的透明区域
+	EditImage(ctx context.Context, req *types.ImageEditRequest) (*types.ImageGenerationResponse, error)
+	// CreateImageVariation 生成相似的图像
+	CreateImageVariation(ctx context.Context, req *types.ImageEditRequest) (*types.ImageGenerationResponse, error)
 }
 
+// 图片的返回格式
+const (
+	ImageResponseURL     = "url"
+	ImageResponseB64JSON = "b64_json"
+)
+
 // imageService 图像服务实现
 type imageService struct {
 	config *config.Config
+	store  *imageStore // 本地存储，未启用时为空
+	jobs   *imageJobStore
 }
 
 // NewImageService 创建图像服务实例
 func NewImageService(cfg *config.Config) ImageService {
-	return &imageService{
+	s := &imageService{
 		config: cfg,
+		jobs:   newImageJobStore(),
+	}
+	if cfg.ImageStore.Enabled {
+		store, err := newImageStore(cfg.ImageStore)
+		if err != nil {
+			// 本地存储不可用时仍返回 Monica CDN 链接
+			logger.Error("初始化图片本地存储失败", zap.Error(err))
+		} else {
+			s.store = store
+		}
 	}
+	return s
 }
 
 // GenerateImage 生成图像
 func (s *imageService) GenerateImage(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+	monicaReq, err := s.prepareRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 调用Monica API生成图像
+	response, err := monica.GenerateImage(ctx, s.config, monicaReq)
+	if err != nil {
+		logger.Error("生成图像失败", zap.Error(err))
+		return nil, errors.NewImageGenerationError(err)
+	}
+
+	if err := s.deliverImages(ctx, req.ResponseFormat, response.Data); err != nil {
+		logger.Error("下载生成的图像失败", zap.Error(err))
+		return nil, errors.NewImageGenerationError(err)
+	}
+	return response, nil
+}
+
+// prepareRequest 校验请求并转换为 Monica 请求
+func (s *imageService) prepareRequest(req *types.ImageGenerationRequest) (*types.MonicaImageRequest, error) {
 	// 验证请求
 	if req.Prompt == "" {
 		return nil, errors.NewInvalidInputError("提示词不能为空", nil)
 	}
 
-	// 设置默认值
-	if req.Model == "" {
-		req.Model = "dall-e-3"
+	if err := validateResponseFormat(&req.ResponseFormat); err != nil {
+		return nil, err
 	}
-	if req.N <= 0 {
-		req.N = 1
-	}
-	if req.Size == "" {
-		req.Size = "1024x1024"
+
+	// 校验模型、尺寸等参数并转换为 Monica 请求
+	monicaReq, err := types.NewMonicaImageRequest(s.config, req)
+	if err != nil {
+		return nil, err
 	}
 
 	// 日志记录请求
 	logger.Info("处理图像生成请求",
 		zap.String("model", req.Model),
+		zap.String("model_type", monicaReq.ModelType),
 		zap.String("size", req.Size),
+		zap.String("aspect_ratio", monicaReq.AspectRatio),
 		zap.Int("count", req.N),
+		zap.String("user", req.User),
+		zap.Bool("async", req.Async),
 	)
+	return monicaReq, nil
+}
 
-	// 调用Monica API生成图像
-	response, err := monica.GenerateImage(ctx, s.config, req)
+// EditImage 编辑图像
+func (s *imageService) EditImage(ctx context.Context, req *types.ImageEditRequest) (*types.ImageGenerationResponse, error) {
+	return s.transformImage(ctx, req, false)
+}
+
+// CreateImageVariation 生成相似的图像
+func (s *imageService) CreateImageVariation(ctx context.Context, req *types.ImageEditRequest) (*types.ImageGenerationResponse, error) {
+	return s.transformImage(ctx, req, true)
+}
+
+// transformImage 上传原图与蒙版后提交图生图任务，结果与文生图一样按 response_format 返回
+func (s *imageService) transformImage(ctx context.Context, req *types.ImageEditRequest, variation bool) (*types.ImageGenerationResponse, error) {
+	if err := validateResponseFormat(&req.ResponseFormat); err != nil {
+		return nil, err
+	}
+	monicaReq, err := types.NewMonicaImageEditRequest(ctx, s.config, req, variation)
 	if err != nil {
-		logger.Error("生成图像失败", zap.Error(err))
-		return nil, errors.NewImageGenerationError(err)
+		logger.Error("转换图像编辑请求失败", zap.Error(err))
+		return nil, err
 	}
 
+	logger.Info("处理图像编辑请求",
+		zap.String("model", req.Model),
+		zap.String("task_type", monicaReq.TaskType),
+		zap.String("aspect_ratio", monicaReq.AspectRatio),
+		zap.Int("count", req.N),
+		zap.String("user", req.User),
+	)
+
+	response, err := monica.GenerateImage(ctx, s.config, monicaReq)
+	if err != nil {
+		logger.Error("编辑图像失败", zap.Error(err))
+		return nil, errors.NewImageGenerationError(err)
+	}
+	if err := s.deliverImages(ctx, req.ResponseFormat, response.Data); err != nil {
+		logger.Error("下载生成的图像失败", zap.Error(err))
+		return nil, errors.NewImageGenerationError(err)
+	}
 	return response, nil
 }
+
+// validateResponseFormat 校验 response_format，未指定时为 url
+func validateResponseFormat(format *string) error {
+	if *format == "" {
+		*format = ImageResponseURL
+	}
+	if *format != ImageResponseURL && *format != ImageResponseB64JSON {
+		return errors.NewInvalidInputError(fmt.Sprintf("不支持的 response_format: %s", *format), nil)
+	}
+	return nil
+}
+
+// CreateImageJob 提交任务后立即返回，由后台等待生成完成
+func (s *imageService) CreateImageJob(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageJob, error) {
+	monicaReq, err := s.prepareRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	task, err := monica.SubmitImageTask(ctx, s.config, monicaReq)
+	if err != nil {
+		logger.Error("提交图像生成任务失败", zap.Error(err))
+		return nil, errors.NewImageGenerationError(err)
+	}
+
+	// 任务只能由提交它的账号查询，多账号时 ID 中带上标识账号的令牌
+	id := imageJobIDPrefix + strconv.Itoa(task.ID)
+	if scope := account.Scope(ctx); scope != "" {
+		id += "-" + imageJobScope(scope)
+	}
+	job := types.ImageJob{
+		ID:           id,
+		Object:       "image.generation.job",
+		Status:       types.ImageJobInProgress,
+		Model:        req.Model,
+		CreatedAt:    task.SubmittedAt.Unix(),
+		ExpectedTime: int(task.ExpectedTime / time.Second),
+	}
+	s.jobs.Put(job, task)
+	// 请求已经返回，任务使用独立的 ctx 并继续占用提交任务的账号，截止时间由 WaitImageTask 按预估时间设置
+	jobCtx, release := account.Detach(ctx)
+	go s.runImageJob(jobCtx, release, job.ID, task, req.ResponseFormat)
+	return &job, nil
+}
+
+// runImageJob 等待任务完成并记录结果，结束后调用 release 释放账号
+func (s *imageService) runImageJob(ctx context.Context, release func(), id string, task *monica.ImageTask, format string) {
+	defer release()
+	urls, err := monica.WaitImageTask(ctx, s.config, task)
+	var response *types.ImageGenerationResponse
+	if err == nil {
+		response = monica.NewImageResponse(task.Prompt, urls)
+		err = s.deliverImages(ctx, format, response.Data)
+	}
+	if err != nil {
+		logger.Error("异步图像生成失败", zap.String("job_id", id), zap.Error(err))
+	}
+	s.jobs.Finish(id, response, err)
+}
+
+// GetImageJob 查询任务状态，本地没有记录时（如重启后）按 image_tools_id 向上游查询一次
+func (s *imageService) GetImageJob(ctx context.Context, id string) (*types.ImageJob, error) {
+	if job, _, ok := s.jobs.Get(id); ok {
+		return &job, nil
+	}
+
+	rawID, scope, scoped := strings.Cut(strings.TrimPrefix(id, imageJobIDPrefix), "-")
+	imageToolsID, err := strconv.Atoi(rawID)
+	if err != nil || !strings.HasPrefix(id, imageJobIDPrefix) {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("任务不存在: %s", id))
+	}
+	var accountName string
+	if scoped {
+		if accountName = resolveImageJobScope(scope); accountName == "" {
+			return nil, errors.NewNotFoundError(fmt.Sprintf("任务不存在: %s", id))
+		}
+	}
+	if !account.Prefer(ctx, accountName) {
+		return nil, errors.NewRequestFailedError("查询图像生成任务失败", fmt.Errorf("account %s is unavailable", accountName))
+	}
+	urls, err := monica.PollImageTask(ctx, s.config, imageToolsID)
+	if err != nil {
+		return nil, errors.NewRequestFailedError("查询图像生成任务失败", err)
+	}
+	job := &types.ImageJob{
+		ID:     id,
+		Object: "image.generation.job",
+		Status: types.ImageJobInProgress,
+	}
+	if len(urls) > 0 {
+		// 没有原始请求，只能返回 CDN 链接
+		job.Status = types.ImageJobSucceeded
+		job.Progress = 100
+		job.Result = monica.NewImageResponse("", urls)
+	}
+	return job, nil
+}
+
+// WatchImageJob 任务变更或进度变化时调用 fn，任务结束后返回
+func (s *imageService) WatchImageJob(ctx context.Context, id string, fn func(*types.ImageJob) error) error {
+	if _, _, ok := s.jobs.Get(id); !ok {
+		// 本地没有记录的任务只推送一次当前状态
+		job, err := s.GetImageJob(ctx, id)
+		if err != nil {
+			return err
+		}
+		return fn(job)
+	}
+
+	ticker := time.NewTicker(imageJobProgressInterval)
+	defer ticker.Stop()
+	lastProgress := -1
+	for {
+		job, updated, ok := s.jobs.Get(id)
+		if !ok {
+			return errors.NewNotFoundError(fmt.Sprintf("任务不存在: %s", id))
+		}
+		if job.Done() || job.Progress != lastProgress {
+			if err := fn(&job); err != nil {
+				return err
+			}
+			lastProgress = job.Progress
+		}
+		if job.Done() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-updated:
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverImages 按 response_format 返回图片
+// b64_json 时下载 CDN 图片并编码；url 时如果启用了本地存储，保存到本地并返回签名路径，由路由补全为完整链接
+func (s *imageService) deliverImages(ctx context.Context, format string, images []types.ImageGenerationData) error {
+	if format == ImageResponseURL && s.store == nil {
+		return nil
+	}
+	for i := range images {
+		data, err := downloadImage(ctx, images[i].URL)
+		if err != nil {
+			if format == ImageResponseURL {
+				// 保存失败时退回为 CDN 链接
+				logger.Warn("保存生成的图像失败，返回 CDN 链接", zap.Error(err))
+				continue
+			}
+			return err
+		}
+
+		if format == ImageResponseB64JSON {
+			images[i].B64JSON = utils.Base64Encode(data)
+			images[i].URL = ""
+			continue
+		}
+		id, err := s.store.Put(data)
+		if err != nil {
+			logger.Warn("保存生成的图像失败，返回 CDN 链接", zap.Error(err))
+			continue
+		}
+		images[i].URL = s.store.SignedPath(id)
+	}
+	return nil
+}
+
+// GetImageFile 读取本地保存的图片
+func (s *imageService) GetImageFile(ctx context.Context, id, expires, signature string) ([]byte, string, error) {
+	if s.store == nil {
+		return nil, "", errors.NewNotFoundError("未启用图片本地存储")
+	}
+	if !s.store.Verify(id, expires, signature) {
+		return nil, "", errors.NewUnauthorizedError("图片链接签名无效或已过期")
+	}
+	data, mimeType, ok := s.store.Get(id)
+	if !ok {
+		return nil, "", errors.NewNotFoundError(fmt.Sprintf("图片不存在: %s", id))
+	}
+	return data, mimeType, nil
+}
+
+// downloadImage 从 Monica CDN 下载生成的图片
+func downloadImage(ctx context.Context, url string) ([]byte, error) {
+	resp, err := utils.RestyDefaultClient.R().
+		SetContext(ctx).
+		Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("download image failed: %w", err)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("download image failed: status %d", resp.StatusCode())
+	}
+	return resp.Body(), nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"monica-proxy/internal/config"
+
+	"github.com/google/uuid"
+)
+
+// ImageFilePath 本地图片的访问路径前缀，签名链接自带鉴权，不需要 Bearer Token
+const ImageFilePath = "/v1/images/files/"
+
+// storedImage 保存在本地的生成图片
+type storedImage struct {
+	id        string
+	name      string // 目录中的文件名，为 id 加扩展名
+	size      int64
+	createdAt time.Time
+}
+
+// imageStore 生成图片的本地存储，按保留时长与总大小上限清理最早的图片
+type imageStore struct {
+	mu        sync.Mutex
+	dir       string
+	retention time.Duration
+	maxBytes  int64
+	urlTTL    time.Duration
+	key       []byte
+	images    map[string]*storedImage
+	total     int64
+}
+
+// newImageStore 创建本地存储，加载目录中已有的图片
+func newImageStore(cfg config.ImageStoreConfig) (*imageStore, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create image store dir failed: %w", err)
+	}
+	key := []byte(cfg.SigningKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate signing key failed: %w", err)
+		}
+	}
+	s := &imageStore{
+		dir:       cfg.Dir,
+		retention: cfg.Retention,
+		maxBytes:  cfg.MaxBytes,
+		urlTTL:    cfg.URLTTL,
+		key:       key,
+		images:    make(map[string]*storedImage),
+	}
+
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("read image store dir failed: %w", err)
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		s.images[id] = &storedImage{id: id, name: entry.Name(), size: info.Size(), createdAt: info.ModTime()}
+		s.total += info.Size()
+	}
+	s.prune()
+	go func() {
+		// 定期清理过期图片，没有新图片时也能及时释放磁盘空间
+		for range time.Tick(min(cfg.Retention, 10*time.Minute)) {
+			s.prune()
+		}
+	}()
+	return s, nil
+}
+
+// Put 保存图片，返回图片 ID，空间不足时先删除最早的图片
+func (s *imageStore) Put(data []byte) (string, error) {
+	size := int64(len(data))
+	if size > s.maxBytes {
+		return "", fmt.Errorf("image size exceeds store limit: %d > %d", size, s.maxBytes)
+	}
+	id := "img-" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	name := id + imageExtension(http.DetectContentType(data))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(size)
+	if err := os.WriteFile(filepath.Join(s.dir, name), data, 0o644); err != nil {
+		return "", fmt.Errorf("write image failed: %w", err)
+	}
+	s.images[id] = &storedImage{id: id, name: name, size: size, createdAt: time.Now()}
+	s.total += size
+	return id, nil
+}
+
+// Get 读取未过期的图片，返回内容与 MIME 类型
+func (s *imageStore) Get(id string) ([]byte, string, bool) {
+	s.mu.Lock()
+	image, ok := s.images[id]
+	if ok && time.Since(image.createdAt) > s.retention {
+		s.removeLocked(image)
+		ok = false
+	}
+	s.mu.Unlock()
+	if !ok {
+		return nil, "", false
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, image.name))
+	if err != nil {
+		return nil, "", false
+	}
+	return data, http.DetectContentType(data), true
+}
+
+// SignedPath 生成图片的签名访问路径，链接有效期不超过图片的保留时长
+func (s *imageStore) SignedPath(id string) string {
+	expires := time.Now().Add(min(s.urlTTL, s.retention)).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", s.sign(id, expires))
+	return ImageFilePath + id + "?" + query.Encode()
+}
+
+// Verify 校验签名与有效期
+func (s *imageStore) Verify(id, expires, signature string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.sign(id, exp)))
+}
+
+func (s *imageStore) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(id + "." + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *imageStore) prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(0)
+}
+
+// pruneLocked 删除超过保留时长的图片，再按从旧到新删除图片直到能放下 incoming 字节，调用方需持有锁
+func (s *imageStore) pruneLocked(incoming int64) {
+	images := make([]*storedImage, 0, len(s.images))
+	for _, image := range s.images {
+		if time.Since(image.createdAt) > s.retention {
+			s.removeLocked(image)
+			continue
+		}
+		images = append(images, image)
+	}
+	if s.total+incoming <= s.maxBytes {
+		return
+	}
+	slices.SortFunc(images, func(a, b *storedImage) int {
+		return a.createdAt.Compare(b.createdAt)
+	})
+	for _, image := range images {
+		if s.total+incoming <= s.maxBytes {
+			break
+		}
+		s.removeLocked(image)
+	}
+}
+
+func (s *imageStore) removeLocked(image *storedImage) {
+	_ = os.Remove(filepath.Join(s.dir, image.name))
+	delete(s.images, image.id)
+	s.total -= image.size
+}
+
+// imageExtension 根据 MIME 类型确定文件扩展名
+func imageExtension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/png":
+		return ".png"
+	}
+	return ".bin"
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n0000000000")
+
+func testImageStoreConfig(dir string) config.ImageStoreConfig {
+	return config.ImageStoreConfig{
+		Enabled:    true,
+		Dir:        dir,
+		Retention:  time.Hour,
+		MaxBytes:   1024,
+		URLTTL:     time.Minute,
+		SigningKey: "test-key",
+	}
+}
+
+// TestImageStoreSignedPath 测试签名链接的校验与过期
+func TestImageStoreSignedPath(t *testing.T) {
+	store, err := newImageStore(testImageStoreConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := store.Put(testPNG)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := store.SignedPath(id)
+	u, err := url.Parse(path)
+	if err != nil || u.Path != ImageFilePath+id {
+		t.Fatalf("签名路径错误: %s", path)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	if !store.Verify(id, expires, signature) {
+		t.Fatal("有效的签名未通过校验")
+	}
+	if store.Verify("img-other", expires, signature) {
+		t.Error("其他图片的签名通过了校验")
+	}
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	if store.Verify(id, past, store.sign(id, time.Now().Add(-time.Minute).Unix())) {
+		t.Error("过期的链接通过了校验")
+	}
+
+	data, mimeType, ok := store.Get(id)
+	if !ok || !bytes.Equal(data, testPNG) || mimeType != "image/png" {
+		t.Fatalf("读取图片错误: ok=%v mime=%s", ok, mimeType)
+	}
+}
+
+// TestImageStoreQuota 测试超出总大小上限时删除最早的图片，重启后重新加载已有图片
+func TestImageStoreQuota(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testImageStoreConfig(dir)
+	cfg.MaxBytes = int64(len(testPNG)) * 2
+	store, err := newImageStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := store.Put(testPNG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, _, ok := store.Get(ids[0]); ok {
+		t.Error("最早的图片未被删除")
+	}
+	if _, _, ok := store.Get(ids[2]); !ok {
+		t.Error("最新的图片被删除")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("目录中的图片数错误: %d", len(entries))
+	}
+
+	reloaded, err := newImageStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := reloaded.Get(ids[1]); !ok || reloaded.total != store.total {
+		t.Errorf("重新加载的图片错误: total=%d", reloaded.total)
+	}
+}
+
+// fakeCDNTransport 模拟 Monica CDN 返回图片
+type fakeCDNTransport struct{}
+
+func (fakeCDNTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"image/png"}},
+		Body:       io.NopCloser(bytes.NewReader(testPNG)),
+		Request:    req,
+	}, nil
+}
+
+// TestDeliverImages 测试 b64_json 与本地存储两种返回方式
+func TestDeliverImages(t *testing.T) {
+	original := utils.RestyDefaultClient
+	defer func() { utils.RestyDefaultClient = original }()
+	utils.RestyDefaultClient = resty.New().SetTransport(fakeCDNTransport{})
+
+	s := &imageService{config: &config.Config{}}
+	images := []types.ImageGenerationData{{URL: "https://cdn.example.com/a.png"}}
+	if err := s.deliverImages(context.Background(), ImageResponseB64JSON, images); err != nil {
+		t.Fatal(err)
+	}
+	if images[0].URL != "" || images[0].B64JSON != utils.Base64Encode(testPNG) {
+		t.Errorf("b64_json 结果错误: %+v", images[0])
+	}
+
+	store, err := newImageStore(testImageStoreConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.store = store
+	images = []types.ImageGenerationData{{URL: "https://cdn.example.com/a.png"}}
+	if err := s.deliverImages(context.Background(), ImageResponseURL, images); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(images[0].URL, ImageFilePath) {
+		t.Errorf("未返回本地链接: %s", images[0].URL)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+	"monica-proxy/internal/logger"
+	"monica-proxy/internal/monica"
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// MessagesService Anthropic Messages 服务接口
+type MessagesService interface {
+	// HandleMessages 处理 Anthropic Messages 请求
+	// 流式请求返回 *monica.CompletionStream，非流式请求返回 *types.AnthropicMessagesResponse
+	HandleMessages(ctx context.Context, req *types.AnthropicMessagesRequest) (interface{}, error)
+}
+
+// messagesService Anthropic Messages 服务实现
+type messagesService struct {
+	config           *config.Config
+	chatService      ChatService
+	customBotService CustomBotService
+}
+
+// NewMessagesService 创建 Anthropic Messages 服务实例
+func NewMessagesService(cfg *config.Config, chatService ChatService, customBotService CustomBotService) MessagesService {
+	return &messagesService{
+		config:           cfg,
+		chatService:      chatService,
+		customBotService: customBotService,
+	}
+}
+
+// HandleMessages 处理 Anthropic Messages 请求
+func (s *messagesService) HandleMessages(ctx context.Context, req *types.AnthropicMessagesRequest) (interface{}, error) {
+	if len(req.Messages) == 0 {
+		return nil, errors.NewEmptyMessageError()
+	}
+
+	chatReq, err := types.AnthropicToChatGPT(req)
+	if err != nil {
+		return nil, errors.NewInvalidInputError("无效的Messages请求", err)
+	}
+	if req.TopK != nil {
+		// Monica 不支持 top_k，通过响应头告知客户端参数未生效
+		utils.AddResponseHeader(ctx, utils.WarningHeader, "top_k is not supported and was ignored")
+	}
+
+	stream, err := openUpstreamStream(ctx, s.config, s.chatService, s.customBotService, &chatReq)
+	if err != nil {
+		return nil, err
+	}
+	if req.Stream {
+		return stream, nil
+	}
+	defer stream.Close()
+
+	response, err := monica.CollectMonicaSSEToAnthropic(ctx, stream, req.Thinking.Enabled())
+	if err != nil {
+		logger.Error("处理Monica响应失败", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	return response, nil
+}
+
+// openUpstreamStream 按当前模式调用 Monica，返回上游 SSE 流
+// Monica 上游始终是 SSE，其他协议的接口统一拿原始流再按各自协议输出
+func openUpstreamStream(ctx context.Context, cfg *config.Config, chatService ChatService, customBotService CustomBotService, chatReq *openai.ChatCompletionRequest) (*monica.CompletionStream, error) {
+	chatReq.Stream = true
+
+	var result interface{}
+	var err error
+	if cfg.Monica.EnableCustomBotMode {
+		result, err = customBotService.HandleCustomBotChat(ctx, chatReq, cfg.Monica.BotUID)
+	} else {
+		result, err = chatService.HandleChatCompletion(ctx, chatReq)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stream, ok := result.(*monica.CompletionStream)
+	if !ok {
+		return nil, errors.NewInternalError(nil)
+	}
+	return stream, nil
+}
@@ -4,6 +4,7 @@ import (
 	"monica-proxy/internal/config"
 	"monica-proxy/internal/logger"
 	"monica-proxy/internal/types"
+	"slices"
 
 	"go.uber.org/zap"
 )
@@ -26,9 +27,15 @@ func NewModelService(cfg *config.Config) ModelService {
 	}
 }
 
-// GetSupportedModels 获取支持的模型列表
+// GetSupportedModels 获取支持的模型列表，包括对话模型与图像生成模型
 func (s *modelService) GetSupportedModels() []string {
 	models := types.GetSupportedModels()
+	// 图像生成模型只能用于 /v1/images/generations
+	for _, model := range types.GetImageModels(s.config) {
+		if !slices.Contains(models, model) {
+			models = append(models, model)
+		}
+	}
 	
 	logger.Info("获取支持的模型列表",
 		zap.Int("model_count", len(models)),
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"monica-proxy/internal/cache"
+	"monica-proxy/internal/types"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// storedResponse 已保存的响应及其完整对话历史
+type storedResponse struct {
+	response *types.Response
+	messages []openai.ChatCompletionMessage // 截止到该响应（含输出）的对话，不含 instructions
+}
+
+// responseStore 本地响应存储，用于 GET/DELETE 以及 previous_response_id 续接
+// 响应保存 TTL 后过期，超出容量时淘汰最久未使用的响应，续接会刷新被引用响应的使用顺序
+type responseStore struct {
+	entries *cache.LRU[*storedResponse]
+}
+
+// newResponseStore 创建响应存储
+func newResponseStore(ttl time.Duration, maxEntries int) *responseStore {
+	// 不配置持久化路径时创建缓存不会失败
+	entries, _ := cache.New[*storedResponse](cache.Options{MaxEntries: maxEntries, TTL: ttl})
+	return &responseStore{entries: entries}
+}
+
+// Get 获取未过期的响应
+func (rs *responseStore) Get(id string) (*storedResponse, bool) {
+	return rs.entries.Get(id)
+}
+
+// Put 保存响应
+func (rs *responseStore) Put(resp *types.Response, messages []openai.ChatCompletionMessage) {
+	rs.entries.Set(resp.ID, &storedResponse{response: resp, messages: messages}, 0)
+}
+
+// Delete 删除响应，返回是否存在
+func (rs *responseStore) Delete(id string) bool {
+	if _, exists := rs.entries.Get(id); !exists {
+		return false
+	}
+	rs.entries.Delete(id)
+	return true
+}
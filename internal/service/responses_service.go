@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+	"monica-proxy/internal/logger"
+	"monica-proxy/internal/monica"
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// ResponsesService OpenAI Responses 服务接口
+type ResponsesService interface {
+	// CreateResponse 创建响应
+	// 流式请求返回 *ResponseStream，非流式请求返回 *types.Response
+	CreateResponse(ctx context.Context, req *types.ResponsesRequest) (interface{}, error)
+	// GetResponse 获取已保存的响应
+	GetResponse(id string) (*types.Response, error)
+	// DeleteResponse 删除已保存的响应
+	DeleteResponse(id string) (*types.ResponseDeleted, error)
+}
+
+// responsesService OpenAI Responses 服务实现
+type responsesService struct {
+	config           *config.Config
+	chatService      ChatService
+	customBotService CustomBotService
+	store            *responseStore
+}
+
+// NewResponsesService 创建 OpenAI Responses 服务实例
+func NewResponsesService(cfg *config.Config, chatService ChatService, customBotService CustomBotService) ResponsesService {
+	return &responsesService{
+		config:           cfg,
+		chatService:      chatService,
+		customBotService: customBotService,
+		store:            newResponseStore(cfg.Responses.StoreTTL, cfg.Responses.StoreMaxEntries),
+	}
+}
+
+// ResponseStream 流式 Responses 结果，输出完成后按需保存到响应存储
+type ResponseStream struct {
+	stream   *monica.CompletionStream
+	response *types.Response
+	history  []openai.ChatCompletionMessage
+	store    *responseStore
+}
+
+// Stream 将上游 SSE 转换为 Responses 语义事件写给客户端
+func (s *ResponseStream) Stream(ctx context.Context, w io.Writer) error {
+	if err := monica.StreamMonicaSSEToResponses(ctx, s.response, w, s.stream); err != nil {
+		return err
+	}
+	if s.response.Store {
+		s.store.Put(s.response, appendResponseOutput(s.history, s.response))
+	}
+	return nil
+}
+
+// Close 关闭上游响应体
+func (s *ResponseStream) Close() error {
+	return s.stream.Close()
+}
+
+// CreateResponse 创建响应
+func (s *responsesService) CreateResponse(ctx context.Context, req *types.ResponsesRequest) (interface{}, error) {
+	if len(req.Input) == 0 {
+		return nil, errors.NewEmptyMessageError()
+	}
+
+	// 续接上一轮响应的对话历史
+	var history []openai.ChatCompletionMessage
+	if req.PreviousResponseID != "" {
+		previous, ok := s.store.Get(req.PreviousResponseID)
+		if !ok {
+			return nil, errors.NewInvalidInputError(fmt.Sprintf("未找到上一轮响应: %s", req.PreviousResponseID), nil)
+		}
+		history = append(history, previous.messages...)
+	}
+
+	inputMessages, err := types.ResponsesInputToMessages(req.Input)
+	if err != nil {
+		return nil, errors.NewInvalidInputError("无效的input", err)
+	}
+	history = append(history, inputMessages...)
+
+	chatReq := openai.ChatCompletionRequest{
+		Model:      req.Model,
+		MaxTokens:  req.MaxOutputTokens,
+		User:       req.User,
+		Tools:      types.ResponsesToolsToChatGPT(req.Tools),
+		ToolChoice: req.ToolChoice,
+		// text.format 的 JSON 模式与 ChatCompletion 的 response_format 一致处理
+		ResponseFormat: req.Text.ChatGPTResponseFormat(),
+	}
+	if req.ParallelToolCalls != nil {
+		chatReq.ParallelToolCalls = *req.ParallelToolCalls
+	}
+	if req.Temperature != nil {
+		chatReq.Temperature = *req.Temperature
+	}
+	if req.TopP != nil {
+		chatReq.TopP = *req.TopP
+	}
+	// instructions 只作用于当前请求，不随 previous_response_id 继承
+	if req.Instructions != "" {
+		chatReq.Messages = append(chatReq.Messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: req.Instructions,
+		})
+	}
+	chatReq.Messages = append(chatReq.Messages, history...)
+
+	stream, err := openUpstreamStream(ctx, s.config, s.chatService, s.customBotService, &chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	response := newResponse(req)
+	if req.Stream {
+		return &ResponseStream{
+			stream:   stream,
+			response: response,
+			history:  history,
+			store:    s.store,
+		}, nil
+	}
+	defer stream.Close()
+
+	if err := monica.CollectMonicaSSEToResponse(ctx, response, stream); err != nil {
+		logger.Error("处理Monica响应失败", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	if response.Store {
+		s.store.Put(response, appendResponseOutput(history, response))
+	}
+	return response, nil
+}
+
+// GetResponse 获取已保存的响应
+func (s *responsesService) GetResponse(id string) (*types.Response, error) {
+	entry, ok := s.store.Get(id)
+	if !ok {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("响应不存在: %s", id))
+	}
+	return entry.response, nil
+}
+
+// DeleteResponse 删除已保存的响应
+func (s *responsesService) DeleteResponse(id string) (*types.ResponseDeleted, error) {
+	if !s.store.Delete(id) {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("响应不存在: %s", id))
+	}
+	return &types.ResponseDeleted{
+		ID:      id,
+		Object:  "response.deleted",
+		Deleted: true,
+	}, nil
+}
+
+// newResponse 根据请求构造进行中的响应对象
+func newResponse(req *types.ResponsesRequest) *types.Response {
+	store := true
+	if req.Store != nil {
+		store = *req.Store
+	}
+	metadata := req.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return &types.Response{
+		ID:                 "resp_" + utils.RandStringUsingMathRand(32),
+		Object:             "response",
+		CreatedAt:          time.Now().Unix(),
+		Status:             "in_progress",
+		Model:              req.Model,
+		Output:             []types.ResponseOutputItem{},
+		Instructions:       req.Instructions,
+		PreviousResponseID: req.PreviousResponseID,
+		MaxOutputTokens:    req.MaxOutputTokens,
+		Temperature:        req.Temperature,
+		TopP:               req.TopP,
+		User:               req.User,
+		Metadata:           metadata,
+		Store:              store,
+	}
+}
+
+// appendResponseOutput 将响应输出作为 assistant 消息追加到对话历史
+func appendResponseOutput(history []openai.ChatCompletionMessage, resp *types.Response) []openai.ChatCompletionMessage {
+	messages := make([]openai.ChatCompletionMessage, 0, len(history)+1)
+	messages = append(messages, history...)
+	return append(messages, openai.ChatCompletionMessage{
+		Role:      openai.ChatMessageRoleAssistant,
+		Content:   resp.OutputText(),
+		ToolCalls: resp.ToolCalls(),
+	})
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/monica"
+	"monica-proxy/internal/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeChatService 记录收到的请求，按顺序返回预设的回复
+type fakeChatService struct {
+	replies  []string
+	requests []openai.ChatCompletionRequest
+}
+
+func (f *fakeChatService) HandleChatCompletion(ctx context.Context, req *openai.ChatCompletionRequest) (interface{}, error) {
+	f.requests = append(f.requests, *req)
+	reply := f.replies[len(f.requests)-1]
+	body := `data: {"text":"` + reply + `"}` + "\n\n" + `data: {"text":"","finished":true}` + "\n\n"
+	return &monica.CompletionStream{
+		Body:    io.NopCloser(strings.NewReader(body)),
+		Options: monica.NewCompletionOptions(req),
+	}, nil
+}
+
+// newTestResponsesService 创建使用 fakeChatService 的 Responses 服务
+func newTestResponsesService(replies ...string) (*responsesService, *fakeChatService) {
+	chat := &fakeChatService{replies: replies}
+	cfg := &config.Config{}
+	cfg.Responses.StoreTTL = time.Hour
+	cfg.Responses.StoreMaxEntries = 2
+	return NewResponsesService(cfg, chat, nil).(*responsesService), chat
+}
+
+// responsesRequest 解析 JSON 构造 Responses 请求
+func responsesRequest(t *testing.T, body string) *types.ResponsesRequest {
+	t.Helper()
+	var req types.ResponsesRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	return &req
+}
+
+// TestResponsesChaining 测试 previous_response_id 续接对话历史，instructions 不随续接继承
+func TestResponsesChaining(t *testing.T) {
+	s, chat := newTestResponsesService("Hi Bob", "Your name is Bob")
+	ctx := context.Background()
+
+	result, err := s.CreateResponse(ctx, responsesRequest(t, `{"model":"gpt-4o","instructions":"Be brief.","input":"I am Bob"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := result.(*types.Response)
+	if first.Status != "completed" || first.OutputText() != "Hi Bob" {
+		t.Fatalf("第一轮响应错误: %+v", first)
+	}
+
+	result, err = s.CreateResponse(ctx, responsesRequest(t, `{"model":"gpt-4o","input":"Who am I?","previous_response_id":"`+first.ID+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := result.(*types.Response)
+	if second.PreviousResponseID != first.ID || second.OutputText() != "Your name is Bob" {
+		t.Errorf("第二轮响应错误: %+v", second)
+	}
+
+	var roles, contents []string
+	for _, msg := range chat.requests[1].Messages {
+		roles = append(roles, msg.Role)
+		contents = append(contents, msg.Content)
+	}
+	if strings.Join(roles, ",") != "user,assistant,user" || strings.Join(contents, "|") != "I am Bob|Hi Bob|Who am I?" {
+		t.Errorf("续接的对话历史错误: %v %v", roles, contents)
+	}
+
+	if _, err := s.CreateResponse(ctx, responsesRequest(t, `{"model":"gpt-4o","input":"x","previous_response_id":"resp_missing"}`)); err == nil {
+		t.Error("上一轮响应不存在时应返回错误")
+	}
+}
+
+// TestResponsesStore 测试响应的获取、删除、store=false 与容量淘汰
+func TestResponsesStore(t *testing.T) {
+	s, _ := newTestResponsesService("a", "b", "c", "d")
+	ctx := context.Background()
+
+	create := func(body string) *types.Response {
+		result, err := s.CreateResponse(ctx, responsesRequest(t, body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.(*types.Response)
+	}
+
+	unstored := create(`{"model":"gpt-4o","input":"a","store":false}`)
+	if _, err := s.GetResponse(unstored.ID); err == nil {
+		t.Error("store=false 的响应不应被保存")
+	}
+
+	first := create(`{"model":"gpt-4o","input":"b"}`)
+	if got, err := s.GetResponse(first.ID); err != nil || got.OutputText() != "b" {
+		t.Errorf("获取响应失败: %+v %v", got, err)
+	}
+	if _, err := s.DeleteResponse(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteResponse(first.ID); err == nil {
+		t.Error("重复删除应返回错误")
+	}
+
+	// 容量为 2，第三个响应淘汰最久未使用的响应
+	second := create(`{"model":"gpt-4o","input":"c"}`)
+	third := create(`{"model":"gpt-4o","input":"d","previous_response_id":"` + second.ID + `"}`)
+	if _, err := s.GetResponse(second.ID); err != nil {
+		t.Errorf("最近被续接的响应不应被淘汰: %v", err)
+	}
+	if _, err := s.GetResponse(third.ID); err != nil {
+		t.Errorf("最新的响应不应被淘汰: %v", err)
+	}
+}
+
+// TestResponsesStream 测试流式响应输出完成后保存，可用于续接
+func TestResponsesStream(t *testing.T) {
+	s, _ := newTestResponsesService("streamed")
+	result, err := s.CreateResponse(context.Background(), responsesRequest(t, `{"model":"gpt-4o","input":"hi","stream":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := result.(*ResponseStream)
+	defer stream.Close()
+
+	var buf bytes.Buffer
+	if err := stream.Stream(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []string{"response.created", "response.output_text.delta", "response.completed"} {
+		if !strings.Contains(buf.String(), "event: "+event) {
+			t.Errorf("流式输出缺少 %s 事件:\n%s", event, buf.String())
+		}
+	}
+	if got, err := s.GetResponse(stream.response.ID); err != nil || got.OutputText() != "streamed" {
+		t.Errorf("流式响应未保存: %+v %v", got, err)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+	"monica-proxy/internal/logger"
+	"monica-proxy/internal/monica"
+	"monica-proxy/internal/structured"
+	"monica-proxy/internal/toolcall"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// streamOpener 发起一次上游请求并返回 SSE 流
+type streamOpener func(ctx context.Context, req *openai.ChatCompletionRequest) (*monica.CompletionStream, error)
+
+// validated 当前请求是否需要先收集完整回复再校验：结构化输出，或 tool_choice 要求必须调用工具
+func validated(req *openai.ChatCompletionRequest) bool {
+	return structured.Enabled(req) || toolcall.Forced(req)
+}
+
+// completeStructured 处理需要校验回复的单候选请求
+// 流式请求在校验通过后再把结果按流式格式输出
+func completeStructured(ctx context.Context, cfg *config.Config, req *openai.ChatCompletionRequest, open streamOpener) (interface{}, error) {
+	completion, err := collectStructured(ctx, cfg, req, open)
+	if err != nil {
+		return nil, err
+	}
+	if req.Stream {
+		return monica.NewReplayStream(completion, monica.NewCompletionOptions(req)), nil
+	}
+	return monica.NewCompletionResponse(req.Model, completion), nil
+}
+
+// collectStructured 收集符合 response_format 与 tool_choice 的完整回复
+// 先校验工具调用，再修复并校验 JSON，失败时把错误反馈给模型重新作答，最多重试 MaxRetries 次
+func collectStructured(ctx context.Context, cfg *config.Config, req *openai.ChatCompletionRequest, open streamOpener) (*monica.Completion, error) {
+	var format *structured.Format
+	if structured.Enabled(req) {
+		var err error
+		if format, err = structured.ParseFormat(req); err != nil {
+			return nil, errors.NewInvalidInputError("无效的response_format", err)
+		}
+	}
+
+	attemptReq := *req
+	attemptReq.Messages = append([]openai.ChatCompletionMessage(nil), req.Messages...)
+
+	for attempt := 0; ; attempt++ {
+		completion, err := collectCompletion(ctx, &attemptReq, open)
+		if err != nil {
+			return nil, err
+		}
+
+		var checkErr error
+		var correction string
+		toolErr := toolcall.CheckChoice(req, completion.ToolCalls)
+		if toolErr != nil {
+			checkErr, correction = toolErr, toolcall.CorrectionPrompt(toolErr)
+		} else if format != nil && len(completion.ToolCalls) == 0 {
+			// 模型选择调用工具时不校验正文
+			text, formatErr := format.Check(completion.Text)
+			if formatErr == nil {
+				completion.Text = text
+			}
+			checkErr, correction = formatErr, structured.CorrectionPrompt(formatErr)
+		}
+		if checkErr == nil {
+			return completion, nil
+		}
+
+		if attempt >= cfg.StructuredOutput.MaxRetries {
+			logger.Warn("回复校验失败",
+				zap.String("model", req.Model),
+				zap.Int("attempts", attempt+1),
+				zap.Error(checkErr),
+			)
+			if toolErr != nil {
+				return nil, errors.NewToolChoiceError(checkErr)
+			}
+			return nil, errors.NewStructuredOutputError(checkErr)
+		}
+
+		logger.Info("回复校验失败，重新请求上游",
+			zap.String("model", req.Model),
+			zap.Int("attempt", attempt+1),
+			zap.Error(checkErr),
+		)
+		reply := completion.Text
+		if len(completion.ToolCalls) > 0 {
+			reply = strings.TrimSpace(reply + "\n\n" + toolcall.FormatCalls(completion.ToolCalls))
+		}
+		attemptReq.Messages = append(attemptReq.Messages,
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply},
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: correction},
+		)
+	}
+}
+
+// collectCompletion 发起一次上游请求并收集完整回复
+func collectCompletion(ctx context.Context, req *openai.ChatCompletionRequest, open streamOpener) (*monica.Completion, error) {
+	stream, err := open(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	completion, err := monica.CollectCompletion(ctx, stream)
+	if err != nil {
+		logger.Error("处理Monica响应失败", zap.Error(err))
+		return nil, errors.NewInternalError(err)
+	}
+	return completion, nil
+}
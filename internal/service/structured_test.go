@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"strings"
+	"testing"
+
+	"monica-proxy/internal/config"
+	apperrors "monica-proxy/internal/errors"
+	"monica-proxy/internal/monica"
+	"monica-proxy/internal/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// replyOpener 按顺序返回预设回复的 streamOpener，并记录每次请求的消息
+func replyOpener(replies []string, requests *[][]openai.ChatCompletionMessage) streamOpener {
+	return func(ctx context.Context, req *openai.ChatCompletionRequest) (*monica.CompletionStream, error) {
+		reply := replies[len(*requests)]
+		*requests = append(*requests, req.Messages)
+		reply = strings.ReplaceAll(strings.ReplaceAll(reply, `"`, `\"`), "\n", `\n`)
+		body := `data: {"text":"` + reply + `"}` + "\n\n" + `data: {"text":"","finished":true}` + "\n\n"
+		return &monica.CompletionStream{
+			Body:    io.NopCloser(strings.NewReader(body)),
+			Options: monica.NewCompletionOptions(req),
+		}, nil
+	}
+}
+
+// TestForcedToolChoice 测试 tool_choice 要求调用工具时，未调用或调用了其他函数会重新请求，超过重试次数后返回错误
+func TestForcedToolChoice(t *testing.T) {
+	cfg := &config.Config{StructuredOutput: config.StructuredOutputConfig{MaxRetries: 1}}
+	req := &openai.ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "weather?"}},
+		Tools: []openai.Tool{
+			{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "get_weather"}},
+			{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "get_time"}},
+		},
+		ToolChoice: map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}},
+	}
+	if !validated(req) {
+		t.Fatal("指定函数的请求应先收集回复再校验")
+	}
+
+	var requests [][]openai.ChatCompletionMessage
+	replies := []string{
+		"<tool_call>\n{\"name\": \"get_time\", \"arguments\": {}}\n</tool_call>",
+		"<tool_call>\n{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}\n</tool_call>",
+	}
+	completion, err := collectStructured(context.Background(), cfg, req, replyOpener(replies, &requests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completion.ToolCalls) != 1 || completion.ToolCalls[0].Function.Name != "get_weather" {
+		t.Errorf("工具调用错误: %+v", completion.ToolCalls)
+	}
+	if len(requests) != 2 || len(requests[1]) != 3 || !strings.Contains(requests[1][1].Content, "get_time") {
+		t.Errorf("重新请求时应回放上一次的工具调用并附上纠正提示: %+v", requests)
+	}
+
+	requests = nil
+	_, err = collectStructured(context.Background(), cfg, req, replyOpener([]string{"sunny", "still sunny"}, &requests))
+	var appErr *apperrors.AppError
+	if !stderrors.As(err, &appErr) || appErr.Code != apperrors.ErrToolChoice || len(requests) != 2 {
+		t.Errorf("超过重试次数后应返回 tool_choice 错误: %v, 请求 %d 次", err, len(requests))
+	}
+}
+
+// multiContentRequest 构造消息为多段纯文本内容的请求，部分 SDK 总是以数组发送 content
+func multiContentRequest() openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model: "gpt-4o",
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, MultiContent: []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeText, Text: "weather in Paris?"},
+		}}},
+	}
+}
+
+// lastItemContents 转换请求并返回最后一个条目的内容，同时检查普通对话与 Custom Bot 两种请求
+func lastItemContents(t *testing.T, req openai.ChatCompletionRequest) []string {
+	t.Helper()
+	cfg := &config.Config{}
+	mReq, err := types.ChatGPTToMonica(context.Background(), cfg, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	botReq, err := types.ChatGPTToCustomBot(context.Background(), cfg, req, "bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []string{
+		mReq.Data.Items[len(mReq.Data.Items)-1].Data.Content,
+		botReq.Data.Items[len(botReq.Data.Items)-1].Data.Content,
+	}
+}
+
+// TestToolPromptMultiContent 测试工具说明注入多段内容的消息后随消息发送
+func TestToolPromptMultiContent(t *testing.T) {
+	req := multiContentRequest()
+	req.Tools = []openai.Tool{{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "get_weather"}}}
+	for _, content := range lastItemContents(t, req) {
+		if !strings.Contains(content, "get_weather") || !strings.Contains(content, "weather in Paris?") {
+			t.Errorf("工具说明或提问未发送: %q", content)
+		}
+	}
+}
+
+// TestFormatPromptMultiContent 测试 response_format 的格式约束注入多段内容的消息后随消息发送
+func TestFormatPromptMultiContent(t *testing.T) {
+	req := multiContentRequest()
+	req.ResponseFormat = &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	for _, content := range lastItemContents(t, req) {
+		if !strings.Contains(content, "JSON object") || !strings.Contains(content, "weather in Paris?") {
+			t.Errorf("格式约束或提问未发送: %q", content)
+		}
+	}
+}
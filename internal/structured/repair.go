@@ -0,0 +1,107 @@
+package structured
+
+import (
+	"strings"
+)
+
+// Repair 尝试修复模型输出中常见的 JSON 格式问题
+//   - 去掉 markdown 代码块与前后的说明文字
+//   - 删除对象与数组末尾多余的逗号
+func Repair(text string) string {
+	text = strings.TrimSpace(text)
+	text = stripCodeFence(text)
+	text = extractJSON(text)
+	return removeTrailingCommas(text)
+}
+
+// stripCodeFence 提取 ``` 代码块中的内容
+func stripCodeFence(text string) string {
+	start := strings.Index(text, "```")
+	if start < 0 {
+		return text
+	}
+	body := text[start+3:]
+	// 跳过语言标记，如 ```json
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 && !strings.ContainsAny(body[:nl], "{[") {
+		body = body[nl+1:]
+	}
+	if end := strings.LastIndex(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body)
+}
+
+// extractJSON 截取第一个 { 或 [ 到与之匹配的括号，去掉前后的说明文字
+func extractJSON(text string) string {
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return text[start : i+1]
+			}
+		}
+	}
+	// 括号不匹配时保留原文，交给解析器报错
+	return text[start:]
+}
+
+// removeTrailingCommas 删除字符串外紧跟在 } 或 ] 前的逗号
+func removeTrailingCommas(text string) string {
+	var sb strings.Builder
+	sb.Grow(len(text))
+
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			sb.WriteByte(c)
+			continue
+		}
+		if c == '"' {
+			inString = true
+		}
+		if c == ',' {
+			j := i + 1
+			for j < len(text) && strings.IndexByte(" \t\r\n", text[j]) >= 0 {
+				j++
+			}
+			if j < len(text) && (text[j] == '}' || text[j] == ']') {
+				continue
+			}
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
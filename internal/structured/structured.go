@@ -0,0 +1,123 @@
+// Package structured 实现 response_format 的 json_object / json_schema 模式
+// 约束通过提示词注入上游，收集到的回复经过修复与 schema 校验后才返回给客户端
+package structured
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Format 解析后的 response_format
+type Format struct {
+	Type        openai.ChatCompletionResponseFormatType
+	Name        string
+	Description string
+	Schema      any // 解码后的 JSON Schema，json_object 模式为 nil
+	Strict      bool
+}
+
+// ParseFormat 解析请求的 response_format，非 JSON 模式返回 nil
+func ParseFormat(req *openai.ChatCompletionRequest) (*Format, error) {
+	rf := req.ResponseFormat
+	if rf == nil {
+		return nil, nil
+	}
+	switch rf.Type {
+	case openai.ChatCompletionResponseFormatTypeJSONObject:
+		return &Format{Type: rf.Type}, nil
+	case openai.ChatCompletionResponseFormatTypeJSONSchema:
+		if rf.JSONSchema == nil || rf.JSONSchema.Schema == nil {
+			return nil, fmt.Errorf("response_format.json_schema.schema is required")
+		}
+		raw, err := json.Marshal(rf.JSONSchema.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json_schema: %w", err)
+		}
+		var schema any
+		if err := json.Unmarshal(raw, &schema); err != nil {
+			return nil, fmt.Errorf("invalid json_schema: %w", err)
+		}
+		if schema == nil {
+			return nil, fmt.Errorf("response_format.json_schema.schema is required")
+		}
+		return &Format{
+			Type:        rf.Type,
+			Name:        rf.JSONSchema.Name,
+			Description: rf.JSONSchema.Description,
+			Schema:      schema,
+			Strict:      rf.JSONSchema.Strict,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Enabled 当前请求是否需要结构化输出
+func Enabled(req *openai.ChatCompletionRequest) bool {
+	rf := req.ResponseFormat
+	return rf != nil && (rf.Type == openai.ChatCompletionResponseFormatTypeJSONObject ||
+		rf.Type == openai.ChatCompletionResponseFormatTypeJSONSchema)
+}
+
+// Prompt 渲染注入上游的格式约束，非 JSON 模式返回空字符串
+func Prompt(req *openai.ChatCompletionRequest) string {
+	format, err := ParseFormat(req)
+	if err != nil || format == nil {
+		return ""
+	}
+	return format.Prompt()
+}
+
+// Prompt 渲染格式约束提示词
+func (f *Format) Prompt() string {
+	var sb strings.Builder
+	sb.WriteString("# Response format\n\n")
+	sb.WriteString("Reply with a single valid JSON value and nothing else: no prose, no explanations, no markdown code fences.\n")
+	if f.Schema == nil {
+		sb.WriteString("The value must be a JSON object.")
+		return sb.String()
+	}
+
+	sb.WriteString("The value must conform to the following JSON Schema")
+	if f.Name != "" {
+		sb.WriteString(fmt.Sprintf(" (%q)", f.Name))
+	}
+	sb.WriteString(":\n")
+	if f.Description != "" {
+		sb.WriteString(f.Description + "\n")
+	}
+	schema, _ := json.Marshal(f.Schema)
+	sb.Write(schema)
+	if f.Strict {
+		sb.WriteString("\nInclude every required property and do not add properties that are not defined in the schema.")
+	}
+	return sb.String()
+}
+
+// Check 修复并校验模型输出，返回规范化后的 JSON 文本
+func (f *Format) Check(text string) (string, error) {
+	repaired := Repair(text)
+
+	var value any
+	if err := json.Unmarshal([]byte(repaired), &value); err != nil {
+		return "", fmt.Errorf("output is not valid JSON: %w", err)
+	}
+	if f.Schema == nil {
+		if _, ok := value.(map[string]any); !ok {
+			return "", fmt.Errorf("output must be a JSON object")
+		}
+		return repaired, nil
+	}
+	if err := Validate(f.Schema, value); err != nil {
+		return "", err
+	}
+	return repaired, nil
+}
+
+// CorrectionPrompt 校验失败后要求模型重新作答的提示词
+func CorrectionPrompt(err error) string {
+	return fmt.Sprintf("Your previous reply was rejected: %v.\nReply again with only the corrected JSON value.", err)
+}
@@ -0,0 +1,96 @@
+package structured
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestRepair 测试常见格式问题的修复
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"纯JSON", `{"a": 1}`, `{"a": 1}`},
+		{"代码块", "```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"前后说明文字", "结果如下：{\"a\": [1, 2]} 希望有帮助", `{"a": [1, 2]}`},
+		{"末尾逗号", "{\"a\": [1, 2,], \"b\": {\"c\": 1,},}", `{"a": [1, 2], "b": {"c": 1}}`},
+		{"字符串中的逗号与括号", `{"a": "x,}", "b": "]"}`, `{"a": "x,}", "b": "]"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Repair(tt.input); got != tt.want {
+				t.Errorf("Repair(%q) = %q; 期望 %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidate 测试 JSON Schema 校验
+func TestValidate(t *testing.T) {
+	schema := decode(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0},
+			"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}},
+			"role": {"enum": ["admin", "user"]},
+			"note": {"type": ["string", "null"]}
+		},
+		"required": ["name", "age"],
+		"additionalProperties": false,
+		"$defs": {"tag": {"type": "string", "pattern": "^[a-z]+$"}}
+	}`)
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{"合法", `{"name": "a", "age": 3, "tags": ["x"], "role": "user", "note": null}`, ""},
+		{"缺少必填字段", `{"name": "a"}`, `missing required property "age"`},
+		{"类型错误", `{"name": "a", "age": 1.5}`, "$.age: expected integer"},
+		{"多余字段", `{"name": "a", "age": 1, "x": 1}`, `unexpected property "x"`},
+		{"数组元素引用", `{"name": "a", "age": 1, "tags": ["X"]}`, "$.tags[0]: string does not match pattern"},
+		{"枚举", `{"name": "a", "age": 1, "role": "root"}`, "$.role: value must be one of"},
+		{"数值范围", `{"name": "a", "age": -1}`, "$.age: value must be >= 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(schema, decode(t, tt.value))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("期望校验通过，实际错误: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("期望错误包含 %q，实际: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestCheckJSONObject 测试 json_object 模式只接受对象
+func TestCheckJSONObject(t *testing.T) {
+	format := &Format{Type: "json_object"}
+	if _, err := format.Check("[1, 2]"); err == nil {
+		t.Error("json_object 模式不应接受数组")
+	}
+	if got, err := format.Check("```\n{\"a\": 1,}\n```"); err != nil || got != `{"a": 1}` {
+		t.Errorf("Check 结果错误: %q, %v", got, err)
+	}
+}
+
+func decode(t *testing.T, text string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		t.Fatalf("解析 JSON 失败: %v", err)
+	}
+	return v
+}
@@ -0,0 +1,340 @@
+package structured
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validate 按 JSON Schema 校验已解码的值
+// 支持结构化输出常用的关键字子集：type、enum、const、properties、required、additionalProperties、
+// items、长度/数量/数值范围、pattern、anyOf/oneOf/allOf 以及指向 $defs/definitions 的 $ref
+func Validate(schema any, value any) error {
+	v := &validator{root: schema}
+	return v.validate(schema, value, "$", 0)
+}
+
+// maxRefDepth $ref 最大展开深度，防止自引用死循环
+const maxRefDepth = 64
+
+type validator struct {
+	root any
+}
+
+func (v *validator) validate(schema any, value any, path string, depth int) error {
+	if depth > maxRefDepth {
+		return fmt.Errorf("%s: schema nesting too deep", path)
+	}
+
+	s, ok := schema.(map[string]any)
+	if !ok {
+		// true / 空 schema 接受任意值，false 拒绝任意值
+		if b, isBool := schema.(bool); isBool && !b {
+			return fmt.Errorf("%s: no value is allowed here", path)
+		}
+		return nil
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return v.validate(target, value, path, depth+1)
+	}
+
+	if value == nil && s["nullable"] == true {
+		return nil
+	}
+	if err := checkType(s["type"], value, path); err != nil {
+		return err
+	}
+	if enum, ok := s["enum"].([]any); ok && !containsValue(enum, value) {
+		return fmt.Errorf("%s: value must be one of %s", path, formatValues(enum))
+	}
+	if constant, ok := s["const"]; ok && !equalValue(constant, value) {
+		return fmt.Errorf("%s: value must be %v", path, constant)
+	}
+
+	for _, sub := range schemaList(s["allOf"]) {
+		if err := v.validate(sub, value, path, depth+1); err != nil {
+			return err
+		}
+	}
+	if subs := schemaList(s["anyOf"]); len(subs) > 0 {
+		var firstErr error
+		matched := false
+		for _, sub := range subs {
+			err := v.validate(sub, value, path, depth+1)
+			if err == nil {
+				matched = true
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value does not match any allowed schema (%v)", path, firstErr)
+		}
+	}
+	if subs := schemaList(s["oneOf"]); len(subs) > 0 {
+		matches := 0
+		for _, sub := range subs {
+			if v.validate(sub, value, path, depth+1) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: value must match exactly one schema, matched %d", path, matches)
+		}
+	}
+
+	switch val := value.(type) {
+	case map[string]any:
+		return v.validateObject(s, val, path, depth)
+	case []any:
+		return v.validateArray(s, val, path, depth)
+	case string:
+		return validateString(s, val, path)
+	case float64:
+		return validateNumber(s, val, path)
+	}
+	return nil
+}
+
+func (v *validator) validateObject(s map[string]any, obj map[string]any, path string, depth int) error {
+	properties, _ := s["properties"].(map[string]any)
+
+	for _, name := range stringList(s["required"]) {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", path, name)
+		}
+	}
+
+	for name, value := range obj {
+		childPath := path + "." + name
+		if sub, ok := properties[name]; ok {
+			if err := v.validate(sub, value, childPath, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unexpected property %q", path, name)
+			}
+		case map[string]any:
+			if err := v.validate(additional, value, childPath, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if n, ok := number(s["minProperties"]); ok && float64(len(obj)) < n {
+		return fmt.Errorf("%s: expected at least %v properties", path, n)
+	}
+	if n, ok := number(s["maxProperties"]); ok && float64(len(obj)) > n {
+		return fmt.Errorf("%s: expected at most %v properties", path, n)
+	}
+	return nil
+}
+
+func (v *validator) validateArray(s map[string]any, arr []any, path string, depth int) error {
+	if n, ok := number(s["minItems"]); ok && float64(len(arr)) < n {
+		return fmt.Errorf("%s: expected at least %v items", path, n)
+	}
+	if n, ok := number(s["maxItems"]); ok && float64(len(arr)) > n {
+		return fmt.Errorf("%s: expected at most %v items", path, n)
+	}
+	if s["uniqueItems"] == true {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if equalValue(arr[i], arr[j]) {
+					return fmt.Errorf("%s: items must be unique", path)
+				}
+			}
+		}
+	}
+
+	items, ok := s["items"]
+	if !ok {
+		return nil
+	}
+	for i, item := range arr {
+		if err := v.validate(items, item, fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateString(s map[string]any, str string, path string) error {
+	length := float64(utf8.RuneCountInString(str))
+	if n, ok := number(s["minLength"]); ok && length < n {
+		return fmt.Errorf("%s: string shorter than %v characters", path, n)
+	}
+	if n, ok := number(s["maxLength"]); ok && length > n {
+		return fmt.Errorf("%s: string longer than %v characters", path, n)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err == nil && !re.MatchString(str) {
+			return fmt.Errorf("%s: string does not match pattern %q", path, pattern)
+		}
+	}
+	return nil
+}
+
+func validateNumber(s map[string]any, n float64, path string) error {
+	if bound, ok := number(s["minimum"]); ok && n < bound {
+		return fmt.Errorf("%s: value must be >= %v", path, bound)
+	}
+	if bound, ok := number(s["maximum"]); ok && n > bound {
+		return fmt.Errorf("%s: value must be <= %v", path, bound)
+	}
+	if bound, ok := number(s["exclusiveMinimum"]); ok && n <= bound {
+		return fmt.Errorf("%s: value must be > %v", path, bound)
+	}
+	if bound, ok := number(s["exclusiveMaximum"]); ok && n >= bound {
+		return fmt.Errorf("%s: value must be < %v", path, bound)
+	}
+	if step, ok := number(s["multipleOf"]); ok && step > 0 {
+		if q := n / step; math.Abs(q-math.Round(q)) > 1e-9 {
+			return fmt.Errorf("%s: value must be a multiple of %v", path, step)
+		}
+	}
+	return nil
+}
+
+// resolve 解析本地 $ref，支持 #、#/$defs/x、#/definitions/x 等 JSON Pointer
+func (v *validator) resolve(ref string) (any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	current := v.root
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return current, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		node, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		if current, ok = node[token]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return current, nil
+}
+
+// checkType 校验 type 关键字，支持字符串与字符串数组
+func checkType(typ any, value any, path string) error {
+	var types []string
+	switch t := typ.(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		types = stringList(t)
+	default:
+		return nil
+	}
+	for _, t := range types {
+		if matchesType(t, value) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), typeName(value))
+}
+
+func matchesType(typ string, value any) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func schemaList(v any) []any {
+	list, _ := v.([]any)
+	return list
+}
+
+func stringList(v any) []string {
+	list, _ := v.([]any)
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func number(v any) (float64, bool) {
+	n, ok := v.(float64)
+	return n, ok
+}
+
+func containsValue(list []any, value any) bool {
+	for _, item := range list {
+		if equalValue(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalValue(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func formatValues(values []any) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, fmt.Sprintf("%v", v))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
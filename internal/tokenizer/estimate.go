@@ -0,0 +1,109 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// estimate 无法加载 BPE 编码时的近似计数
+func estimate(text string) int {
+	n := 0
+	forEachPiece(text, func(piece string, tokens int) bool {
+		n += tokens
+		return true
+	})
+	return n
+}
+
+// estimateTruncate 按近似计数截取前缀
+func estimateTruncate(text string, maxTokens int) (string, int) {
+	end, n := 0, 0
+	forEachPiece(text, func(piece string, tokens int) bool {
+		if n+tokens > maxTokens {
+			return false
+		}
+		end += len(piece)
+		n += tokens
+		return true
+	})
+	return text[:end], n
+}
+
+// forEachPiece 按近似 GPT 预分词规则切分文本，回调每个片段及其 token 数，回调返回 false 时停止
+//   - 英文单词（含前导空格）约 4 个字符一个 token
+//   - 数字约 3 位一个 token
+//   - 中日韩字符每个字一个 token
+//   - 标点与其他符号每个一个 token，连续空白合并为一个 token
+func forEachPiece(text string, fn func(piece string, tokens int) bool) {
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		start := i
+
+		// 单个前导空格并入后面的单词
+		if r == ' ' && i+size < len(text) {
+			next, _ := utf8.DecodeRuneInString(text[i+size:])
+			if isWordRune(next) || unicode.IsDigit(next) {
+				i += size
+				r, size = next, utf8.RuneLen(next)
+			}
+		}
+
+		var tokens int
+		switch {
+		case isCJK(r):
+			i += size
+			tokens = 1
+		case isWordRune(r):
+			runes := 0
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !isWordRune(r) {
+					break
+				}
+				i += size
+				runes++
+			}
+			tokens = (runes + 3) / 4
+		case unicode.IsDigit(r):
+			digits := 0
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+				digits++
+			}
+			tokens = (digits + 2) / 3
+		case unicode.IsSpace(r):
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				i += size
+			}
+			tokens = 1
+		default:
+			i += size
+			tokens = 1
+		}
+
+		if !fn(text[start:i], tokens) {
+			return
+		}
+	}
+}
+
+// isWordRune 非中日韩的字母
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) && !isCJK(r)
+}
+
+// isCJK 中日韩表意文字、假名与韩文
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
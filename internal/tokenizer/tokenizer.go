@@ -0,0 +1,104 @@
+// Package tokenizer 本地 token 计数
+// Monica 不返回 token 用量，max_tokens 截断与用量统计都依赖这里的本地计数
+// OpenAI 模型使用对应的 BPE 编码；其他厂商的模型没有公开的本地分词器，统一按 cl100k_base 近似
+package tokenizer
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"monica-proxy/internal/logger"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+	"go.uber.org/zap"
+)
+
+const (
+	EncodingO200K  = "o200k_base"
+	EncodingCL100K = "cl100k_base"
+
+	// 每条消息的格式开销与回复引导开销，与 OpenAI 的计数方式一致
+	TokensPerMessage = 3
+	TokensPerReply   = 3
+)
+
+// o200kPrefixes 使用 o200k_base 的模型前缀
+var o200kPrefixes = []string{"gpt-4o", "gpt-4.1", "gpt-4-5", "gpt-4.5", "gpt-5", "o1", "o3", "o4", "chatgpt-4o"}
+
+var (
+	loaderOnce sync.Once
+	encodings  sync.Map // encoding name -> *encodingEntry
+)
+
+// encodingEntry 延迟加载的编码，加载失败时 codec 为空并回退到近似计数
+type encodingEntry struct {
+	once  sync.Once
+	codec *tiktoken.Tiktoken
+}
+
+// EncodingForModel 返回模型所属系列使用的 BPE 编码名
+func EncodingForModel(model string) string {
+	model = strings.ToLower(model)
+	for _, prefix := range o200kPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return EncodingO200K
+		}
+	}
+	return EncodingCL100K
+}
+
+// codecForModel 获取模型对应的编码器，BPE 数据内置在二进制中，不需要联网下载
+func codecForModel(model string) *tiktoken.Tiktoken {
+	loaderOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+	})
+
+	name := EncodingForModel(model)
+	value, _ := encodings.LoadOrStore(name, &encodingEntry{})
+	entry := value.(*encodingEntry)
+	entry.once.Do(func() {
+		codec, err := tiktoken.GetEncoding(name)
+		if err != nil {
+			logger.Warn("加载BPE编码失败，使用近似计数", zap.String("encoding", name), zap.Error(err))
+			return
+		}
+		entry.codec = codec
+	})
+	return entry.codec
+}
+
+// Count 计算文本的 token 数
+func Count(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	codec := codecForModel(model)
+	if codec == nil {
+		return estimate(text)
+	}
+	return len(codec.EncodeOrdinary(text))
+}
+
+// Truncate 截取不超过 maxTokens 个 token 的文本前缀，返回截取结果与其 token 数
+func Truncate(model, text string, maxTokens int) (string, int) {
+	if maxTokens <= 0 {
+		return "", 0
+	}
+	codec := codecForModel(model)
+	if codec == nil {
+		return estimateTruncate(text, maxTokens)
+	}
+
+	tokens := codec.EncodeOrdinary(text)
+	if len(tokens) <= maxTokens {
+		return text, len(tokens)
+	}
+	prefix := codec.Decode(tokens[:maxTokens])
+	// token 边界可能落在多字节字符中间，去掉不完整的尾部字节
+	for len(prefix) > 0 && !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix, maxTokens
+}
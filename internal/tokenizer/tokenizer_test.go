@@ -0,0 +1,52 @@
+package tokenizer
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+// TestEncodingForModel 测试模型系列到 BPE 编码的映射
+func TestEncodingForModel(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o":            EncodingO200K,
+		"gpt-4.1-mini":      EncodingO200K,
+		"o3-mini":           EncodingO200K,
+		"gpt-5":             EncodingO200K,
+		"gpt-4":             EncodingCL100K,
+		"claude-sonnet-4":   EncodingCL100K,
+		"gemini-2.5-pro":    EncodingCL100K,
+		"deepseek-reasoner": EncodingCL100K,
+	}
+	for model, want := range tests {
+		if got := EncodingForModel(model); got != want {
+			t.Errorf("EncodingForModel(%s) = %s; 期望 %s", model, got, want)
+		}
+	}
+}
+
+// TestCount 测试 BPE 计数
+func TestCount(t *testing.T) {
+	if n := Count("gpt-4o", "hello world"); n != 2 {
+		t.Errorf("Count(hello world) = %d; 期望 2", n)
+	}
+	if n := Count("gpt-4o", ""); n != 0 {
+		t.Errorf("空文本应为 0 个 token，实际 %d", n)
+	}
+}
+
+// TestTruncate 测试按 token 截断时不产生不完整的 UTF-8 字符
+func TestTruncate(t *testing.T) {
+	text := "こんにちは世界、今日はいい天気ですね"
+	for max := 1; max < Count("gpt-4", text); max++ {
+		prefix, n := Truncate("gpt-4", text, max)
+		if !utf8.ValidString(prefix) {
+			t.Fatalf("max=%d 截断结果不是合法 UTF-8: %q", max, prefix)
+		}
+		if n > max || Count("gpt-4", prefix) > max {
+			t.Fatalf("max=%d 截断结果超出限制: %q (%d)", max, prefix, n)
+		}
+	}
+	if prefix, _ := Truncate("gpt-4o", "short", 100); prefix != "short" {
+		t.Errorf("未超限时应返回原文，实际 %q", prefix)
+	}
+}
@@ -0,0 +1,145 @@
+package toolcall
+
+import (
+	"encoding/json"
+	"strings"
+
+	"monica-proxy/internal/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// rawCall 模型输出的工具调用 JSON
+type rawCall struct {
+	Name       string          `json:"name"`
+	Arguments  json.RawMessage `json:"arguments"`
+	Parameters json.RawMessage `json:"parameters"` // 部分模型会误用 parameters
+}
+
+// NewCallID 生成工具调用ID
+func NewCallID() string {
+	return "call_" + utils.RandStringUsingMathRand(24)
+}
+
+// Parse 从模型输出中提取 <tool_call> 块
+// 返回块外的正文与解析出的工具调用；缺少结束标签的最后一个块也会尝试解析
+func Parse(text string) (string, []openai.ToolCall) {
+	var content strings.Builder
+	var calls []openai.ToolCall
+
+	rest := text
+	for {
+		start := strings.Index(rest, openTag)
+		if start < 0 {
+			content.WriteString(rest)
+			break
+		}
+		content.WriteString(rest[:start])
+		rest = rest[start+len(openTag):]
+
+		body := rest
+		end := strings.Index(rest, closeTag)
+		if end >= 0 {
+			body = rest[:end]
+			rest = rest[end+len(closeTag):]
+		} else {
+			rest = ""
+		}
+
+		call, ok := parseCall(body)
+		if !ok {
+			// 无法解析的块按原样保留为正文
+			content.WriteString(openTag + body)
+			if end >= 0 {
+				content.WriteString(closeTag)
+			}
+			continue
+		}
+		index := len(calls)
+		call.Index = &index
+		calls = append(calls, call)
+	}
+
+	return strings.TrimSpace(content.String()), calls
+}
+
+// parseCall 解析单个 <tool_call> 块内的 JSON
+func parseCall(body string) (openai.ToolCall, bool) {
+	body = strings.TrimSpace(body)
+	body = strings.TrimPrefix(body, "```json")
+	body = strings.TrimPrefix(body, "```")
+	body = strings.TrimSuffix(body, "```")
+	body = strings.TrimSpace(body)
+
+	var raw rawCall
+	if err := json.Unmarshal([]byte(body), &raw); err != nil || raw.Name == "" {
+		return openai.ToolCall{}, false
+	}
+
+	arguments := raw.Arguments
+	if len(arguments) == 0 {
+		arguments = raw.Parameters
+	}
+	return openai.ToolCall{
+		ID:   NewCallID(),
+		Type: openai.ToolTypeFunction,
+		Function: openai.FunctionCall{
+			Name:      raw.Name,
+			Arguments: normalizeArguments(arguments),
+		},
+	}, true
+}
+
+// normalizeArguments 将参数统一为 JSON 对象字符串，兼容模型把参数写成 JSON 字符串的情况
+func normalizeArguments(arguments json.RawMessage) string {
+	if len(arguments) == 0 || string(arguments) == "null" {
+		return "{}"
+	}
+	var encoded string
+	if err := json.Unmarshal(arguments, &encoded); err == nil {
+		if json.Valid([]byte(encoded)) {
+			return encoded
+		}
+		return "{}"
+	}
+	return string(arguments)
+}
+
+// StreamParser 流式解析器，正文实时放行，遇到 <tool_call> 后缓存剩余输出直到结束
+type StreamParser struct {
+	pending string // 可能是标签前缀、暂不放行的正文
+	call    strings.Builder
+	inCall  bool
+}
+
+// Feed 输入增量文本，返回可以立即输出的正文
+func (p *StreamParser) Feed(text string) string {
+	if p.inCall {
+		p.call.WriteString(text)
+		return ""
+	}
+
+	p.pending += text
+	if idx := strings.Index(p.pending, openTag); idx >= 0 {
+		out := p.pending[:idx]
+		p.call.WriteString(p.pending[idx:])
+		p.pending = ""
+		p.inCall = true
+		return out
+	}
+
+	keep := utils.PartialSuffixLen(p.pending, openTag)
+	out := p.pending[:len(p.pending)-keep]
+	p.pending = p.pending[len(p.pending)-keep:]
+	return out
+}
+
+// Finish 结束解析，返回尚未输出的正文与工具调用
+func (p *StreamParser) Finish() (string, []openai.ToolCall) {
+	if !p.inCall {
+		out := p.pending
+		p.pending = ""
+		return out, nil
+	}
+	return Parse(p.call.String())
+}
@@ -0,0 +1,118 @@
+package toolcall
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// TestParse 测试从完整输出中解析工具调用
+func TestParse(t *testing.T) {
+	text := "好的。\n<tool_call>\n{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}\n</tool_call>\n" +
+		"<tool_call>```json\n{\"name\": \"get_time\", \"parameters\": \"{\\\"tz\\\": \\\"UTC\\\"}\"}\n```</tool_call>"
+
+	content, calls := Parse(text)
+	if content != "好的。" {
+		t.Errorf("正文解析错误: %q", content)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("期望解析出 2 个工具调用，实际 %d 个", len(calls))
+	}
+	if calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city": "Paris"}` {
+		t.Errorf("第一个工具调用解析错误: %+v", calls[0].Function)
+	}
+	if calls[1].Function.Name != "get_time" || calls[1].Function.Arguments != `{"tz": "UTC"}` {
+		t.Errorf("第二个工具调用解析错误: %+v", calls[1].Function)
+	}
+	for i, call := range calls {
+		if call.Index == nil || *call.Index != i {
+			t.Errorf("工具调用 %d 的 Index 错误", i)
+		}
+		if !strings.HasPrefix(call.ID, "call_") {
+			t.Errorf("工具调用 %d 的 ID 格式错误: %s", i, call.ID)
+		}
+	}
+}
+
+// TestParseInvalidBlock 测试无法解析的块按原样保留为正文
+func TestParseInvalidBlock(t *testing.T) {
+	text := "<tool_call>not json</tool_call>"
+	content, calls := Parse(text)
+	if len(calls) != 0 {
+		t.Errorf("不应解析出工具调用: %+v", calls)
+	}
+	if content != text {
+		t.Errorf("正文应原样保留，实际 %q", content)
+	}
+}
+
+// TestStreamParser 测试标签跨增量切分时的流式解析
+func TestStreamParser(t *testing.T) {
+	chunks := []string{"让我查一下<", "tool", "_call>{\"name\": \"search\", ", "\"arguments\": {}}</tool_call>"}
+
+	parser := &StreamParser{}
+	var out strings.Builder
+	for _, chunk := range chunks {
+		out.WriteString(parser.Feed(chunk))
+	}
+	rest, calls := parser.Finish()
+	out.WriteString(rest)
+
+	if out.String() != "让我查一下" {
+		t.Errorf("流式正文错误: %q", out.String())
+	}
+	if len(calls) != 1 || calls[0].Function.Name != "search" || calls[0].Function.Arguments != "{}" {
+		t.Errorf("流式工具调用解析错误: %+v", calls)
+	}
+}
+
+// TestStreamParserPlainText 测试没有工具调用时正文完整输出
+func TestStreamParserPlainText(t *testing.T) {
+	parser := &StreamParser{}
+	var out strings.Builder
+	for _, chunk := range []string{"a < b", " 且 c <tool", " 不是标签"} {
+		out.WriteString(parser.Feed(chunk))
+	}
+	rest, calls := parser.Finish()
+	out.WriteString(rest)
+
+	if out.String() != "a < b 且 c <tool 不是标签" {
+		t.Errorf("正文错误: %q", out.String())
+	}
+	if len(calls) != 0 {
+		t.Errorf("不应解析出工具调用: %+v", calls)
+	}
+}
+
+// TestCheckChoice 测试 required 与指定函数的 tool_choice 校验
+func TestCheckChoice(t *testing.T) {
+	tools := []openai.Tool{
+		{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "get_weather"}},
+		{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "get_time"}},
+	}
+	weather := []openai.ToolCall{{Function: openai.FunctionCall{Name: "get_weather"}}}
+	clock := []openai.ToolCall{{Function: openai.FunctionCall{Name: "get_time"}}}
+
+	auto := &openai.ChatCompletionRequest{Tools: tools}
+	if Forced(auto) || CheckChoice(auto, nil) != nil {
+		t.Error("auto 不应要求调用工具")
+	}
+
+	required := &openai.ChatCompletionRequest{Tools: tools, ToolChoice: ChoiceRequired}
+	if !Forced(required) || CheckChoice(required, nil) == nil || CheckChoice(required, clock) != nil {
+		t.Error("required 校验错误")
+	}
+
+	named := &openai.ChatCompletionRequest{Tools: tools, ToolChoice: map[string]any{
+		"type":     "function",
+		"function": map[string]any{"name": "get_weather"},
+	}}
+	if !Forced(named) || CheckChoice(named, nil) == nil || CheckChoice(named, clock) == nil || CheckChoice(named, weather) != nil {
+		t.Error("指定函数校验错误")
+	}
+
+	if Forced(&openai.ChatCompletionRequest{ToolChoice: ChoiceRequired}) {
+		t.Error("没有工具定义时不应要求调用工具")
+	}
+}
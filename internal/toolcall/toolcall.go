@@ -0,0 +1,267 @@
+// Package toolcall 在 Monica 普通对话之上模拟 OpenAI 的函数/工具调用
+// 工具定义渲染进上游提示词，模型按约定格式输出 <tool_call> 块，再解析回结构化的 tool_calls
+package toolcall
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"monica-proxy/internal/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	openTag        = "<tool_call>"
+	closeTag       = "</tool_call>"
+	resultOpenTag  = "<tool_result"
+	resultCloseTag = "</tool_result>"
+)
+
+// 工具选择模式
+const (
+	ChoiceAuto     = "auto"
+	ChoiceNone     = "none"
+	ChoiceRequired = "required"
+	ChoiceFunction = "function" // 指定函数
+)
+
+// Choice 解析后的 tool_choice
+type Choice struct {
+	Mode string
+	Name string // Mode 为 function 时的函数名
+}
+
+// ParseChoice 解析 tool_choice，兼容字符串与 {"type":"function","function":{"name":...}} 对象
+func ParseChoice(toolChoice any) Choice {
+	switch v := toolChoice.(type) {
+	case string:
+		switch v {
+		case ChoiceNone, ChoiceRequired:
+			return Choice{Mode: v}
+		}
+	case map[string]any:
+		if fn, ok := v["function"].(map[string]any); ok {
+			if name, ok := fn["name"].(string); ok && name != "" {
+				return Choice{Mode: ChoiceFunction, Name: name}
+			}
+		}
+		if name, ok := v["name"].(string); ok && name != "" {
+			return Choice{Mode: ChoiceFunction, Name: name}
+		}
+	case openai.ToolChoice:
+		return Choice{Mode: ChoiceFunction, Name: v.Function.Name}
+	case *openai.ToolChoice:
+		if v != nil {
+			return Choice{Mode: ChoiceFunction, Name: v.Function.Name}
+		}
+	}
+	return Choice{Mode: ChoiceAuto}
+}
+
+// requestTools 合并 tools 与已废弃的 functions 字段
+func requestTools(req *openai.ChatCompletionRequest) []openai.FunctionDefinition {
+	defs := make([]openai.FunctionDefinition, 0, len(req.Tools)+len(req.Functions))
+	for _, tool := range req.Tools {
+		if tool.Type == openai.ToolTypeFunction && tool.Function != nil {
+			defs = append(defs, *tool.Function)
+		}
+	}
+	return append(defs, req.Functions...)
+}
+
+// requestChoice 读取 tool_choice，未设置时回退到已废弃的 function_call 字段
+func requestChoice(req *openai.ChatCompletionRequest) Choice {
+	if req.ToolChoice != nil {
+		return ParseChoice(req.ToolChoice)
+	}
+	return ParseChoice(req.FunctionCall)
+}
+
+// Enabled 当前请求是否需要解析模型输出中的工具调用
+func Enabled(req *openai.ChatCompletionRequest) bool {
+	return len(requestTools(req)) > 0 && requestChoice(req).Mode != ChoiceNone
+}
+
+// Forced tool_choice 是否要求模型必须调用工具（required 或指定函数）
+func Forced(req *openai.ChatCompletionRequest) bool {
+	if !Enabled(req) {
+		return false
+	}
+	mode := requestChoice(req).Mode
+	return mode == ChoiceRequired || mode == ChoiceFunction
+}
+
+// CheckChoice 校验模型的工具调用是否满足 tool_choice：required 时至少调用一个工具，指定函数时只能调用该函数
+func CheckChoice(req *openai.ChatCompletionRequest, calls []openai.ToolCall) error {
+	choice := requestChoice(req)
+	switch choice.Mode {
+	case ChoiceRequired:
+		if len(calls) == 0 {
+			return fmt.Errorf("tool_choice is required but no tool was called")
+		}
+	case ChoiceFunction:
+		if len(calls) == 0 {
+			return fmt.Errorf("tool %q must be called but no tool was called", choice.Name)
+		}
+		for _, call := range calls {
+			if call.Function.Name != choice.Name {
+				return fmt.Errorf("tool %q must be called but %q was called", choice.Name, call.Function.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// CorrectionPrompt 渲染工具调用不满足 tool_choice 时反馈给模型的提示
+func CorrectionPrompt(err error) string {
+	return fmt.Sprintf("Your previous reply was rejected: %v.\nReply again with only the required %s block.", err, openTag)
+}
+
+// ParallelAllowed 是否允许一次返回多个工具调用，parallel_tool_calls 未显式设为 false 时允许
+func ParallelAllowed(req *openai.ChatCompletionRequest) bool {
+	if allowed, ok := req.ParallelToolCalls.(bool); ok {
+		return allowed
+	}
+	return true
+}
+
+// PrepareMessages 将工具相关的消息改写为 Monica 能理解的纯文本消息
+//   - assistant 的 tool_calls 渲染为 <tool_call> 块
+//   - 连续的 role=tool/function 结果合并为一条 user 消息
+//   - 启用工具时，把工具说明注入最后一条 user 消息
+func PrepareMessages(req *openai.ChatCompletionRequest) []openai.ChatCompletionMessage {
+	messages := make([]openai.ChatCompletionMessage, 0, len(req.Messages))
+	callNames := make(map[string]string)
+	var results []string
+
+	flushResults := func() {
+		if len(results) == 0 {
+			return
+		}
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: strings.Join(results, "\n"),
+		})
+		results = nil
+	}
+
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case openai.ChatMessageRoleTool, openai.ChatMessageRoleFunction:
+			name := msg.Name
+			if name == "" {
+				name = callNames[msg.ToolCallID]
+			}
+			results = append(results, FormatResult(msg.ToolCallID, name, utils.MessageText(msg)))
+			continue
+		}
+		flushResults()
+
+		if msg.Role == openai.ChatMessageRoleAssistant && (len(msg.ToolCalls) > 0 || msg.FunctionCall != nil) {
+			calls := msg.ToolCalls
+			if msg.FunctionCall != nil {
+				calls = append(calls, openai.ToolCall{Type: openai.ToolTypeFunction, Function: *msg.FunctionCall})
+			}
+			for _, call := range calls {
+				if call.ID != "" {
+					callNames[call.ID] = call.Function.Name
+				}
+			}
+
+			text := utils.MessageText(msg)
+			if text != "" {
+				text += "\n\n"
+			}
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: text + FormatCalls(calls),
+			})
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	flushResults()
+
+	if Enabled(req) {
+		utils.InjectPrompt(messages, RenderPrompt(requestTools(req), requestChoice(req), ParallelAllowed(req)))
+	}
+	return messages
+}
+
+// toolSpec 渲染进提示词的工具描述
+type toolSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+// RenderPrompt 渲染工具说明提示词
+func RenderPrompt(tools []openai.FunctionDefinition, choice Choice, parallel bool) string {
+	var sb strings.Builder
+	sb.WriteString("# Tools\n\n")
+	sb.WriteString("You can call the tools listed below. To call a tool, reply with a block in exactly this format:\n\n")
+	sb.WriteString(openTag + "\n{\"name\": \"<tool name>\", \"arguments\": {<arguments as a JSON object>}}\n" + closeTag + "\n\n")
+	sb.WriteString("Rules:\n")
+	sb.WriteString("- The arguments must be valid JSON that matches the tool's parameters schema.\n")
+	if parallel {
+		sb.WriteString("- To call several tools at once, output several " + openTag + " blocks one after another.\n")
+	} else {
+		sb.WriteString("- Call at most one tool per reply.\n")
+	}
+	sb.WriteString("- Do not write anything after the last " + closeTag + " block. Wait for the results.\n")
+	sb.WriteString("- Tool results are given back to you inside " + resultOpenTag + "> blocks.\n")
+	switch choice.Mode {
+	case ChoiceRequired:
+		sb.WriteString("- You MUST call at least one tool in this reply.\n")
+	case ChoiceFunction:
+		sb.WriteString(fmt.Sprintf("- You MUST call the tool %q in this reply.\n", choice.Name))
+	default:
+		sb.WriteString("- If no tool is needed, answer normally without any " + openTag + " block.\n")
+	}
+
+	sb.WriteString("\nAvailable tools:\n")
+	for _, tool := range tools {
+		if choice.Mode == ChoiceFunction && tool.Name != choice.Name {
+			continue
+		}
+		spec, _ := json.Marshal(toolSpec{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		})
+		sb.Write(spec)
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// FormatCalls 将工具调用渲染为 <tool_call> 块，用于回放对话历史
+func FormatCalls(calls []openai.ToolCall) string {
+	blocks := make([]string, 0, len(calls))
+	for _, call := range calls {
+		arguments := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(arguments) {
+			arguments, _ = json.Marshal(call.Function.Arguments)
+		}
+		payload, _ := json.Marshal(struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}{call.Function.Name, arguments})
+		blocks = append(blocks, openTag+"\n"+string(payload)+"\n"+closeTag)
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// FormatResult 将工具执行结果渲染为 <tool_result> 块
+func FormatResult(callID, name, content string) string {
+	var attrs string
+	if callID != "" {
+		attrs += fmt.Sprintf(" id=%q", callID)
+	}
+	if name != "" {
+		attrs += fmt.Sprintf(" name=%q", name)
+	}
+	return resultOpenTag + attrs + ">\n" + content + "\n" + resultCloseTag
+}
@@ -0,0 +1,350 @@
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// AnthropicMessagesRequest Anthropic Messages API 请求
+type AnthropicMessagesRequest struct {
+	Model         string               `json:"model"`
+	System        AnthropicContent     `json:"system,omitempty"` // 字符串或 text 块数组
+	Messages      []AnthropicMessage   `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	Temperature   *float32             `json:"temperature,omitempty"`
+	TopP          *float32             `json:"top_p,omitempty"`
+	TopK          *int                 `json:"top_k,omitempty"`
+	Metadata      *AnthropicMetadata   `json:"metadata,omitempty"`
+	Thinking      *AnthropicThinking   `json:"thinking,omitempty"`
+	Tools         []AnthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *AnthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// AnthropicTool 工具定义
+type AnthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+}
+
+// AnthropicToolChoice 工具选择
+type AnthropicToolChoice struct {
+	Type                   string `json:"type"` // auto, any, tool, none
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+// AnthropicMetadata 请求元数据
+type AnthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+// AnthropicThinking 扩展思考配置
+type AnthropicThinking struct {
+	Type         string `json:"type"` // enabled, disabled
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
+}
+
+// Enabled 请求是否开启了扩展思考，未开启时不返回思考过程
+func (t *AnthropicThinking) Enabled() bool {
+	return t != nil && t.Type == "enabled"
+}
+
+// AnthropicMessage 单条对话消息
+type AnthropicMessage struct {
+	Role    string           `json:"role"` // user, assistant
+	Content AnthropicContent `json:"content"`
+}
+
+// AnthropicContent 内容块列表，反序列化时兼容纯字符串写法
+type AnthropicContent []AnthropicContentBlock
+
+// UnmarshalJSON 支持 "content": "text" 与 "content": [{...}] 两种格式
+func (c *AnthropicContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = AnthropicContent{{Type: "text", Text: text}}
+		return nil
+	}
+
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return fmt.Errorf("invalid content: %w", err)
+	}
+	*c = blocks
+	return nil
+}
+
+// Text 拼接所有 text 块的内容
+func (c AnthropicContent) Text() string {
+	var parts []string
+	for _, block := range c {
+		if block.Type == "text" && block.Text != "" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// AnthropicContentBlock 内容块，按 Type 区分 text/image/document/thinking/tool_use/tool_result
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+
+	// image, document
+	Source *AnthropicImageSource `json:"source,omitempty"`
+
+	// document
+	Title string `json:"title,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   AnthropicContent `json:"content,omitempty"`
+	IsError   bool             `json:"is_error,omitempty"`
+}
+
+// AnthropicImageSource 图片或文档来源
+type AnthropicImageSource struct {
+	Type      string `json:"type"` // base64, url, text、file（仅文档）
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+	FileID    string `json:"file_id,omitempty"`
+}
+
+// AnthropicMessagesResponse Anthropic Messages API 非流式响应
+type AnthropicMessagesResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"` // 固定为 message
+	Role         string                  `json:"role"`
+	Model        string                  `json:"model"`
+	Content      []AnthropicContentBlock `json:"content"`
+	StopReason   *string                 `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage          `json:"usage"`
+}
+
+// AnthropicUsage token 用量
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Anthropic 流式事件结构，字段不使用 omitempty 以保证空字符串照常输出
+
+// AnthropicMessageStartEvent message_start 事件
+type AnthropicMessageStartEvent struct {
+	Type    string                    `json:"type"`
+	Message AnthropicMessagesResponse `json:"message"`
+}
+
+// AnthropicContentBlockStartEvent content_block_start 事件
+type AnthropicContentBlockStartEvent struct {
+	Type         string         `json:"type"`
+	Index        int            `json:"index"`
+	ContentBlock map[string]any `json:"content_block"`
+}
+
+// AnthropicContentBlockDeltaEvent content_block_delta 事件
+type AnthropicContentBlockDeltaEvent struct {
+	Type  string            `json:"type"`
+	Index int               `json:"index"`
+	Delta map[string]string `json:"delta"`
+}
+
+// AnthropicContentBlockStopEvent content_block_stop 事件
+type AnthropicContentBlockStopEvent struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+}
+
+// AnthropicMessageDeltaEvent message_delta 事件
+type AnthropicMessageDeltaEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		StopReason   string  `json:"stop_reason"`
+		StopSequence *string `json:"stop_sequence"`
+	} `json:"delta"`
+	Usage AnthropicUsage `json:"usage"`
+}
+
+// AnthropicMessageStopEvent message_stop 事件
+type AnthropicMessageStopEvent struct {
+	Type string `json:"type"`
+}
+
+// AnthropicToChatGPT 将 Anthropic Messages 请求转换为 ChatCompletion 请求
+// 转换后的请求沿用 ChatGPTToMonica / ChatGPTToCustomBot 的处理路径
+func AnthropicToChatGPT(req *AnthropicMessagesRequest) (openai.ChatCompletionRequest, error) {
+	chatReq := openai.ChatCompletionRequest{
+		Model:     req.Model,
+		MaxTokens: req.MaxTokens,
+		Stop:      req.StopSequences,
+		Stream:    req.Stream,
+	}
+	if req.Temperature != nil {
+		chatReq.Temperature = *req.Temperature
+	}
+	if req.TopP != nil {
+		chatReq.TopP = *req.TopP
+	}
+	if req.Metadata != nil {
+		chatReq.User = req.Metadata.UserID
+	}
+
+	if system := req.System.Text(); system != "" {
+		chatReq.Messages = append(chatReq.Messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: system,
+		})
+	}
+
+	for _, msg := range req.Messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			return chatReq, fmt.Errorf("unsupported role: %s", msg.Role)
+		}
+		chatReq.Messages = append(chatReq.Messages, anthropicMessageToChatGPT(msg)...)
+	}
+
+	for _, tool := range req.Tools {
+		chatReq.Tools = append(chatReq.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+	if req.ToolChoice != nil {
+		switch req.ToolChoice.Type {
+		case "any":
+			chatReq.ToolChoice = "required"
+		case "none":
+			chatReq.ToolChoice = "none"
+		case "tool":
+			chatReq.ToolChoice = map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": req.ToolChoice.Name},
+			}
+		}
+		if req.ToolChoice.DisableParallelToolUse {
+			chatReq.ParallelToolCalls = false
+		}
+	}
+
+	if len(chatReq.Messages) == 0 {
+		return chatReq, fmt.Errorf("empty messages")
+	}
+	return chatReq, nil
+}
+
+// anthropicMessageToChatGPT 转换单条消息
+// tool_use 块转换为 assistant 的 tool_calls，tool_result 块转换为 role=tool 消息，存在图片或文档时使用 MultiContent
+func anthropicMessageToChatGPT(msg AnthropicMessage) []openai.ChatCompletionMessage {
+	var messages []openai.ChatCompletionMessage
+	var texts []string
+	var images []openai.ChatMessagePart
+	var toolCalls []openai.ToolCall
+	for _, block := range msg.Content {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+		case "image":
+			if url := anthropicImageURL(block.Source); url != "" {
+				images = append(images, openai.ChatMessagePart{
+					Type:     openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{URL: url},
+				})
+			}
+		case "document":
+			if block.Source != nil && block.Source.Type == "file" {
+				images = append(images, NewFileIDPart(block.Source.FileID, block.Title))
+			} else if url := anthropicDocumentURL(block.Source); url != "" {
+				images = append(images, NewFilePart(url, block.Title))
+			}
+		case "tool_use":
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			toolCalls = append(toolCalls, openai.ToolCall{
+				ID:       block.ID,
+				Type:     openai.ToolTypeFunction,
+				Function: openai.FunctionCall{Name: block.Name, Arguments: arguments},
+			})
+		case "tool_result":
+			result := block.Content.Text()
+			if block.IsError {
+				result = "[error] " + result
+			}
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				ToolCallID: block.ToolUseID,
+				Content:    result,
+			})
+		}
+		// thinking 块为历史思考过程，上游无法复用，直接丢弃
+	}
+
+	text := strings.Join(texts, "\n")
+	switch {
+	case len(toolCalls) > 0:
+		messages = append(messages, openai.ChatCompletionMessage{Role: msg.Role, Content: text, ToolCalls: toolCalls})
+	case len(images) > 0:
+		parts := make([]openai.ChatMessagePart, 0, len(images)+1)
+		parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: text})
+		parts = append(parts, images...)
+		messages = append(messages, openai.ChatCompletionMessage{Role: msg.Role, MultiContent: parts})
+	case text != "" || len(messages) == 0:
+		messages = append(messages, openai.ChatCompletionMessage{Role: msg.Role, Content: text})
+	}
+	return messages
+}
+
+// anthropicImageURL 将图片来源转换为 image_url 可用的地址
+func anthropicImageURL(source *AnthropicImageSource) string {
+	if source == nil {
+		return ""
+	}
+	switch source.Type {
+	case "base64":
+		return fmt.Sprintf("data:%s;base64,%s", source.MediaType, source.Data)
+	case "url":
+		return source.URL
+	default:
+		return ""
+	}
+}
+
+// anthropicDocumentURL 将文档来源转换为 file 内容段可用的地址，纯文本来源编码为 data: URL
+func anthropicDocumentURL(source *AnthropicImageSource) string {
+	if source == nil {
+		return ""
+	}
+	if source.Type == "text" {
+		mediaType := source.MediaType
+		if mediaType == "" {
+			mediaType = "text/plain"
+		}
+		return fmt.Sprintf("data:%s;base64,%s", mediaType, base64.StdEncoding.EncodeToString([]byte(source.Data)))
+	}
+	return anthropicImageURL(source)
+}
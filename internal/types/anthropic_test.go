@@ -0,0 +1,87 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// TestAnthropicToChatGPT 测试 Messages 请求的转换：system、字符串内容、tool_use/tool_result、图片与 tool_choice
+func TestAnthropicToChatGPT(t *testing.T) {
+	var req AnthropicMessagesRequest
+	err := json.Unmarshal([]byte(`{
+		"model": "claude-sonnet-4-5",
+		"system": [{"type": "text", "text": "Be brief."}],
+		"max_tokens": 256,
+		"stop_sequences": ["END"],
+		"metadata": {"user_id": "u1"},
+		"messages": [
+			{"role": "user", "content": "What's the weather?"},
+			{"role": "assistant", "content": [
+				{"type": "thinking", "thinking": "need a tool", "signature": "sig"},
+				{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": "sunny", "is_error": true},
+				{"type": "text", "text": "And this?"},
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "AAAA"}}
+			]}
+		],
+		"tools": [{"name": "get_weather", "input_schema": {"type": "object"}}],
+		"tool_choice": {"type": "any"}
+	}`), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chatReq, err := AnthropicToChatGPT(&req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chatReq.MaxTokens != 256 || chatReq.User != "u1" || len(chatReq.Stop) != 1 || chatReq.ToolChoice != "required" {
+		t.Errorf("请求参数转换错误: %+v", chatReq)
+	}
+	if len(chatReq.Tools) != 1 || chatReq.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("工具定义转换错误: %+v", chatReq.Tools)
+	}
+
+	messages := chatReq.Messages
+	if len(messages) != 5 {
+		t.Fatalf("消息数量错误: %+v", messages)
+	}
+	if messages[0].Role != openai.ChatMessageRoleSystem || messages[0].Content != "Be brief." {
+		t.Errorf("system 转换错误: %+v", messages[0])
+	}
+	if messages[1].Content != "What's the weather?" {
+		t.Errorf("字符串内容转换错误: %+v", messages[1])
+	}
+	if call := messages[2].ToolCalls; len(call) != 1 || call[0].ID != "toolu_1" || call[0].Function.Arguments != `{"city": "Paris"}` {
+		t.Errorf("tool_use 转换错误: %+v", messages[2])
+	}
+	if messages[3].Role != openai.ChatMessageRoleTool || messages[3].ToolCallID != "toolu_1" || messages[3].Content != "[error] sunny" {
+		t.Errorf("tool_result 转换错误: %+v", messages[3])
+	}
+	if parts := messages[4].MultiContent; len(parts) != 2 || parts[0].Text != "And this?" || parts[1].ImageURL.URL != "data:image/png;base64,AAAA" {
+		t.Errorf("图片转换错误: %+v", messages[4])
+	}
+
+	req.Messages[0].Role = "system"
+	if _, err := AnthropicToChatGPT(&req); err == nil {
+		t.Error("messages 中的 system 角色应返回错误")
+	}
+}
+
+// TestAnthropicThinkingEnabled 测试只有 type 为 enabled 时才开启扩展思考
+func TestAnthropicThinkingEnabled(t *testing.T) {
+	var thinking *AnthropicThinking
+	if thinking.Enabled() {
+		t.Error("未设置 thinking 时不应开启")
+	}
+	if (&AnthropicThinking{Type: "disabled"}).Enabled() {
+		t.Error("disabled 时不应开启")
+	}
+	if !(&AnthropicThinking{Type: "enabled", BudgetTokens: 1024}).Enabled() {
+		t.Error("enabled 时应开启")
+	}
+}
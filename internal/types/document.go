@@ -0,0 +1,283 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"monica-proxy/internal/account"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+	"monica-proxy/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+)
+
+// ChatMessagePartTypeFile OpenAI 的 file 内容段
+// go-openai 的 ChatMessagePart 没有 file 字段，解析请求时文件数据放在 ImageURL.URL，文件名放在 Text
+const ChatMessagePartTypeFile openai.ChatMessagePartType = "file"
+
+const mimeTypeDocx = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// documentExtTypes 常见文档扩展名对应的 MIME 类型
+var documentExtTypes = map[string]string{
+	".pdf":      "application/pdf",
+	".txt":      "text/plain",
+	".md":       "text/markdown",
+	".markdown": "text/markdown",
+	".csv":      "text/csv",
+	".docx":     mimeTypeDocx,
+	".xlsx":     "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx":     "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// NewFilePart 构造文件内容段，data 为 data: URL
+func NewFilePart(data, filename string) openai.ChatMessagePart {
+	return openai.ChatMessagePart{
+		Type:     ChatMessagePartTypeFile,
+		Text:     filename,
+		ImageURL: &openai.ChatMessageImageURL{URL: data},
+	}
+}
+
+// filePartData 取出文件内容段中的数据与文件名
+func filePartData(part openai.ChatMessagePart) (data, filename string) {
+	if part.ImageURL != nil {
+		data = part.ImageURL.URL
+	}
+	return data, part.Text
+}
+
+// isDocumentDataURL 是否为非图片类型的 data: URL
+func isDocumentDataURL(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "data:") && !strings.HasPrefix(lower, "data:image/")
+}
+
+// parseDataURL 解析 data: URL，支持 base64 与百分号编码两种形式
+func parseDataURL(s string) (string, []byte, error) {
+	if !strings.HasPrefix(strings.ToLower(s), "data:") {
+		return "", nil, fmt.Errorf("file data must be a data: URL")
+	}
+	header, payload, ok := strings.Cut(s[len("data:"):], ",")
+	if !ok {
+		return "", nil, fmt.Errorf("invalid data URL")
+	}
+
+	isBase64 := false
+	params := strings.Split(header, ";")
+	if last := params[len(params)-1]; strings.EqualFold(last, "base64") {
+		isBase64 = true
+		params = params[:len(params)-1]
+	}
+	mimeType := strings.ToLower(strings.TrimSpace(params[0]))
+
+	if isBase64 {
+		data, err := utils.Base64Decode(payload)
+		if err != nil {
+			return "", nil, fmt.Errorf("decode base64 failed: %v", err)
+		}
+		return mimeType, data, nil
+	}
+	text, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("decode data URL failed: %v", err)
+	}
+	return mimeType, []byte(text), nil
+}
+
+// prepareDocument 读取并校验文档附件，引用 file_id 的附件直接从文件元数据存储中获取
+// 命中缓存时返回文件信息，否则返回待上传的文件；错误均为 400 的 AppError
+func prepareDocument(ctx context.Context, cfg *config.Config, part openai.ChatMessagePart) (*FileInfo, *pendingUpload, error) {
+	// 通过 /v1/files 上传过的文件直接使用保存的文件信息
+	if fileID, ok := filePartID(part); ok {
+		stored, exists := Files().Get(fileID)
+		if !exists {
+			return nil, nil, errors.NewInvalidInputError("文件不存在", fmt.Errorf("no such file: %s", fileID))
+		}
+		// file_uid 属于上传文件的账号，其他账号无法引用
+		if !account.Prefer(ctx, stored.Account) {
+			return nil, nil, errors.NewInvalidInputError(fmt.Sprintf("文件 %s 由账号 %s 上传，该账号当前不可用或与本次请求的其他文件、会话不属于同一账号", fileID, stored.Account), nil)
+		}
+		info := stored.Info
+		return &info, nil, nil
+	}
+
+	source, filename := filePartData(part)
+	var (
+		mimeType string
+		data     []byte
+		err      error
+	)
+	if utils.IsRemoteURL(source) {
+		remote, fetchErr := utils.FetchRemoteFile(ctx, source)
+		if fetchErr != nil {
+			return nil, nil, errors.NewInvalidInputError("下载文件失败", fetchErr)
+		}
+		data, mimeType = remote.Data, remote.ContentType
+		if filename == "" {
+			if u, parseErr := url.Parse(source); parseErr == nil {
+				filename = path.Base(u.Path)
+			}
+		}
+	} else if mimeType, data, err = parseDataURL(source); err != nil {
+		return nil, nil, errors.NewInvalidInputError("无效的文件数据", err)
+	}
+
+	cacheKey := "doc:" + contentHash(data)
+	if fileInfo, exists := imageCache.Get(accountCacheKey(ctx, cacheKey)); exists {
+		return fileInfo, nil, nil
+	}
+
+	fileInfo, err := validateDocumentBytes(&cfg.Attachments, data, mimeType, filename)
+	if err != nil {
+		return nil, nil, errors.NewInvalidInputError("不支持的文件", err)
+	}
+	return nil, &pendingUpload{
+		data:         data,
+		info:         fileInfo,
+		indexTimeout: cfg.Attachments.IndexTimeout,
+		cacheKeys:    []string{cacheKey},
+	}, nil
+}
+
+// UploadFileBytes 上传 /v1/files 接收的文件，图片按图片规则校验，其余按文档附件规则校验，大小上限为 maxSize
+func UploadFileBytes(ctx context.Context, cfg *config.Config, data []byte, mimeType, filename string, maxSize int64) (*FileInfo, error) {
+	upload := &pendingUpload{data: data, indexTimeout: cfg.Attachments.IndexTimeout}
+	if detected := http.DetectContentType(data); isImageFile(detected, filename) {
+		if int64(len(data)) > maxSize {
+			return nil, errors.NewInvalidInputError("不支持的文件", fmt.Errorf("file size exceeds limit: %d > %d", len(data), maxSize))
+		}
+		if cfg.ImagePreprocess.Enabled {
+			processed, err := preprocessImage(cfg, data, "")
+			if err != nil {
+				return nil, errors.NewInvalidInputError("不支持的文件", err)
+			}
+			upload.data, detected = processed.Data, processed.MimeType
+		}
+		fileInfo, err := validateImageBytes(upload.data, detected)
+		if err != nil {
+			return nil, errors.NewInvalidInputError("不支持的文件", err)
+		}
+		upload.info = fileInfo
+		upload.indexTimeout = imageIndexTimeout
+	} else {
+		attachments := cfg.Attachments
+		attachments.MaxSize = maxSize
+		fileInfo, err := validateDocumentBytes(&attachments, data, mimeType, filename)
+		if err != nil {
+			return nil, errors.NewInvalidInputError("不支持的文件", err)
+		}
+		upload.info = fileInfo
+	}
+
+	uploadBatch(ctx, cfg, []*pendingUpload{upload})
+	if upload.err != nil {
+		return nil, uploadError(upload)
+	}
+	return upload.result, nil
+}
+
+// isImageFile 是否按图片处理：Monica 支持的图片格式，以及可由预处理转换的 BMP、TIFF
+func isImageFile(detected, filename string) bool {
+	if SupportedImageTypes[detected] || detected == "image/bmp" {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".tif" || ext == ".tiff"
+}
+
+// validateDocumentBytes 校验文档类型与大小
+// 声明的类型缺失时根据文件名推断，并通过内容嗅探确认与声明的类型一致
+func validateDocumentBytes(cfg *config.AttachmentsConfig, data []byte, mimeType, filename string) (*FileInfo, error) {
+	if int64(len(data)) > cfg.MaxSize {
+		return nil, fmt.Errorf("file size exceeds limit: %d > %d", len(data), cfg.MaxSize)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	filename = filepath.Base(filename)
+	if filename == "." || filename == "/" {
+		filename = ""
+	}
+	mimeType, _, _ = strings.Cut(strings.ToLower(mimeType), ";")
+	mimeType = strings.TrimSpace(mimeType)
+	// 嗅探或声明的类型过于宽泛时以扩展名为准，例如 docx 会被识别为 zip
+	if extType := documentExtTypes[strings.ToLower(filepath.Ext(filename))]; extType != "" {
+		switch mimeType {
+		case "", "application/octet-stream", "application/zip", "text/plain":
+			mimeType = extType
+		}
+	}
+	if mimeType == "" {
+		return nil, fmt.Errorf("unknown file type")
+	}
+
+	allowed := false
+	for _, t := range cfg.AllowedTypes {
+		if strings.EqualFold(t, mimeType) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("file type %s is not allowed", mimeType)
+	}
+	if err := sniffDocument(data, mimeType); err != nil {
+		return nil, err
+	}
+
+	ext := filepath.Ext(filename)
+	if ext == "" {
+		ext = documentExt(mimeType)
+		filename = uuid.New().String() + ext
+	}
+	return &FileInfo{
+		FileName: filename,
+		FileSize: int64(len(data)),
+		FileType: mimeType,
+		FileExt:  strings.TrimPrefix(ext, "."),
+	}, nil
+}
+
+// sniffDocument 检查文件内容与声明的类型是否一致
+func sniffDocument(data []byte, mimeType string) error {
+	detected, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	switch {
+	case strings.HasPrefix(mimeType, "text/"):
+		if !utf8.Valid(data) {
+			return fmt.Errorf("file content is not valid UTF-8 text")
+		}
+	case mimeType == "application/pdf":
+		if detected != "application/pdf" {
+			return fmt.Errorf("file content (%s) does not match declared type %s", detected, mimeType)
+		}
+	case strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument."):
+		// Office Open XML 文件本质是 zip 包
+		if detected != "application/zip" {
+			return fmt.Errorf("file content (%s) does not match declared type %s", detected, mimeType)
+		}
+	}
+	return nil
+}
+
+// documentExt 根据 MIME 类型推断扩展名
+func documentExt(mimeType string) string {
+	for ext, t := range documentExtTypes {
+		if t == mimeType && ext != ".markdown" {
+			return ext
+		}
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
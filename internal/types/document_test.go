@@ -0,0 +1,80 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"monica-proxy/internal/config"
+)
+
+// TestParseDataURL 测试 base64 与百分号编码两种 data: URL
+func TestParseDataURL(t *testing.T) {
+	mimeType, data, err := parseDataURL("data:text/plain;base64,aGVsbG8=")
+	if err != nil || mimeType != "text/plain" || string(data) != "hello" {
+		t.Errorf("base64 解析错误: %q %q %v", mimeType, data, err)
+	}
+	mimeType, data, err = parseDataURL("data:text/csv,a%2Cb%0A1%2C2")
+	if err != nil || mimeType != "text/csv" || string(data) != "a,b\n1,2" {
+		t.Errorf("百分号编码解析错误: %q %q %v", mimeType, data, err)
+	}
+	if _, _, err := parseDataURL("https://example.com/a.pdf"); err == nil {
+		t.Error("非 data: URL 应返回错误")
+	}
+}
+
+// TestValidateDocumentBytes 测试文档类型、大小与内容嗅探校验
+func TestValidateDocumentBytes(t *testing.T) {
+	cfg := &config.AttachmentsConfig{
+		MaxSize:      1024,
+		AllowedTypes: []string{"application/pdf", "text/plain", "text/markdown"},
+	}
+
+	info, err := validateDocumentBytes(cfg, []byte("%PDF-1.7\n..."), "application/pdf", "../report.pdf")
+	if err != nil || info.FileName != "report.pdf" || info.FileType != "application/pdf" {
+		t.Errorf("PDF 校验错误: %+v %v", info, err)
+	}
+	info, err = validateDocumentBytes(cfg, []byte("# title"), "", "notes.md")
+	if err != nil || info.FileType != "text/markdown" {
+		t.Errorf("应根据扩展名推断类型: %+v %v", info, err)
+	}
+	info, err = validateDocumentBytes(cfg, []byte("hello"), "text/plain", "")
+	if err != nil || info.FileExt != "txt" {
+		t.Errorf("缺少文件名时应生成文件名: %+v %v", info, err)
+	}
+
+	cases := map[string]struct {
+		data     []byte
+		mimeType string
+	}{
+		"类型不允许":  {[]byte("a,b"), "text/csv"},
+		"内容不符":   {[]byte("plain text"), "application/pdf"},
+		"非UTF-8": {[]byte{0xff, 0xfe, 0xfd}, "text/plain"},
+		"超过大小":   {make([]byte, 2048), "text/plain"},
+	}
+	for name, c := range cases {
+		if _, err := validateDocumentBytes(cfg, c.data, c.mimeType, ""); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+}
+
+// TestChatCompletionRequestFilePart 测试 file 内容段在解析请求时被保留
+func TestChatCompletionRequestFilePart(t *testing.T) {
+	body := `{"model":"gpt-4o","reasoning_mode":"tags","messages":[{"role":"user","content":[
+		{"type":"text","text":"总结这份文档"},
+		{"type":"file","file":{"file_data":"data:application/pdf;base64,JVBERi0=","filename":"a.pdf"}}]}]}`
+	var req ChatCompletionRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.ReasoningMode != "tags" || req.Model != "gpt-4o" {
+		t.Errorf("扩展字段解析错误: %+v", req)
+	}
+	parts := req.Messages[0].MultiContent
+	if len(parts) != 2 || parts[1].Type != ChatMessagePartTypeFile {
+		t.Fatalf("内容段错误: %+v", parts)
+	}
+	if data, filename := filePartData(parts[1]); data != "data:application/pdf;base64,JVBERi0=" || filename != "a.pdf" {
+		t.Errorf("file 内容段错误: %q %q", data, filename)
+	}
+}
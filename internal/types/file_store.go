@@ -0,0 +1,235 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"monica-proxy/internal/account"
+	"monica-proxy/internal/config"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// FileObject OpenAI 文件对象
+type FileObject struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"` // 固定为 file
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Status    string `json:"status"` // 上传时已等待 Monica 解析完成，固定为 processed
+}
+
+// FileList 文件列表
+type FileList struct {
+	Object  string       `json:"object"` // 固定为 list
+	Data    []FileObject `json:"data"`
+	HasMore bool         `json:"has_more"`
+}
+
+// FileDeleted 删除文件的结果
+type FileDeleted struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"` // 固定为 file
+	Deleted bool   `json:"deleted"`
+}
+
+// StoredFile 通过 /v1/files 上传的文件，Info 为 Monica 返回的文件信息，引用时直接附加到消息中
+type StoredFile struct {
+	ID        string   `json:"id"`
+	Filename  string   `json:"filename"`
+	Purpose   string   `json:"purpose"`
+	CreatedAt int64    `json:"created_at"`
+	Info      FileInfo `json:"info"`
+	Account   string   `json:"account,omitempty"` // 上传文件的账号，file_uid 只能由该账号使用
+}
+
+// Object 转换为 OpenAI 文件对象
+func (f *StoredFile) Object() FileObject {
+	return FileObject{
+		ID:        f.ID,
+		Object:    "file",
+		Bytes:     f.Info.FileSize,
+		CreatedAt: f.CreatedAt,
+		Filename:  f.Filename,
+		Purpose:   f.Purpose,
+		Status:    "processed",
+	}
+}
+
+// FileStore 文件元数据存储，path 非空时每次修改后整体写回磁盘
+type FileStore struct {
+	mu    sync.RWMutex
+	path  string
+	files map[string]*StoredFile
+}
+
+// fileStore 全局文件元数据存储，启动时由 InitFileStore 从磁盘加载
+var fileStore = &FileStore{files: make(map[string]*StoredFile)}
+
+// InitFileStore 根据配置加载文件元数据存储
+func InitFileStore(cfg *config.Config) error {
+	store, err := NewFileStore(cfg.Files.StorePath)
+	if err != nil {
+		return err
+	}
+	fileStore = store
+	return nil
+}
+
+// Files 返回全局文件元数据存储
+func Files() *FileStore {
+	return fileStore
+}
+
+// NewFileStore 创建文件元数据存储，path 对应的文件已存在时加载其中的记录
+func NewFileStore(path string) (*FileStore, error) {
+	fs := &FileStore{path: path, files: make(map[string]*StoredFile)}
+	if path == "" {
+		return fs, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read file store failed: %w", err)
+	}
+
+	var files []*StoredFile
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, fmt.Errorf("parse file store failed: %w", err)
+	}
+	for _, f := range files {
+		fs.files[f.ID] = f
+	}
+	return fs, nil
+}
+
+// Get 获取文件
+func (fs *FileStore) Get(id string) (*StoredFile, bool) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	f, ok := fs.files[id]
+	return f, ok
+}
+
+// List 按创建时间倒序列出文件，purpose 为空时列出全部
+func (fs *FileStore) List(purpose string) []*StoredFile {
+	fs.mu.RLock()
+	files := make([]*StoredFile, 0, len(fs.files))
+	for _, f := range fs.files {
+		if purpose == "" || f.Purpose == purpose {
+			files = append(files, f)
+		}
+	}
+	fs.mu.RUnlock()
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].CreatedAt != files[j].CreatedAt {
+			return files[i].CreatedAt > files[j].CreatedAt
+		}
+		return files[i].ID > files[j].ID
+	})
+	return files
+}
+
+// Put 保存文件
+func (fs *FileStore) Put(f *StoredFile) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.files[f.ID] = f
+	if err := fs.saveLocked(); err != nil {
+		delete(fs.files, f.ID)
+		return err
+	}
+	return nil
+}
+
+// Delete 删除文件，返回是否存在
+func (fs *FileStore) Delete(id string) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, ok := fs.files[id]
+	if !ok {
+		return false, nil
+	}
+	delete(fs.files, id)
+	if err := fs.saveLocked(); err != nil {
+		fs.files[id] = f
+		return false, err
+	}
+	return true, nil
+}
+
+// saveLocked 先写临时文件再重命名，避免写入中断时损坏已有数据，调用方需持有写锁
+func (fs *FileStore) saveLocked() error {
+	if fs.path == "" {
+		return nil
+	}
+
+	files := make([]*StoredFile, 0, len(fs.files))
+	for _, f := range fs.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal file store failed: %w", err)
+	}
+
+	if dir := filepath.Dir(fs.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create file store dir failed: %w", err)
+		}
+	}
+	tmp := fs.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write file store failed: %w", err)
+	}
+	if err := os.Rename(tmp, fs.path); err != nil {
+		return fmt.Errorf("write file store failed: %w", err)
+	}
+	return nil
+}
+
+// fileIDPrefix file 内容段通过 file_id 引用已上传文件时，ImageURL.URL 中使用的前缀
+const fileIDPrefix = "file_id:"
+
+// NewFileIDPart 构造引用已上传文件的 file 内容段
+func NewFileIDPart(fileID, filename string) openai.ChatMessagePart {
+	return NewFilePart(fileIDPrefix+fileID, filename)
+}
+
+// filePartID 取出 file 内容段引用的文件 ID
+func filePartID(part openai.ChatMessagePart) (string, bool) {
+	data, _ := filePartData(part)
+	if !strings.HasPrefix(data, fileIDPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(data, fileIDPrefix), true
+}
+
+// preferFileAccounts 让请求使用消息引用的已上传文件所属的账号，需要在请求分配账号之前调用
+func preferFileAccounts(ctx context.Context, messages []openai.ChatCompletionMessage) {
+	for _, msg := range messages {
+		for _, part := range msg.MultiContent {
+			if part.Type != ChatMessagePartTypeFile {
+				continue
+			}
+			if fileID, ok := filePartID(part); ok {
+				if stored, exists := Files().Get(fileID); exists {
+					account.Prefer(ctx, stored.Account)
+				}
+			}
+		}
+	}
+}
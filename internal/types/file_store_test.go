@@ -0,0 +1,64 @@
+package types
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"monica-proxy/internal/config"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// TestFileStorePersistence 测试文件元数据写回磁盘后可重新加载
+func TestFileStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store", "files.json")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"file-a", "file-b"} {
+		f := &StoredFile{ID: id, Filename: id + ".pdf", Purpose: "assistants", CreatedAt: int64(i), Info: FileInfo{FileUID: "uid-" + id, FileTokens: 42}}
+		if err := store.Put(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ok, err := store.Delete("file-a"); !ok || err != nil {
+		t.Fatalf("Delete = %v, %v", ok, err)
+	}
+
+	reloaded, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := reloaded.List("")
+	if len(files) != 1 || files[0].ID != "file-b" || files[0].Info.FileUID != "uid-file-b" || files[0].Info.FileTokens != 42 {
+		t.Errorf("重新加载结果错误: %+v", files)
+	}
+	if len(reloaded.List("batch")) != 0 {
+		t.Error("按 purpose 过滤结果错误")
+	}
+}
+
+// TestUploadAttachmentsFileID 测试 file_id 引用直接使用保存的文件信息，无需上传
+func TestUploadAttachmentsFileID(t *testing.T) {
+	previous := fileStore
+	defer func() { fileStore = previous }()
+	fileStore, _ = NewFileStore("")
+	_ = fileStore.Put(&StoredFile{ID: "file-x", Info: FileInfo{FileUID: "uid-x"}})
+
+	cfg := &config.Config{}
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+		{Role: openai.ChatMessageRoleUser, MultiContent: []openai.ChatMessagePart{NewFileIDPart("file-x", "")}},
+	}
+	uploads, err := uploadMessageAttachments(context.Background(), cfg, messages)
+	if err != nil || uploads[0] != nil || len(uploads[1].files) != 1 || uploads[1].files[0].FileUID != "uid-x" {
+		t.Errorf("uploadMessageAttachments = %+v, %v", uploads, err)
+	}
+
+	messages[1].MultiContent = []openai.ChatMessagePart{NewFileIDPart("file-missing", "")}
+	if _, err := uploadMessageAttachments(context.Background(), cfg, messages); err == nil {
+		t.Error("不存在的文件应返回错误")
+	}
+}
@@ -4,178 +4,123 @@ import (
 	"context"
 	"fmt"
 	"monica-proxy/internal/config"
+	"monica-proxy/internal/imageproc"
+	"monica-proxy/internal/logger"
 	"monica-proxy/internal/utils"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/cespare/xxhash/v2"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 const MaxFileSize = 10 * 1024 * 1024 // 10MB
 
-var imageCache sync.Map
-
-// sampleAndHash 对base64字符串进行采样并计算xxHash
-func sampleAndHash(data string) string {
-	// 如果数据长度小于1024，直接计算整个字符串的哈希
-	if len(data) <= 1024 {
-		return fmt.Sprintf("%x", xxhash.Sum64String(data))
-	}
-
-	// 采样策略：
-	// 1. 取前256字节
-	// 2. 取中间256字节
-	// 3. 取最后256字节
-	var samples []string
-	samples = append(samples, data[:256])
-	mid := len(data) / 2
-	samples = append(samples, data[mid-128:mid+128])
-	samples = append(samples, data[len(data)-256:])
-
-	// 将采样数据拼接后计算哈希
-	return fmt.Sprintf("%x", xxhash.Sum64String(strings.Join(samples, "")))
-}
-
-// UploadBase64Image 上传base64编码的图片到Monica
-func UploadBase64Image(ctx context.Context, cfg *config.Config, base64Data string) (*FileInfo, error) {
-	// 1. 生成缓存key
-	cacheKey := sampleAndHash(base64Data)
-
-	// 2. 检查缓存
-	if value, exists := imageCache.Load(cacheKey); exists {
-		return value.(*FileInfo), nil
-	}
+const imageIndexTimeout = 5 * time.Second // 等待图片解析完成的最长时间
+
+// prepareImage 读取、预处理并校验消息中的图片，支持 data: URL 与 http(s) 链接，detail 为 image_url 的 detail 提示
+// 命中缓存时返回文件信息，否则返回待上传的文件
+func prepareImage(ctx context.Context, cfg *config.Config, imageURL, detail string) (*FileInfo, *pendingUpload, error) {
+	var (
+		imageData []byte
+		mimeType  string
+		urlKey    string
+	)
+	if utils.IsRemoteURL(imageURL) {
+		// 先按链接查找缓存，下载后再按内容查找，不同链接指向同一张图片时不会重复上传
+		urlKey = "url:" + contentHash([]byte(imageURL))
+		if fileInfo, exists := imageCache.Get(accountCacheKey(ctx, urlKey)); exists {
+			return fileInfo, nil, nil
+		}
+		remote, err := utils.FetchRemoteFile(ctx, imageURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetch remote image failed: %v", err)
+		}
+		// 图片格式在预处理与校验时确认，BMP、TIFF 等格式可能需要转换
+		imageData, mimeType = remote.Data, remote.ContentType
+	} else {
+		// 移除 "data:image/png;base64," 这样的前缀
+		parts := strings.Split(imageURL, ",")
+		if len(parts) != 2 {
+			return nil, nil, fmt.Errorf("invalid base64 image format")
+		}
 
-	// 3. 解析base64数据
-	// 移除 "data:image/png;base64," 这样的前缀
-	parts := strings.Split(base64Data, ",")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid base64 image format")
-	}
+		// 获取图片类型
+		mimeType = strings.TrimSuffix(strings.TrimPrefix(parts[0], "data:"), ";base64")
+		if !strings.HasPrefix(mimeType, "image/") {
+			return nil, nil, fmt.Errorf("invalid image mime type: %s", mimeType)
+		}
 
-	// 获取图片类型
-	mimeType := strings.TrimSuffix(strings.TrimPrefix(parts[0], "data:"), ";base64")
-	if !strings.HasPrefix(mimeType, "image/") {
-		return nil, fmt.Errorf("invalid image mime type: %s", mimeType)
+		// 解码base64数据
+		var err error
+		if imageData, err = utils.Base64Decode(parts[1]); err != nil {
+			return nil, nil, fmt.Errorf("decode base64 failed: %v", err)
+		}
 	}
 
-	// 解码base64数据
-	imageData, err := utils.Base64Decode(parts[1])
-	if err != nil {
-		return nil, fmt.Errorf("decode base64 failed: %v", err)
+	// 预处理结果与 detail 有关，low 单独缓存
+	cacheKeys := []string{"img:" + contentHash(imageData)}
+	if cfg.ImagePreprocess.Enabled && detail == imageproc.DetailLow {
+		cacheKeys[0] += ":" + imageproc.DetailLow
 	}
-
-	// 4. 验证图片格式和大小
-	fileInfo, err := validateImageBytes(imageData, mimeType)
-	if err != nil {
-		return nil, fmt.Errorf("validate image failed: %v", err)
+	if fileInfo, exists := imageCache.Get(accountCacheKey(ctx, cacheKeys[0])); exists {
+		return fileInfo, nil, nil
 	}
-	// log.Printf("file info: %+v", fileInfo)
-
-	// 5. 获取预签名URL
-	preSignReq := &PreSignRequest{
-		FilenameList: []string{fileInfo.FileName},
-		Module:       ImageModule,
-		Location:     ImageLocation,
-		ObjID:        uuid.New().String(),
+	if urlKey != "" {
+		cacheKeys = append(cacheKeys, urlKey)
 	}
 
-	var preSignResp PreSignResponse
-	_, err = utils.RestyDefaultClient.R().
-		SetContext(ctx).
-		SetHeader("cookie", cfg.Monica.Cookie).
-		SetBody(preSignReq).
-		SetResult(&preSignResp).
-		Post(PreSignURL)
-
-	if err != nil {
-		return nil, fmt.Errorf("get pre-sign url failed: %v", err)
-	}
-
-	if len(preSignResp.Data.PreSignURLList) == 0 || len(preSignResp.Data.ObjectURLList) == 0 {
-		return nil, fmt.Errorf("no pre-sign url or object url returned")
+	if cfg.ImagePreprocess.Enabled {
+		processed, err := preprocessImage(cfg, imageData, detail)
+		if err != nil {
+			return nil, nil, err
+		}
+		imageData, mimeType = processed.Data, processed.MimeType
 	}
-	// log.Printf("preSign info: %+v", preSignResp)
-
-	// 6. 上传图片数据
-	_, err = utils.RestyDefaultClient.R().
-		SetContext(ctx).
-		SetHeader("Content-Type", fileInfo.FileType).
-		SetBody(imageData).
-		Put(preSignResp.Data.PreSignURLList[0])
 
+	fileInfo, err := validateImageBytes(imageData, mimeType)
 	if err != nil {
-		return nil, fmt.Errorf("upload file failed: %v", err)
-	}
-
-	// 7. 创建文件对象
-	fileInfo.ObjectURL = preSignResp.Data.ObjectURLList[0]
-	uploadReq := &FileUploadRequest{
-		Data: []FileInfo{*fileInfo},
+		return nil, nil, fmt.Errorf("validate image failed: %v", err)
 	}
+	return nil, &pendingUpload{
+		data:         imageData,
+		info:         fileInfo,
+		indexTimeout: imageIndexTimeout,
+		cacheKeys:    cacheKeys,
+	}, nil
+}
 
-	var uploadResp FileUploadResponse
-	_, err = utils.RestyDefaultClient.R().
-		SetContext(ctx).
-		SetHeader("cookie", cfg.Monica.Cookie).
-		SetBody(uploadReq).
-		SetResult(&uploadResp).
-		Post(FileUploadURL)
-
+// preprocessImage 缩放、重新压缩、转换格式并去除元数据，处理后的大小不超过 MaxImageSize
+func preprocessImage(cfg *config.Config, imageData []byte, detail string) (*imageproc.Result, error) {
+	result, err := imageproc.Process(imageData, detail, imageproc.Options{
+		MaxBytes:           MaxImageSize,
+		MaxDimension:       cfg.ImagePreprocess.MaxDimension,
+		LowDetailDimension: cfg.ImagePreprocess.LowDetailDimension,
+		JPEGQuality:        cfg.ImagePreprocess.JPEGQuality,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("create file object failed: %v", err)
+		return nil, fmt.Errorf("preprocess image failed: %v", err)
 	}
-	// log.Printf("uploadResp: %+v", uploadResp)
-	if len(uploadResp.Data.Items) > 0 {
-		fileInfo.FileName = uploadResp.Data.Items[0].FileName
-		fileInfo.FileType = uploadResp.Data.Items[0].FileType
-		fileInfo.FileSize = uploadResp.Data.Items[0].FileSize
-		fileInfo.FileUID = uploadResp.Data.Items[0].FileUID
-		fileInfo.FileExt = uploadResp.Data.Items[0].FileType
-		fileInfo.FileTokens = uploadResp.Data.Items[0].FileTokens
-		fileInfo.FileChunks = uploadResp.Data.Items[0].FileChunks
+	if result.Changed {
+		logger.Debug("图片已预处理",
+			zap.Int("original_size", len(imageData)),
+			zap.Int("processed_size", len(result.Data)),
+			zap.String("mime_type", result.MimeType),
+		)
 	}
+	return result, nil
+}
 
-	fileInfo.UseFullText = true
-	fileInfo.FileURL = preSignResp.Data.CDNURLList[0]
-
-	// 8. 获取文件llm读取结果知道有返回
-	var batchResp FileBatchGetResponse
-	reqMap := make(map[string][]string)
-	reqMap["file_uids"] = []string{fileInfo.FileUID}
-	var retryCount = 1
-	for {
-		if retryCount > 5 {
-			return nil, fmt.Errorf("retry limit exceeded")
-		}
-		_, err = utils.RestyDefaultClient.R().
-			SetContext(ctx).
-			SetHeader("cookie", cfg.Monica.Cookie).
-			SetBody(reqMap).
-			SetResult(&batchResp).
-			Post(FileGetURL)
-		if err != nil {
-			return nil, fmt.Errorf("batch get file failed: %v", err)
-		}
-		if len(batchResp.Data.Items) > 0 && batchResp.Data.Items[0].FileChunks > 0 {
-			break
-		} else {
-			retryCount++
-		}
-		time.Sleep(1 * time.Second)
-	}
-	fileInfo.FileChunks = batchResp.Data.Items[0].FileChunks
-	fileInfo.FileTokens = batchResp.Data.Items[0].FileTokens
-	fileInfo.URL = ""
-	fileInfo.ObjectURL = ""
-
-	// 9. 保存到缓存
-	imageCache.Store(cacheKey, fileInfo)
+// FileIndexError Monica 解析文件失败，IndexState 与 ErrorMessage 来自 batch_get_file
+type FileIndexError struct {
+	FileName     string
+	IndexState   int
+	ErrorMessage string
+}
 
-	return fileInfo, nil
+func (e *FileIndexError) Error() string {
+	return fmt.Sprintf("index state %d: %s", e.IndexState, e.ErrorMessage)
 }
 
 // validateImageBytes 验证图片字节数据的格式和大小
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"monica-proxy/internal/account"
+	"monica-proxy/internal/cache"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// imageCacheFlushInterval 缓存持久化到磁盘的间隔
+const imageCacheFlushInterval = time.Minute
+
+// imageCache 已上传图片与文档的缓存，按内容的 SHA-256 索引，值为 Monica 返回的文件信息
+// 启动时由 InitImageCache 按配置重建，未初始化时不限制容量
+var imageCache, _ = cache.New[*FileInfo](cache.Options{})
+
+// InitImageCache 根据配置创建上传缓存，配置了持久化路径时从磁盘加载并定期写回
+func InitImageCache(cfg *config.Config) error {
+	c, err := cache.New[*FileInfo](cache.Options{
+		MaxEntries: cfg.ImageCache.MaxEntries,
+		MaxBytes:   cfg.ImageCache.MaxBytes,
+		TTL:        cfg.ImageCache.TTL,
+		Path:       cfg.ImageCache.PersistPath,
+	})
+	if err != nil {
+		return err
+	}
+	imageCache = c
+
+	if cfg.ImageCache.PersistPath != "" {
+		logger.Info("已加载上传缓存", zap.Int("entries", c.Stats().Entries))
+		go func() {
+			ticker := time.NewTicker(imageCacheFlushInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := c.Save(); err != nil {
+					logger.Warn("保存上传缓存失败", zap.Error(err))
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// FlushImageCache 将上传缓存写回磁盘，未配置持久化路径或没有修改时直接返回，用于退出前保存定期写回之后的修改
+func FlushImageCache() error {
+	return imageCache.Save()
+}
+
+// ImageCacheStats 返回上传缓存的统计信息
+func ImageCacheStats() cache.Stats {
+	return imageCache.Stats()
+}
+
+// accountCacheKey 配置了多个账号时缓存键按账号区分，file_uid 只能由上传它的账号使用
+func accountCacheKey(ctx context.Context, key string) string {
+	if scope := account.Scope(ctx); scope != "" {
+		return scope + "/" + key
+	}
+	return key
+}
+
+// contentHash 计算完整内容的 SHA-256，作为缓存键避免不同文件冲突
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
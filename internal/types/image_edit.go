@@ -0,0 +1,97 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"net/http"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+)
+
+// ImageEditRequest /v1/images/edits 与 /v1/images/variations 的 multipart 请求
+type ImageEditRequest struct {
+	Model          string
+	Prompt         string // 编辑必填，生成相似图片时忽略
+	N              int
+	Size           string
+	ResponseFormat string
+	User           string
+	Image          []byte // 原图
+	Mask           []byte // 可选的蒙版，透明区域为需要重绘的部分，尺寸须与原图一致
+}
+
+// NewMonicaImageEditRequest 上传原图与蒙版并转换为 Monica 图生图请求，variation 为 true 时生成相似的图片
+// 有蒙版时重绘蒙版的透明区域，否则按提示词修改整张图片；未指定尺寸时按原图的宽高比生成
+func NewMonicaImageEditRequest(ctx context.Context, cfg *config.Config, req *ImageEditRequest, variation bool) (*MonicaImageRequest, error) {
+	taskType := ImageTaskImageToImage
+	switch {
+	case variation:
+		taskType = ImageTaskVariation
+		req.Prompt = ""
+		req.Mask = nil
+	case req.Prompt == "":
+		return nil, errors.NewInvalidInputError("提示词不能为空", nil)
+	case req.Mask != nil:
+		taskType = ImageTaskInpainting
+	}
+
+	source, _, err := image.DecodeConfig(bytes.NewReader(req.Image))
+	if err != nil {
+		return nil, errors.NewInvalidInputError("无法识别的图片", err)
+	}
+	if req.Mask != nil {
+		mask, format, err := image.DecodeConfig(bytes.NewReader(req.Mask))
+		if err != nil || format != "png" {
+			return nil, errors.NewInvalidInputError("蒙版必须是带透明通道的 PNG 图片", err)
+		}
+		if mask.Width != source.Width || mask.Height != source.Height {
+			return nil, errors.NewInvalidInputError(fmt.Sprintf("蒙版尺寸 %dx%d 与原图 %dx%d 不一致", mask.Width, mask.Height, source.Width, source.Height), nil)
+		}
+	}
+
+	size := req.Size
+	if size == "" || size == "auto" {
+		size = fmt.Sprintf("%dx%d", source.Width, source.Height)
+	}
+	monicaReq, _, err := newImageToolsRequest(cfg, &req.Model, &req.N, size, taskType)
+	if err != nil && size != req.Size {
+		// 原图的宽高比与模型相差过大时使用模型的默认宽高比
+		monicaReq, _, err = newImageToolsRequest(cfg, &req.Model, &req.N, "auto", taskType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	monicaReq.Prompt = req.Prompt
+
+	// 原图与蒙版不经过预处理，缩放或重新压缩会改变尺寸并丢失蒙版的透明通道
+	uploads := make([]*pendingUpload, 0, 2)
+	for _, data := range [][]byte{req.Image, req.Mask} {
+		if data == nil {
+			continue
+		}
+		fileInfo, err := validateImageBytes(data, http.DetectContentType(data))
+		if err != nil {
+			return nil, errors.NewInvalidInputError("不支持的图片", err)
+		}
+		uploads = append(uploads, &pendingUpload{data: data, info: fileInfo, indexTimeout: imageIndexTimeout})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ImageUploadTimeout+imageIndexTimeout)
+	defer cancel()
+	uploadBatch(ctx, cfg, uploads)
+	for _, u := range uploads {
+		if u.err != nil {
+			return nil, errors.NewFileUploadError(u.err)
+		}
+	}
+	monicaReq.ImageFileUID = uploads[0].result.FileUID
+	monicaReq.ImageURL = uploads[0].result.FileURL
+	if len(uploads) > 1 {
+		monicaReq.MaskFileUID = uploads[1].result.FileUID
+		monicaReq.MaskURL = uploads[1].result.FileURL
+	}
+	return monicaReq, nil
+}
@@ -0,0 +1,64 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+
+	"monica-proxy/internal/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func testPNGBytes(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestNewMonicaImageEditRequest 测试任务类型选择、按原图推断宽高比与原图、蒙版的批量上传
+func TestNewMonicaImageEditRequest(t *testing.T) {
+	previous := utils.RestyDefaultClient
+	defer func() { utils.RestyDefaultClient = previous }()
+	fake := &fakeMonica{calls: make(map[string]int), polls: 1}
+	utils.RestyDefaultClient = resty.New().SetTransport(fake)
+	cfg := imageModelConfig()
+
+	req := &ImageEditRequest{Prompt: "add a hat", Image: testPNGBytes(t, 160, 90), Mask: testPNGBytes(t, 160, 90)}
+	monicaReq, err := NewMonicaImageEditRequest(context.Background(), cfg, req, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if monicaReq.TaskType != ImageTaskInpainting || monicaReq.AspectRatio != "16:9" || monicaReq.Prompt != "add a hat" {
+		t.Errorf("编辑请求错误: %+v", monicaReq)
+	}
+	if monicaReq.ImageFileUID != "uid-a" || monicaReq.MaskFileUID != "uid-b" || monicaReq.ImageURL != "https://cdn.test/a" {
+		t.Errorf("上传结果错误: %+v", monicaReq)
+	}
+	if fake.calls[PreSignURL] != 1 {
+		t.Errorf("原图与蒙版应一次预签名: %v", fake.calls)
+	}
+
+	monicaReq, err = NewMonicaImageEditRequest(context.Background(), cfg, &ImageEditRequest{Prompt: "ignored", Image: testPNGBytes(t, 64, 64)}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if monicaReq.TaskType != ImageTaskVariation || monicaReq.Prompt != "" || monicaReq.MaskFileUID != "" {
+		t.Errorf("相似图片请求错误: %+v", monicaReq)
+	}
+
+	invalid := []*ImageEditRequest{
+		{Image: testPNGBytes(t, 64, 64)},
+		{Prompt: "x", Image: []byte("not an image")},
+		{Prompt: "x", Image: testPNGBytes(t, 64, 64), Mask: testPNGBytes(t, 32, 32)},
+	}
+	for _, req := range invalid {
+		if _, err := NewMonicaImageEditRequest(context.Background(), cfg, req, false); err == nil {
+			t.Errorf("无效的编辑请求未返回错误: prompt=%q", req.Prompt)
+		}
+	}
+}
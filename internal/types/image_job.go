@@ -0,0 +1,31 @@
+package types
+
+// 图像生成任务的状态
+const (
+	ImageJobInProgress = "in_progress"
+	ImageJobSucceeded  = "succeeded"
+	ImageJobFailed     = "failed"
+)
+
+// ImageJob 异步图像生成任务，ID 由 Monica 的 image_tools_id 生成
+type ImageJob struct {
+	ID           string                   `json:"id"`
+	Object       string                   `json:"object"` // 固定为 image.generation.job
+	Status       string                   `json:"status"` // in_progress, succeeded, failed
+	Model        string                   `json:"model"`
+	CreatedAt    int64                    `json:"created_at"`
+	ExpectedTime int                      `json:"expected_time"` // 上游预估的生成时间（秒）
+	Progress     int                      `json:"progress"`      // 按已用时间估算的进度（0-100）
+	Result       *ImageGenerationResponse `json:"result,omitempty"`
+	Error        *ImageJobError           `json:"error,omitempty"`
+}
+
+// ImageJobError 任务失败的原因
+type ImageJobError struct {
+	Message string `json:"message"`
+}
+
+// Done 任务是否已结束
+func (j *ImageJob) Done() bool {
+	return j.Status == ImageJobSucceeded || j.Status == ImageJobFailed
+}
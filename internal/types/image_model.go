@@ -0,0 +1,188 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+
+	"github.com/google/uuid"
+)
+
+// commonAspectRatios 支持任意宽高比的模型可用的宽高比
+var commonAspectRatios = []string{"1:1", "16:9", "9:16", "4:3", "3:4", "3:2", "2:3"}
+
+// builtinImageModels 内置的图像生成模型表，键为对外的模型名，可通过 image_models.models 覆盖或扩展
+var builtinImageModels = map[string]config.ImageModelConfig{
+	"dall-e-3": {
+		ModelType:    "sdxl",
+		AspectRatios: []string{"1:1", "16:9", "9:16"},
+		MaxImages:    4,
+		Qualities:    []string{"standard", "hd"},
+		Styles:       []string{"vivid", "natural"},
+	},
+	"gpt-image-1": {
+		ModelType:    "gpt_image_1",
+		AspectRatios: []string{"1:1", "3:2", "2:3"},
+		MaxImages:    4,
+		Qualities:    []string{"auto", "low", "medium", "high"},
+	},
+	"flux": {
+		ModelType:    "flux",
+		AspectRatios: commonAspectRatios,
+		MaxImages:    4,
+	},
+	"sdxl": {
+		ModelType:    "sdxl",
+		AspectRatios: commonAspectRatios,
+		MaxImages:    4,
+	},
+}
+
+const (
+	defaultMaxImages = 4
+	// maxAspectRatioDeviation 请求尺寸与最接近的支持宽高比之间允许的最大比值，超出时返回错误而不是生成变形的图片
+	maxAspectRatioDeviation = 1.5
+)
+
+// lookupImageModel 查找图像生成模型，配置优先于内置表
+func lookupImageModel(cfg *config.Config, name string) (config.ImageModelConfig, bool) {
+	if model, ok := cfg.ImageModels.Models[name]; ok {
+		return model, true
+	}
+	model, ok := builtinImageModels[name]
+	return model, ok
+}
+
+// GetImageModels 获取可用的图像生成模型列表
+func GetImageModels(cfg *config.Config) []string {
+	models := make([]string, 0, len(builtinImageModels)+len(cfg.ImageModels.Models))
+	for name := range builtinImageModels {
+		models = append(models, name)
+	}
+	for name := range cfg.ImageModels.Models {
+		if _, ok := builtinImageModels[name]; !ok {
+			models = append(models, name)
+		}
+	}
+	slices.Sort(models)
+	return models
+}
+
+// NewMonicaImageRequest 校验图像生成参数并转换为 Monica 文生图请求，不支持的参数组合返回错误
+func NewMonicaImageRequest(cfg *config.Config, req *ImageGenerationRequest) (*MonicaImageRequest, error) {
+	monicaReq, model, err := newImageToolsRequest(cfg, &req.Model, &req.N, req.Size, ImageTaskTextToImage)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateImageOption(req.Model, "quality", req.Quality, model.Qualities); err != nil {
+		return nil, err
+	}
+	if err := validateImageOption(req.Model, "style", req.Style, model.Styles); err != nil {
+		return nil, err
+	}
+	monicaReq.Prompt = req.Prompt
+	monicaReq.Quality = req.Quality
+	monicaReq.Style = req.Style
+	return monicaReq, nil
+}
+
+// newImageToolsRequest 校验模型、数量与尺寸，构建各任务类型共用的请求字段，model 与 n 未指定时填入默认值
+func newImageToolsRequest(cfg *config.Config, name *string, n *int, size, taskType string) (*MonicaImageRequest, config.ImageModelConfig, error) {
+	if *name == "" {
+		*name = cfg.ImageModels.Default
+	}
+	model, ok := lookupImageModel(cfg, *name)
+	if !ok {
+		return nil, model, errors.NewInvalidInputError(fmt.Sprintf("不支持的图像模型: %s，可用模型: %s", *name, strings.Join(GetImageModels(cfg), ", ")), nil)
+	}
+
+	if *n <= 0 {
+		*n = 1
+	}
+	maxImages := model.MaxImages
+	if maxImages == 0 {
+		maxImages = defaultMaxImages
+	}
+	if *n > maxImages {
+		return nil, model, errors.NewInvalidInputError(fmt.Sprintf("模型 %s 单次最多生成 %d 张图片", *name, maxImages), nil)
+	}
+
+	aspectRatio, err := imageAspectRatio(*name, size, model.AspectRatios)
+	if err != nil {
+		return nil, model, err
+	}
+	return &MonicaImageRequest{
+		TaskUID:     uuid.New().String(),
+		ImageCount:  *n,
+		ModelType:   model.ModelType,
+		AspectRatio: aspectRatio,
+		TaskType:    taskType,
+	}, model, nil
+}
+
+// imageAspectRatio 将 size 转换为模型支持的宽高比
+// size 可以是 WxH 像素尺寸（取最接近的宽高比）、W:H 宽高比（必须受支持）或 auto（使用模型的默认宽高比）
+func imageAspectRatio(model, size string, allowed []string) (string, error) {
+	if size == "" || size == "auto" {
+		return allowed[0], nil
+	}
+	if strings.Contains(size, ":") {
+		if slices.Contains(allowed, size) {
+			return size, nil
+		}
+		return "", errors.NewInvalidInputError(fmt.Sprintf("模型 %s 不支持宽高比 %s，可用宽高比: %s", model, size, strings.Join(allowed, ", ")), nil)
+	}
+
+	target, ok := parseRatio(size, "x")
+	if !ok {
+		return "", errors.NewInvalidInputError(fmt.Sprintf("无效的尺寸: %s，应为 WxH 或 W:H", size), nil)
+	}
+	best, bestDeviation := "", math.Inf(1)
+	for _, ratio := range allowed {
+		r, ok := parseRatio(ratio, ":")
+		if !ok {
+			continue
+		}
+		// 按比值的对数比较，横竖方向的偏差对称
+		if deviation := math.Abs(math.Log(target / r)); deviation < bestDeviation {
+			best, bestDeviation = ratio, deviation
+		}
+	}
+	if best == "" || math.Exp(bestDeviation) > maxAspectRatioDeviation {
+		return "", errors.NewInvalidInputError(fmt.Sprintf("尺寸 %s 与模型 %s 支持的宽高比相差过大，可用宽高比: %s", size, model, strings.Join(allowed, ", ")), nil)
+	}
+	return best, nil
+}
+
+// parseRatio 解析 WxH 或 W:H，返回宽高比
+func parseRatio(s, sep string) (float64, bool) {
+	w, h, ok := strings.Cut(s, sep)
+	if !ok {
+		return 0, false
+	}
+	width, err := strconv.Atoi(strings.TrimSpace(w))
+	if err != nil || width <= 0 {
+		return 0, false
+	}
+	height, err := strconv.Atoi(strings.TrimSpace(h))
+	if err != nil || height <= 0 {
+		return 0, false
+	}
+	return float64(width) / float64(height), true
+}
+
+// validateImageOption 校验 quality、style 等取值有限的参数，未指定时不校验
+func validateImageOption(model, name, value string, allowed []string) error {
+	if value == "" || slices.Contains(allowed, value) {
+		return nil
+	}
+	if len(allowed) == 0 {
+		return errors.NewInvalidInputError(fmt.Sprintf("模型 %s 不支持 %s 参数", model, name), nil)
+	}
+	return errors.NewInvalidInputError(fmt.Sprintf("模型 %s 不支持 %s=%s，可用取值: %s", model, name, value, strings.Join(allowed, ", ")), nil)
+}
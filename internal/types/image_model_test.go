@@ -0,0 +1,84 @@
+package types
+
+import (
+	"testing"
+
+	"monica-proxy/internal/config"
+)
+
+func imageModelConfig() *config.Config {
+	return &config.Config{ImageModels: config.ImageModelsConfig{
+		Default: "dall-e-3",
+		Models: map[string]config.ImageModelConfig{
+			"custom": {ModelType: "custom_type", AspectRatios: []string{"4:3", "1:1"}, MaxImages: 2},
+		},
+	}}
+}
+
+// TestImageAspectRatio 测试任意尺寸映射到最接近的宽高比
+func TestImageAspectRatio(t *testing.T) {
+	allowed := []string{"1:1", "16:9", "9:16", "4:3", "3:4"}
+	tests := []struct {
+		size    string
+		want    string
+		wantErr bool
+	}{
+		{"", "1:1", false},
+		{"auto", "1:1", false},
+		{"1024x1024", "1:1", false},
+		{"1792x1024", "16:9", false},
+		{"1024x1536", "3:4", false},
+		{"800x600", "4:3", false},
+		{"16:9", "16:9", false},
+		{"3:2", "", true},
+		{"4096x512", "", true},
+		{"large", "", true},
+		{"0x100", "", true},
+	}
+	for _, tt := range tests {
+		got, err := imageAspectRatio("test", tt.size, allowed)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("imageAspectRatio(%q) = %q, %v; want %q, error=%v", tt.size, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestNewMonicaImageRequest 测试模型映射与参数校验
+func TestNewMonicaImageRequest(t *testing.T) {
+	cfg := imageModelConfig()
+
+	req := &ImageGenerationRequest{Prompt: "cat", Size: "1024x1792", Quality: "hd", Style: "natural"}
+	monicaReq, err := NewMonicaImageRequest(cfg, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Model != "dall-e-3" || monicaReq.ModelType != "sdxl" || monicaReq.AspectRatio != "9:16" || monicaReq.ImageCount != 1 {
+		t.Errorf("默认模型的转换结果错误: %+v", monicaReq)
+	}
+
+	monicaReq, err = NewMonicaImageRequest(cfg, &ImageGenerationRequest{Model: "custom", Prompt: "cat", Size: "1280x960", N: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if monicaReq.ModelType != "custom_type" || monicaReq.AspectRatio != "4:3" {
+		t.Errorf("配置模型的转换结果错误: %+v", monicaReq)
+	}
+
+	invalid := []*ImageGenerationRequest{
+		{Model: "unknown", Prompt: "cat"},
+		{Model: "custom", Prompt: "cat", N: 3},
+		{Model: "dall-e-3", Prompt: "cat", Size: "1024x256"},
+		{Model: "dall-e-3", Prompt: "cat", Quality: "ultra"},
+		{Model: "flux", Prompt: "cat", Style: "vivid"},
+	}
+	for _, req := range invalid {
+		if _, err := NewMonicaImageRequest(cfg, req); err == nil {
+			t.Errorf("不支持的参数组合未返回错误: %+v", req)
+		}
+	}
+
+	models := GetImageModels(cfg)
+	if len(models) != len(builtinImageModels)+1 {
+		t.Errorf("图像模型列表错误: %v", models)
+	}
+}
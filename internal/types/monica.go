@@ -3,13 +3,14 @@ package types
 import (
 	"context"
 	"fmt"
+	"monica-proxy/internal/account"
 	"monica-proxy/internal/config"
 	"monica-proxy/internal/logger"
-	"sync/atomic"
+	"monica-proxy/internal/structured"
+	"monica-proxy/internal/toolcall"
+	"monica-proxy/internal/utils"
 	"time"
 
-	lop "github.com/samber/lo/parallel"
-
 	"github.com/google/uuid"
 	"github.com/sashabaranov/go-openai"
 	"go.uber.org/zap"
@@ -21,9 +22,18 @@ const (
 	FileUploadURL = "https://api.monica.im/api/files/batch_create_llm_file"
 	FileGetURL    = "https://api.monica.im/api/files/batch_get_file"
 
-	// 图片生成相关 API
-	ImageGenerateURL = "https://api.monica.im/api/image_tools/text_to_image"
-	ImageResultURL   = "https://api.monica.im/api/image_tools/loop_result"
+	// 图片生成相关 API，各任务类型的提交地址为 ImageToolsURL + task_type
+	ImageToolsURL    = "https://api.monica.im/api/image_tools/"
+	ImageGenerateURL = ImageToolsURL + ImageTaskTextToImage
+	ImageResultURL   = ImageToolsURL + "loop_result"
+)
+
+// Monica image_tools 的任务类型
+const (
+	ImageTaskTextToImage  = "text_to_image"   // 文生图
+	ImageTaskImageToImage = "image_to_image"  // 按提示词修改整张图片
+	ImageTaskInpainting   = "inpainting"      // 按提示词重绘蒙版中透明的区域
+	ImageTaskVariation    = "image_variation" // 生成相似的图片
 )
 
 // 图片相关常量
@@ -69,17 +79,107 @@ type MonicaRequest struct {
 	Language string    `json:"language"`
 	TaskType string    `json:"task_type"`
 	ToolData ToolData  `json:"tool_data"`
+
+	session *sessionPlan
+	pinned  int
+}
+
+// PinnedItems 开头需要始终保留的条目数：欢迎消息与承载系统提示词的消息
+func (r *MonicaRequest) PinnedItems() int {
+	return r.pinned
+}
+
+// SaveSession 上游回复完整结束后调用，记录会话供下一轮请求复用
+// itemID 为上游返回的回复条目 ID，为空时使用请求中预生成的回复 ID
+func (r *MonicaRequest) SaveSession(itemID, reply string) {
+	if itemID == "" {
+		itemID = r.Data.PreGeneratedReplyID
+	}
+	r.session.save(r.Data.ConversationID, itemID, reply)
+}
+
+// Portable 请求是否可以换用其他账号重试：没有接续已有会话，也没有引用只属于当前账号的附件
+func (r *MonicaRequest) Portable() bool {
+	return r.session.portable() && !hasFiles(r.Data.Items)
+}
+
+// SetAccount 换用其他账号重试后更新会话所属的账号
+func (r *MonicaRequest) SetAccount(name string) {
+	r.session.setAccount(name)
+}
+
+// hasFiles 条目中是否有已上传的附件
+func hasFiles(items []Item) bool {
+	for _, item := range items {
+		if len(item.Data.FileInfos) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTextItem 创建纯文本会话条目，ParentItemID 由调用方设置
+func NewTextItem(conversationID, itemType, content string) Item {
+	return Item{
+		ConversationID: conversationID,
+		ItemID:         fmt.Sprintf("msg:%s", uuid.New().String()),
+		ItemType:       itemType,
+		Data:           ItemContent{Type: "text", Content: content, IsIncognito: true},
+	}
+}
+
+// NewTextRequest 创建只有一个问题的无痕请求，用于摘要等代理内部的辅助调用
+func NewTextRequest(model, question string) *MonicaRequest {
+	conversationID, items, preItemID := startConversation(nil, 1)
+	item := NewTextItem(conversationID, "question", question)
+	item.ParentItemID = preItemID
+	return &MonicaRequest{
+		TaskUID: fmt.Sprintf("task:%s", uuid.New().String()),
+		BotUID:  modelToBot(model),
+		Data: DataField{
+			ConversationID:  conversationID,
+			Items:           append(items, item),
+			PreParentItemID: item.ItemID,
+			TriggerBy:       "auto",
+			IsIncognito:     true,
+			UseModel:        model,
+		},
+		Language: "auto",
+		TaskType: "chat",
+	}
+}
+
+// startConversation 复用会话时返回原会话 ID 与接续的回复条目，否则新建会话并放入欢迎消息
+func startConversation(session *sessionPlan, messages int) (string, []Item, string) {
+	if session != nil && session.reply != nil {
+		return session.reply.ConversationID, make([]Item, 0, messages), session.reply.ItemID
+	}
+
+	// 生成会话ID
+	conversationID := fmt.Sprintf("conv:%s", uuid.New().String())
+
+	// 设置默认欢迎消息头，不加上就有几率去掉问题最后的十几个token，不清楚是不是bug
+	defaultItem := Item{
+		ItemID:         fmt.Sprintf("msg:%s", uuid.New().String()),
+		ConversationID: conversationID,
+		ItemType:       "reply",
+		Data:           ItemContent{Type: "text", Content: "__RENDER_BOT_WELCOME_MSG__"},
+	}
+	items := make([]Item, 1, messages+1)
+	items[0] = defaultItem
+	return conversationID, items, defaultItem.ItemID
 }
 
 // DataField 在 Monica 的 body 中
 type DataField struct {
-	ConversationID  string `json:"conversation_id"`
-	PreParentItemID string `json:"pre_parent_item_id"`
-	Items           []Item `json:"items"`
-	TriggerBy       string `json:"trigger_by"`
-	UseModel        string `json:"use_model,omitempty"`
-	IsIncognito     bool   `json:"is_incognito"`
-	UseNewMemory    bool   `json:"use_new_memory"`
+	ConversationID      string `json:"conversation_id"`
+	PreGeneratedReplyID string `json:"pre_generated_reply_id,omitempty"`
+	PreParentItemID     string `json:"pre_parent_item_id"`
+	Items               []Item `json:"items"`
+	TriggerBy           string `json:"trigger_by"`
+	UseModel            string `json:"use_model,omitempty"`
+	IsIncognito         bool   `json:"is_incognito"`
+	UseNewMemory        bool   `json:"use_new_memory"`
 }
 
 type Item struct {
@@ -127,12 +227,20 @@ type PreSignResponse struct {
 
 // MonicaImageRequest 文生图请求结构
 type MonicaImageRequest struct {
-	TaskUID     string `json:"task_uid"`     // 任务ID
-	ImageCount  int    `json:"image_count"`  // 生成图片数量
-	Prompt      string `json:"prompt"`       // 提示词
-	ModelType   string `json:"model_type"`   // 模型类型，目前只支持 sdxl
-	AspectRatio string `json:"aspect_ratio"` // 宽高比，如 1:1, 16:9, 9:16
-	TaskType    string `json:"task_type"`    // 任务类型，固定为 text_to_image
+	TaskUID     string `json:"task_uid"`          // 任务ID
+	ImageCount  int    `json:"image_count"`       // 生成图片数量
+	Prompt      string `json:"prompt"`            // 提示词
+	ModelType   string `json:"model_type"`        // 模型类型，由图像模型表映射
+	AspectRatio string `json:"aspect_ratio"`      // 宽高比，如 1:1, 16:9, 9:16
+	TaskType    string `json:"task_type"`         // 任务类型，见 ImageTask* 常量
+	Quality     string `json:"quality,omitempty"` // 图片质量，取值由模型决定
+	Style       string `json:"style,omitempty"`   // 图片风格，取值由模型决定
+
+	// 图生图任务的原图与蒙版，均为上传到 Monica 的文件
+	ImageFileUID string `json:"image_file_uid,omitempty"`
+	ImageURL     string `json:"image_url,omitempty"`
+	MaskFileUID  string `json:"mask_file_uid,omitempty"`
+	MaskURL      string `json:"mask_url,omitempty"`
 }
 
 // FileInfo 文件信息
@@ -178,26 +286,29 @@ type FileUploadResponse struct {
 // FileBatchGetResponse 获取文件llm处理是否完成
 type FileBatchGetResponse struct {
 	Data struct {
-		Items []struct {
-			FileName     string `json:"file_name"`
-			FileType     string `json:"file_type"`
-			FileSize     int    `json:"file_size"`
-			ObjectUrl    string `json:"object_url"`
-			Url          string `json:"url"`
-			FileMetaInfo struct {
-			} `json:"file_meta_info"`
-			DriveFileUid  string `json:"drive_file_uid"`
-			FileUid       string `json:"file_uid"`
-			IndexState    int    `json:"index_state"`
-			IndexDesc     string `json:"index_desc"`
-			ErrorMessage  string `json:"error_message"`
-			FileTokens    int64  `json:"file_tokens"`
-			FileChunks    int64  `json:"file_chunks"`
-			IndexProgress int    `json:"index_progress"`
-		} `json:"items"`
+		Items []FileBatchGetItem `json:"items"`
 	} `json:"data"`
 }
 
+// FileBatchGetItem 单个文件的解析状态
+type FileBatchGetItem struct {
+	FileName     string `json:"file_name"`
+	FileType     string `json:"file_type"`
+	FileSize     int    `json:"file_size"`
+	ObjectUrl    string `json:"object_url"`
+	Url          string `json:"url"`
+	FileMetaInfo struct {
+	} `json:"file_meta_info"`
+	DriveFileUid  string `json:"drive_file_uid"`
+	FileUid       string `json:"file_uid"`
+	IndexState    int    `json:"index_state"`
+	IndexDesc     string `json:"index_desc"`
+	ErrorMessage  string `json:"error_message"`
+	FileTokens    int64  `json:"file_tokens"`
+	FileChunks    int64  `json:"file_chunks"`
+	IndexProgress int    `json:"index_progress"`
+}
+
 // OpenAIModel represents a model in the OpenAI API format
 type OpenAIModel struct {
 	ID      string `json:"id"`
@@ -277,6 +388,33 @@ type CustomBotRequest struct {
 	TaskType       string        `json:"task_type"`
 	BotData        BotData       `json:"bot_data"`
 	AIRespLanguage string        `json:"ai_resp_language,omitempty"`
+
+	session *sessionPlan
+	pinned  int
+}
+
+// PinnedItems 开头需要始终保留的条目数，Custom Bot 的系统提示词在 bot_data 中，只需保留欢迎消息
+func (r *CustomBotRequest) PinnedItems() int {
+	return r.pinned
+}
+
+// SaveSession 上游回复完整结束后调用，记录会话供下一轮请求复用
+// itemID 为上游返回的回复条目 ID，为空时使用请求中预生成的回复 ID
+func (r *CustomBotRequest) SaveSession(itemID, reply string) {
+	if itemID == "" {
+		itemID = r.Data.PreGeneratedReplyID
+	}
+	r.session.save(r.Data.ConversationID, itemID, reply)
+}
+
+// Portable 请求是否可以换用其他账号重试：没有接续已有会话，也没有引用只属于当前账号的附件
+func (r *CustomBotRequest) Portable() bool {
+	return r.session.portable() && !hasFiles(r.Data.Items)
+}
+
+// SetAccount 换用其他账号重试后更新会话所属的账号
+func (r *CustomBotRequest) SetAccount(name string) {
+	r.session.setAccount(name)
 }
 
 // CustomBotData custom bot的数据字段
@@ -335,42 +473,44 @@ func GetSupportedModels() []string {
 }
 
 // ChatGPTToMonica 将 ChatGPTRequest 转换为 MonicaRequest
-func ChatGPTToMonica(cfg *config.Config, chatReq openai.ChatCompletionRequest) (*MonicaRequest, error) {
+func ChatGPTToMonica(ctx context.Context, cfg *config.Config, chatReq openai.ChatCompletionRequest) (*MonicaRequest, error) {
 	if len(chatReq.Messages) == 0 {
 		return nil, fmt.Errorf("empty messages")
 	}
 
-	// 生成会话ID
-	conversationID := fmt.Sprintf("conv:%s", uuid.New().String())
+	// 同一 user 固定使用同一个账号；引用的已上传文件只属于上传它的账号，先于会话确定请求使用的账号
+	account.SetStickyKey(ctx, chatReq.User)
+	preferFileAccounts(ctx, chatReq.Messages)
+	// 会话模式下复用已有会话时只转换新增的消息
+	session := planSession(ctx, cfg, &chatReq)
+	chatReq.Messages = session.newMessages(chatReq.Messages)
+	// 会话模式需要 Monica 保留会话记录，不能使用无痕模式
+	incognito := session == nil
+
+	// Monica 普通对话不支持系统提示词，按配置的策略改写 system / developer 消息
+	systemMessages := pinnedSystemMessages(&cfg.SystemPrompt, chatReq.Messages)
+	chatReq.Messages = ApplySystemPrompt(&cfg.SystemPrompt, chatReq.Messages)
+	// 工具调用相关消息改写为纯文本，并注入工具说明
+	chatReq.Messages = toolcall.PrepareMessages(&chatReq)
+	// 注入 response_format 输出格式约束
+	if prompt := structured.Prompt(&chatReq); prompt != "" {
+		utils.InjectPrompt(chatReq.Messages, prompt)
+	}
 
-	// 转换消息
+	conversationID, items, preItemID := startConversation(session, len(chatReq.Messages))
+	pinned := len(items) + systemMessages
 
-	// 设置默认欢迎消息头，不加上就有几率去掉问题最后的十几个token，不清楚是不是bug
-	defaultItem := Item{
-		ItemID:         fmt.Sprintf("msg:%s", uuid.New().String()),
-		ConversationID: conversationID,
-		ItemType:       "reply",
-		Data:           ItemContent{Type: "text", Content: "__RENDER_BOT_WELCOME_MSG__"},
+	// 先统一上传所有消息中的附件
+	uploads, err := uploadMessageAttachments(ctx, cfg, chatReq.Messages)
+	if err != nil {
+		return nil, err
 	}
-	var items = make([]Item, 1, len(chatReq.Messages))
-	items[0] = defaultItem
-	preItemID := defaultItem.ItemID
 
-	for _, msg := range chatReq.Messages {
-		if msg.Role == "system" {
-			// monica不支持设置prompt，所以直接跳过
-			continue
-		}
+	for i, msg := range chatReq.Messages {
 		var msgContext string
-		var imgUrl []*openai.ChatMessageImageURL
-		if len(msg.MultiContent) > 0 { // 说明应该是多内容，可能是图片内容
-			for _, content := range msg.MultiContent {
-				switch content.Type {
-				case "text":
-					msgContext = content.Text
-				case "image_url":
-					imgUrl = append(imgUrl, content.ImageURL)
-				}
+		for _, content := range msg.MultiContent {
+			if content.Type == openai.ChatMessagePartTypeText {
+				msgContext = joinText(msgContext, content.Text)
 			}
 		}
 		itemID := fmt.Sprintf("msg:%s", uuid.New().String())
@@ -380,70 +520,23 @@ func ChatGPTToMonica(cfg *config.Config, chatReq openai.ChatCompletionRequest) (
 		}
 
 		var content ItemContent
-		if len(imgUrl) > 0 {
-			// 为图片上传创建带超时的上下文
-			uploadCtx, cancel := context.WithTimeout(context.Background(), ImageUploadTimeout)
-			defer cancel()
-
-			// 统计上传成功和失败数量
-			var successCount, failureCount int64
-
-			// 并发上传图片并收集结果
-			uploadResults := lop.Map(imgUrl, func(item *openai.ChatMessageImageURL, _ int) *FileInfo {
-				f, err := UploadBase64Image(uploadCtx, cfg, item.URL)
-				if err != nil {
-					atomic.AddInt64(&failureCount, 1)
-					logger.Error("上传图片失败",
-						zap.Error(err),
-						zap.String("image_url", item.URL),
-						zap.Int("total_images", len(imgUrl)),
-					)
-					return nil // 返回 nil 表示失败
-				}
-
-				if f == nil {
-					atomic.AddInt64(&failureCount, 1)
-					logger.Warn("图片上传返回空结果", zap.String("image_url", item.URL))
-					return nil
-				}
-
-				atomic.AddInt64(&successCount, 1)
-				return f
-			})
-
-			// 过滤掉失败的上传，只保留成功的
-			fileIfoList := make([]FileInfo, 0, len(uploadResults))
-			for _, result := range uploadResults {
-				if result != nil {
-					fileIfoList = append(fileIfoList, *result)
-				}
-			}
-
-			// 记录上传统计信息
-			if failureCount > 0 {
-				logger.Warn("图片上传完成",
-					zap.Int64("success_count", successCount),
-					zap.Int64("failure_count", failureCount),
-					zap.Int("total_images", len(imgUrl)),
-				)
-			} else {
-				logger.Info("所有图片上传成功",
-					zap.Int64("success_count", successCount),
-					zap.Int("total_images", len(imgUrl)),
-				)
-			}
-
+		if upload := uploads[i]; upload != nil {
 			content = ItemContent{
 				Type:        "file_with_text",
-				Content:     msgContext,
-				FileInfos:   fileIfoList,
-				IsIncognito: true,
+				Content:     upload.text(msgContext),
+				FileInfos:   upload.files,
+				IsIncognito: incognito,
 			}
 		} else {
+			// 多段内容的消息只有文本部分，合并的系统提示词、工具说明与格式约束也写在其中
+			text := msg.Content
+			if len(msg.MultiContent) > 0 {
+				text = msgContext
+			}
 			content = ItemContent{
 				Type:        "text",
-				Content:     msg.Content,
-				IsIncognito: true,
+				Content:     text,
+				IsIncognito: incognito,
 			}
 		}
 
@@ -458,21 +551,29 @@ func ChatGPTToMonica(cfg *config.Config, chatReq openai.ChatCompletionRequest) (
 		preItemID = itemID
 	}
 
+	// 联网搜索只需在本轮提问上开启
+	if webSearchEnabled(ctx, cfg, chatReq.Model) {
+		items[len(items)-1].Data.ManualWebSearchEnabled = true
+	}
+
 	// 构建请求
 	mReq := &MonicaRequest{
 		TaskUID: fmt.Sprintf("task:%s", uuid.New().String()),
 		BotUID:  modelToBot(chatReq.Model),
 		Data: DataField{
-			ConversationID:  conversationID,
-			Items:           items,
-			PreParentItemID: preItemID,
-			TriggerBy:       "auto",
-			IsIncognito:     true,
-			UseModel:        chatReq.Model, //TODO 好像写啥都没影响
-			UseNewMemory:    false,
+			ConversationID:      conversationID,
+			Items:               items,
+			PreGeneratedReplyID: fmt.Sprintf("msg:%s", uuid.New().String()),
+			PreParentItemID:     preItemID,
+			TriggerBy:           "auto",
+			IsIncognito:         incognito,
+			UseModel:            chatReq.Model, //TODO 好像写啥都没影响
+			UseNewMemory:        false,
 		},
 		Language: "auto",
 		TaskType: "chat",
+		session:  session,
+		pinned:   pinned,
 	}
 
 	// indent, err := json.MarshalIndent(mReq, "", "  ")
@@ -485,48 +586,50 @@ func ChatGPTToMonica(cfg *config.Config, chatReq openai.ChatCompletionRequest) (
 }
 
 // ChatGPTToCustomBot 转换ChatGPT请求到Custom Bot请求
-func ChatGPTToCustomBot(cfg *config.Config, chatReq openai.ChatCompletionRequest, botUID string) (*CustomBotRequest, error) {
+func ChatGPTToCustomBot(ctx context.Context, cfg *config.Config, chatReq openai.ChatCompletionRequest, botUID string) (*CustomBotRequest, error) {
 	if len(chatReq.Messages) == 0 {
 		return nil, fmt.Errorf("empty messages")
 	}
 
+	webSearch := webSearchEnabled(ctx, cfg, chatReq.Model)
+	// 所有 system / developer 消息按顺序拼接作为 bot 的 prompt，每次请求都完整发送
+	systemPrompt := SystemPrompt(chatReq.Messages)
+	// 同一 user 固定使用同一个账号；引用的已上传文件只属于上传它的账号，先于会话确定请求使用的账号
+	account.SetStickyKey(ctx, chatReq.User)
+	preferFileAccounts(ctx, chatReq.Messages)
+	// 会话模式下复用已有会话时只转换新增的消息
+	session := planSession(ctx, cfg, &chatReq)
+	chatReq.Messages = session.newMessages(chatReq.Messages)
+
 	// 修改customBot请求的模型ID
 	chatReq.Model = changeModelToCustomBotModel(chatReq.Model)
 
-	// 生成会话ID
-	conversationID := fmt.Sprintf("conv:%s", uuid.New().String())
+	// 工具调用相关消息改写为纯文本，并注入工具说明
+	chatReq.Messages = toolcall.PrepareMessages(&chatReq)
+	// 注入 response_format 输出格式约束
+	if prompt := structured.Prompt(&chatReq); prompt != "" {
+		utils.InjectPrompt(chatReq.Messages, prompt)
+	}
 
-	// 设置默认欢迎消息
-	defaultItem := Item{
-		ItemID:         fmt.Sprintf("msg:%s", uuid.New().String()),
-		ConversationID: conversationID,
-		ItemType:       "reply",
-		Data:           ItemContent{Type: "text", Content: "__RENDER_BOT_WELCOME_MSG__"},
+	conversationID, items, preItemID := startConversation(session, len(chatReq.Messages))
+	pinned := len(items)
+
+	// 先统一上传所有消息中的附件
+	uploads, err := uploadMessageAttachments(ctx, cfg, chatReq.Messages)
+	if err != nil {
+		return nil, err
 	}
-	var items = make([]Item, 1, len(chatReq.Messages))
-	items[0] = defaultItem
-	preItemID := defaultItem.ItemID
 
-	// 提取system消息作为prompt
-	var systemPrompt string
 	// 转换消息
-	for _, msg := range chatReq.Messages {
-		if msg.Role == "system" {
-			// 将system消息作为prompt
-			systemPrompt = msg.Content
+	for i, msg := range chatReq.Messages {
+		if isSystemMessage(msg) {
 			continue
 		}
 
 		var msgContext string
-		var imgUrl []*openai.ChatMessageImageURL
-		if len(msg.MultiContent) > 0 {
-			for _, content := range msg.MultiContent {
-				switch content.Type {
-				case "text":
-					msgContext = content.Text
-				case "image_url":
-					imgUrl = append(imgUrl, content.ImageURL)
-				}
+		for _, content := range msg.MultiContent {
+			if content.Type == openai.ChatMessagePartTypeText {
+				msgContext = joinText(msgContext, content.Text)
 			}
 		}
 
@@ -537,43 +640,22 @@ func ChatGPTToCustomBot(cfg *config.Config, chatReq openai.ChatCompletionRequest
 		}
 
 		var content ItemContent
-		if len(imgUrl) > 0 {
-			// 处理图片上传
-			uploadCtx, cancel := context.WithTimeout(context.Background(), ImageUploadTimeout)
-			defer cancel()
-
-			var successCount, failureCount int64
-			uploadResults := lop.Map(imgUrl, func(item *openai.ChatMessageImageURL, _ int) *FileInfo {
-				f, err := UploadBase64Image(uploadCtx, cfg, item.URL)
-				if err != nil {
-					atomic.AddInt64(&failureCount, 1)
-					logger.Error("上传图片失败",
-						zap.Error(err),
-						zap.String("image_url", item.URL),
-					)
-					return nil
-				}
-				atomic.AddInt64(&successCount, 1)
-				return f
-			})
-
-			fileIfoList := make([]FileInfo, 0, len(uploadResults))
-			for _, result := range uploadResults {
-				if result != nil {
-					fileIfoList = append(fileIfoList, *result)
-				}
-			}
-
+		if upload := uploads[i]; upload != nil {
 			content = ItemContent{
 				Type:        "file_with_text",
-				Content:     msgContext,
-				FileInfos:   fileIfoList,
+				Content:     upload.text(msgContext),
+				FileInfos:   upload.files,
 				IsIncognito: false,
 			}
 		} else {
+			// 多段内容的消息只有文本部分，合并的系统提示词、工具说明与格式约束也写在其中
+			text := msg.Content
+			if len(msg.MultiContent) > 0 {
+				text = msgContext
+			}
 			content = ItemContent{
 				Type:        "text",
-				Content:     msg.Content,
+				Content:     text,
 				IsIncognito: false,
 			}
 		}
@@ -589,6 +671,11 @@ func ChatGPTToCustomBot(cfg *config.Config, chatReq openai.ChatCompletionRequest
 		preItemID = itemID
 	}
 
+	// 联网搜索只需在本轮提问上开启
+	if webSearch {
+		items[len(items)-1].Data.ManualWebSearchEnabled = true
+	}
+
 	// 生成reply ID
 	preGeneratedReplyID := fmt.Sprintf("msg:%s", uuid.New().String())
 
@@ -630,11 +717,21 @@ func ChatGPTToCustomBot(cfg *config.Config, chatReq openai.ChatCompletionRequest
 			},
 		},
 		AIRespLanguage: "Chinese (Simplified)",
+		session:        session,
+		pinned:         pinned,
 	}
 
 	return customBotReq, nil
 }
 
+// joinText 拼接多段文本内容
+func joinText(text, part string) string {
+	if text == "" {
+		return part
+	}
+	return text + "\n" + part
+}
+
 func changeModelToCustomBotModel(model string) string {
 	switch model {
 	case "grok-4":
@@ -644,4 +741,4 @@ func changeModelToCustomBotModel(model string) string {
 	default:
 		return model
 	}
-}
\ No newline at end of file
+}
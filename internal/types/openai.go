@@ -1,29 +1,34 @@
 package types
 
-import "github.com/sashabaranov/go-openai"
+import (
+	"encoding/json"
+
+	"github.com/sashabaranov/go-openai"
+)
 
 // ImageGenerationRequest represents a request to create an image using DALL-E
 type ImageGenerationRequest struct {
-	Model          string `json:"model"`           // Required. Currently supports: dall-e-3
-	Prompt         string `json:"prompt"`          // Required. A text description of the desired image(s)
-	N              int    `json:"n,omitempty"`     // Optional. The number of images to generate. Default is 1
-	Quality        string `json:"quality,omitempty"` // Optional. The quality of the image that will be generated
+	Model          string `json:"model"`                     // Required. Currently supports: dall-e-3
+	Prompt         string `json:"prompt"`                    // Required. A text description of the desired image(s)
+	N              int    `json:"n,omitempty"`               // Optional. The number of images to generate. Default is 1
+	Quality        string `json:"quality,omitempty"`         // Optional. The quality of the image that will be generated
 	ResponseFormat string `json:"response_format,omitempty"` // Optional. The format in which the generated images are returned
-	Size           string `json:"size,omitempty"`   // Optional. The size of the generated images
-	Style          string `json:"style,omitempty"`  // Optional. The style of the generated images
-	User           string `json:"user,omitempty"`   // Optional. A unique identifier representing your end-user
+	Size           string `json:"size,omitempty"`            // Optional. The size of the generated images
+	Style          string `json:"style,omitempty"`           // Optional. The style of the generated images
+	User           string `json:"user,omitempty"`            // Optional. A unique identifier representing your end-user
+	Async          bool   `json:"async,omitempty"`           // 扩展字段，为 true 时立即返回任务，通过 /v1/images/jobs/{id} 查询结果
 }
 
 // ImageGenerationResponse represents the response from the DALL-E image generation API
 type ImageGenerationResponse struct {
-	Created int64                     `json:"created"`
-	Data    []ImageGenerationData    `json:"data"`
+	Created int64                 `json:"created"`
+	Data    []ImageGenerationData `json:"data"`
 }
 
 // ImageGenerationData represents a single image in the response
 type ImageGenerationData struct {
-	URL        string `json:"url,omitempty"`        // The URL of the generated image
-	B64JSON    string `json:"b64_json,omitempty"`   // Base64 encoded JSON of the generated image
+	URL           string `json:"url,omitempty"`            // The URL of the generated image
+	B64JSON       string `json:"b64_json,omitempty"`       // Base64 encoded JSON of the generated image
 	RevisedPrompt string `json:"revised_prompt,omitempty"` // The prompt that was used to generate the image
 }
 
@@ -41,7 +46,118 @@ type ChatCompletionStreamResponse struct {
 
 type ChatCompletionStreamChoice struct {
 	Index        int                                        `json:"index"`
-	Delta        openai.ChatCompletionStreamChoiceDelta     `json:"delta"`
+	Delta        ChatCompletionStreamChoiceDelta            `json:"delta"`
 	Logprobs     *openai.ChatCompletionStreamChoiceLogprobs `json:"logprobs,omitempty"`
 	FinishReason openai.FinishReason                        `json:"finish_reason"`
-}
\ No newline at end of file
+}
+
+// ChatCompletionStreamChoiceDelta 流式增量，在 OpenAI 字段之外携带联网搜索的引用标注
+type ChatCompletionStreamChoiceDelta struct {
+	openai.ChatCompletionStreamChoiceDelta
+	Annotations []ChatCompletionAnnotation `json:"annotations,omitempty"`
+}
+
+// ChatCompletionResponse 非流式 ChatCompletion 响应，与 OpenAI 格式一致，消息可携带引用标注
+type ChatCompletionResponse struct {
+	ID                string                 `json:"id"`
+	Object            string                 `json:"object"`
+	Created           int64                  `json:"created"`
+	Model             string                 `json:"model"`
+	Choices           []ChatCompletionChoice `json:"choices"`
+	Usage             openai.Usage           `json:"usage"`
+	SystemFingerprint string                 `json:"system_fingerprint"`
+}
+
+type ChatCompletionChoice struct {
+	Index        int                   `json:"index"`
+	Message      ChatCompletionMessage `json:"message"`
+	FinishReason openai.FinishReason   `json:"finish_reason"`
+}
+
+// ChatCompletionMessage 回复消息，Annotations 为联网搜索的引用来源
+type ChatCompletionMessage struct {
+	openai.ChatCompletionMessage
+	Annotations []ChatCompletionAnnotation `json:"annotations,omitempty"`
+}
+
+// MarshalJSON openai.ChatCompletionMessage 自带 MarshalJSON，嵌入后会忽略 Annotations，这里补写到对象末尾
+func (m ChatCompletionMessage) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(m.ChatCompletionMessage)
+	if err != nil || len(m.Annotations) == 0 {
+		return data, err
+	}
+	annotations, err := json.Marshal(m.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data[:len(data)-1], `,"annotations":`...)
+	data = append(data, annotations...)
+	return append(data, '}'), nil
+}
+
+// ChatCompletionRequest /v1/chat/completions 请求，在 OpenAI 请求之外携带本代理的扩展字段
+type ChatCompletionRequest struct {
+	openai.ChatCompletionRequest
+
+	// ReasoningMode 思考过程输出方式：reasoning_content, tags, hidden，为空时使用配置
+	ReasoningMode string `json:"reasoning_mode,omitempty"`
+	// WebSearch 是否开启联网搜索，为空时看模型名的 :online 后缀与配置；请求带 web_search_options 时视为开启
+	WebSearch *bool `json:"web_search,omitempty"`
+}
+
+// UnmarshalJSON 解析 OpenAI 请求与扩展字段
+// go-openai 不支持 {"type":"file","file":{...}} 内容段，这里重新扫描消息内容，将其还原为 NewFilePart
+func (r *ChatCompletionRequest) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.ChatCompletionRequest); err != nil {
+		return err
+	}
+
+	var ext struct {
+		ReasoningMode    string          `json:"reasoning_mode"`
+		WebSearch        *bool           `json:"web_search"`
+		WebSearchOptions json.RawMessage `json:"web_search_options"`
+		Messages         []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &ext); err != nil {
+		return err
+	}
+	r.ReasoningMode = ext.ReasoningMode
+	r.WebSearch = ext.WebSearch
+	if r.WebSearch == nil && len(ext.WebSearchOptions) > 0 && string(ext.WebSearchOptions) != "null" {
+		enabled := true
+		r.WebSearch = &enabled
+	}
+
+	for i, msg := range ext.Messages {
+		if i >= len(r.Messages) || len(msg.Content) == 0 || msg.Content[0] != '[' {
+			continue
+		}
+		var parts []chatFilePart
+		if err := json.Unmarshal(msg.Content, &parts); err != nil {
+			continue
+		}
+		for j, part := range parts {
+			if part.Type != string(ChatMessagePartTypeFile) || part.File == nil || j >= len(r.Messages[i].MultiContent) {
+				continue
+			}
+			if part.File.FileData == "" && part.File.FileID != "" {
+				r.Messages[i].MultiContent[j] = NewFileIDPart(part.File.FileID, part.File.Filename)
+				continue
+			}
+			r.Messages[i].MultiContent[j] = NewFilePart(part.File.FileData, part.File.Filename)
+		}
+	}
+	return nil
+}
+
+// chatFilePart OpenAI file 内容段
+type chatFilePart struct {
+	Type string `json:"type"`
+	File *struct {
+		FileData string `json:"file_data,omitempty"`
+		FileID   string `json:"file_id,omitempty"`
+		Filename string `json:"filename,omitempty"`
+	} `json:"file,omitempty"`
+}
@@ -0,0 +1,413 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ResponsesRequest OpenAI Responses API 请求
+type ResponsesRequest struct {
+	Model              string             `json:"model"`
+	Input              ResponsesInput     `json:"input"`
+	Instructions       string             `json:"instructions,omitempty"`
+	PreviousResponseID string             `json:"previous_response_id,omitempty"`
+	Stream             bool               `json:"stream,omitempty"`
+	Store              *bool              `json:"store,omitempty"`
+	MaxOutputTokens    int                `json:"max_output_tokens,omitempty"`
+	Temperature        *float32           `json:"temperature,omitempty"`
+	TopP               *float32           `json:"top_p,omitempty"`
+	User               string             `json:"user,omitempty"`
+	Metadata           map[string]string  `json:"metadata,omitempty"`
+	Reasoning          *ResponseReasoning `json:"reasoning,omitempty"`
+	Tools              []ResponseTool     `json:"tools,omitempty"`
+	ToolChoice         any                `json:"tool_choice,omitempty"`
+	ParallelToolCalls  *bool              `json:"parallel_tool_calls,omitempty"`
+	Text               *ResponseText      `json:"text,omitempty"`
+}
+
+// ResponseText 文本输出配置
+type ResponseText struct {
+	Format *ResponseTextFormat `json:"format,omitempty"`
+}
+
+// ResponseTextFormat 文本输出格式：text / json_object / json_schema
+type ResponseTextFormat struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// ChatGPTResponseFormat 转换为 ChatCompletion 的 response_format，text 格式返回 nil
+func (t *ResponseText) ChatGPTResponseFormat() *openai.ChatCompletionResponseFormat {
+	if t == nil || t.Format == nil {
+		return nil
+	}
+	switch t.Format.Type {
+	case "json_object":
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	case "json_schema":
+		return &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:        t.Format.Name,
+				Description: t.Format.Description,
+				Schema:      t.Format.Schema,
+				Strict:      t.Format.Strict != nil && *t.Format.Strict,
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+// ResponseTool 工具定义，目前只支持 function 类型
+type ResponseTool struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// ResponseReasoning 推理配置
+type ResponseReasoning struct {
+	Effort  string `json:"effort,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// ResponsesInput 输入项列表，反序列化时兼容纯字符串写法
+type ResponsesInput []ResponseInputItem
+
+// UnmarshalJSON 支持 "input": "text" 与 "input": [{...}] 两种格式
+func (in *ResponsesInput) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*in = ResponsesInput{{
+			Type:    "message",
+			Role:    openai.ChatMessageRoleUser,
+			Content: ResponseInputContent{{Type: "input_text", Text: text}},
+		}}
+		return nil
+	}
+
+	var items []ResponseInputItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("invalid input: %w", err)
+	}
+	*in = items
+	return nil
+}
+
+// ResponseInputItem 输入项，省略 type 时视为 message
+type ResponseInputItem struct {
+	Type    string               `json:"type,omitempty"`
+	ID      string               `json:"id,omitempty"`
+	Role    string               `json:"role,omitempty"`
+	Content ResponseInputContent `json:"content,omitempty"`
+
+	// function_call / function_call_output
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    string `json:"output,omitempty"`
+}
+
+// ResponseInputContent 输入内容列表，反序列化时兼容纯字符串写法
+type ResponseInputContent []ResponseInputPart
+
+// UnmarshalJSON 支持 "content": "text" 与 "content": [{...}] 两种格式
+func (c *ResponseInputContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = ResponseInputContent{{Type: "input_text", Text: text}}
+		return nil
+	}
+
+	var parts []ResponseInputPart
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("invalid content: %w", err)
+	}
+	*c = parts
+	return nil
+}
+
+// ResponseInputPart 输入内容片段：input_text / output_text / input_image / input_file
+type ResponseInputPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
+	FileURL  string `json:"file_url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// Response Responses API 响应对象
+type Response struct {
+	ID                 string               `json:"id"`
+	Object             string               `json:"object"` // 固定为 response
+	CreatedAt          int64                `json:"created_at"`
+	Status             string               `json:"status"` // in_progress, completed, incomplete, failed
+	Model              string               `json:"model"`
+	Output             []ResponseOutputItem `json:"output"`
+	Instructions       string               `json:"instructions,omitempty"`
+	PreviousResponseID string               `json:"previous_response_id,omitempty"`
+	MaxOutputTokens    int                  `json:"max_output_tokens,omitempty"`
+	Temperature        *float32             `json:"temperature,omitempty"`
+	TopP               *float32             `json:"top_p,omitempty"`
+	User               string               `json:"user,omitempty"`
+	Metadata           map[string]string    `json:"metadata"`
+	Store              bool                 `json:"store"`
+	Usage              *ResponseUsage       `json:"usage"`
+	Error              *ResponseError       `json:"error"`
+	IncompleteDetails  *ResponseIncomplete  `json:"incomplete_details"`
+}
+
+// ResponseError 响应失败原因
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ResponseIncomplete 响应未完成原因
+type ResponseIncomplete struct {
+	Reason string `json:"reason"`
+}
+
+// ResponseUsage token 用量
+type ResponseUsage struct {
+	InputTokens        int `json:"input_tokens"`
+	OutputTokens       int `json:"output_tokens"`
+	TotalTokens        int `json:"total_tokens"`
+	InputTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+	OutputTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+}
+
+// ResponseOutputItem 输出项：message / reasoning / function_call
+type ResponseOutputItem struct {
+	Type    string                  `json:"type"`
+	ID      string                  `json:"id"`
+	Status  string                  `json:"status,omitempty"`
+	Role    string                  `json:"role,omitempty"`
+	Content []ResponseOutputContent `json:"content,omitempty"`
+	Summary []ResponseSummaryPart   `json:"summary,omitempty"`
+
+	// function_call
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// MarshalJSON 按输出项类型输出必需字段，空列表也保留
+func (item ResponseOutputItem) MarshalJSON() ([]byte, error) {
+	switch item.Type {
+	case "reasoning":
+		summary := item.Summary
+		if summary == nil {
+			summary = []ResponseSummaryPart{}
+		}
+		return json.Marshal(struct {
+			Type    string                `json:"type"`
+			ID      string                `json:"id"`
+			Summary []ResponseSummaryPart `json:"summary"`
+		}{item.Type, item.ID, summary})
+	case "function_call":
+		return json.Marshal(struct {
+			Type      string `json:"type"`
+			ID        string `json:"id"`
+			Status    string `json:"status"`
+			CallID    string `json:"call_id"`
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		}{item.Type, item.ID, item.Status, item.CallID, item.Name, item.Arguments})
+	default:
+		content := item.Content
+		if content == nil {
+			content = []ResponseOutputContent{}
+		}
+		return json.Marshal(struct {
+			Type    string                  `json:"type"`
+			ID      string                  `json:"id"`
+			Status  string                  `json:"status"`
+			Role    string                  `json:"role"`
+			Content []ResponseOutputContent `json:"content"`
+		}{item.Type, item.ID, item.Status, item.Role, content})
+	}
+}
+
+// ResponseOutputContent 输出内容片段
+type ResponseOutputContent struct {
+	Type        string `json:"type"` // output_text
+	Text        string `json:"text"`
+	Annotations []any  `json:"annotations"`
+}
+
+// ResponseSummaryPart 推理摘要片段
+type ResponseSummaryPart struct {
+	Type string `json:"type"` // summary_text
+	Text string `json:"text"`
+}
+
+// OutputText 拼接所有 output_text 内容
+func (r *Response) OutputText() string {
+	var sb strings.Builder
+	for _, item := range r.Output {
+		if item.Type != "message" {
+			continue
+		}
+		for _, content := range item.Content {
+			if content.Type == "output_text" {
+				sb.WriteString(content.Text)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// ToolCalls 将 function_call 输出项转换为 ChatCompletion 工具调用
+func (r *Response) ToolCalls() []openai.ToolCall {
+	var calls []openai.ToolCall
+	for _, item := range r.Output {
+		if item.Type != "function_call" {
+			continue
+		}
+		calls = append(calls, openai.ToolCall{
+			ID:       item.CallID,
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: item.Name, Arguments: item.Arguments},
+		})
+	}
+	return calls
+}
+
+// ResponseDeleted 删除响应的返回结构
+type ResponseDeleted struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"` // 固定为 response.deleted
+	Deleted bool   `json:"deleted"`
+}
+
+// ResponsesInputToMessages 将 Responses 输入项转换为 ChatCompletion 消息
+func ResponsesInputToMessages(input ResponsesInput) ([]openai.ChatCompletionMessage, error) {
+	messages := make([]openai.ChatCompletionMessage, 0, len(input))
+	for _, item := range input {
+		switch item.Type {
+		case "", "message":
+			role := item.Role
+			if role == "developer" {
+				role = openai.ChatMessageRoleSystem
+			}
+			if role == "" {
+				role = openai.ChatMessageRoleUser
+			}
+			messages = append(messages, responseInputMessage(role, item.Content))
+		case "function_call":
+			call := openai.ToolCall{
+				ID:       item.CallID,
+				Type:     openai.ToolTypeFunction,
+				Function: openai.FunctionCall{Name: item.Name, Arguments: item.Arguments},
+			}
+			// 同一轮回复中的消息与函数调用合并为一条 assistant 消息
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == openai.ChatMessageRoleAssistant {
+				messages[last].ToolCalls = append(messages[last].ToolCalls, call)
+				continue
+			}
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:      openai.ChatMessageRoleAssistant,
+				ToolCalls: []openai.ToolCall{call},
+			})
+		case "function_call_output":
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				ToolCallID: item.CallID,
+				Content:    item.Output,
+			})
+		case "reasoning":
+			// 历史推理过程上游无法复用，直接丢弃
+		default:
+			return nil, fmt.Errorf("unsupported input item type: %s", item.Type)
+		}
+	}
+	return messages, nil
+}
+
+// responseInputMessage 转换单条消息，存在图片或文件时使用 MultiContent
+func responseInputMessage(role string, content ResponseInputContent) openai.ChatCompletionMessage {
+	var texts []string
+	var images []openai.ChatMessagePart
+	for _, part := range content {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			texts = append(texts, part.Text)
+		case "input_image":
+			if part.ImageURL != "" {
+				images = append(images, openai.ChatMessagePart{
+					Type: openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{
+						URL:    part.ImageURL,
+						Detail: openai.ImageURLDetail(part.Detail),
+					},
+				})
+			}
+		case "input_file":
+			switch {
+			case part.FileData != "":
+				images = append(images, NewFilePart(part.FileData, part.Filename))
+			case part.FileID != "":
+				images = append(images, NewFileIDPart(part.FileID, part.Filename))
+			case part.FileURL != "":
+				images = append(images, NewFilePart(part.FileURL, part.Filename))
+			}
+		}
+	}
+
+	text := strings.Join(texts, "\n")
+	if len(images) == 0 {
+		return openai.ChatCompletionMessage{Role: role, Content: text}
+	}
+
+	parts := make([]openai.ChatMessagePart, 0, len(images)+1)
+	parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: text})
+	parts = append(parts, images...)
+	return openai.ChatCompletionMessage{Role: role, MultiContent: parts}
+}
+
+// ResponsesWebSearch 请求带内置的 web_search 工具时返回开启，否则返回 nil，由模型后缀与配置决定
+func ResponsesWebSearch(tools []ResponseTool) *bool {
+	for _, tool := range tools {
+		if tool.Type == "web_search" || tool.Type == "web_search_preview" {
+			enabled := true
+			return &enabled
+		}
+	}
+	return nil
+}
+
+// ResponsesToolsToChatGPT 将 Responses 工具定义转换为 ChatCompletion 工具定义，忽略内置工具
+func ResponsesToolsToChatGPT(tools []ResponseTool) []openai.Tool {
+	var chatTools []openai.Tool
+	for _, tool := range tools {
+		if tool.Type != "function" {
+			continue
+		}
+		chatTools = append(chatTools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return chatTools
+}
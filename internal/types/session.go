@@ -0,0 +1,221 @@
+package types
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"monica-proxy/internal/account"
+	"monica-proxy/internal/cache"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/logger"
+	"monica-proxy/internal/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// SessionKeyHeader 显式指定会话键的请求头
+const SessionKeyHeader = "X-Conversation-Key"
+
+// Session 复用的 Monica 会话
+type Session struct {
+	Messages    int            `json:"messages"`    // 已发送的消息数
+	Fingerprint string         `json:"fingerprint"` // 已发送消息的指纹
+	Account     string         `json:"account"`     // 会话所属的账号，只能由该账号继续
+	Replies     []SessionReply `json:"replies"`     // Monica 对这些消息的回复，n > 1 或重新生成时有多条
+}
+
+// SessionReply Monica 的一条回复，下一轮请求回传的 assistant 消息与它一致时接在它之后继续
+type SessionReply struct {
+	ConversationID string `json:"conversation_id"`
+	ItemID         string `json:"item_id"` // Monica 回复条目的 ID
+	Digest         string `json:"digest"`  // 返回给客户端的正文摘要
+}
+
+// maxSessionReplies 同一组消息最多记录的回复数
+const maxSessionReplies = 16
+
+// sessions 会话存储，键为显式会话键或消息指纹，启动时由 InitSessionStore 按配置创建
+var sessions, _ = cache.New[*Session](cache.Options{})
+
+// InitSessionStore 根据配置创建会话存储，会话闲置超过 TTL 后过期
+func InitSessionStore(cfg *config.Config) error {
+	c, err := cache.New[*Session](cache.Options{
+		MaxEntries: cfg.Session.MaxEntries,
+		TTL:        cfg.Session.TTL,
+	})
+	if err != nil {
+		return err
+	}
+	sessions = c
+	return nil
+}
+
+// sessionKey ctx 中记录显式会话键的键
+type sessionKey struct{}
+
+// WithSessionKey 把请求头中的会话键记录到 ctx 中
+func WithSessionKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionKey{}, key)
+}
+
+// sessionPlan 一次请求的会话复用计划
+type sessionPlan struct {
+	key         string        // 保存会话的键
+	reply       *SessionReply // 接续的回复，为空时新建会话并完整发送
+	skip        int           // 已在会话中的消息数，包括回传的 assistant 回复
+	fingerprint string        // 本次全部消息的指纹
+	messages    int
+	account     string // 请求使用的账号
+}
+
+// planSession 查找可以复用的会话，未开启会话模式时返回 nil
+// 只有客户端回传的 assistant 消息与 Monica 记录的回复一致时才接在该回复之后，只发送其后的新消息；
+// 有显式会话键时只比较该会话，否则从最长的前缀开始按指纹查找；历史被编辑或重新生成导致前缀不一致时完整发送
+// 会话只能由创建它的账号继续，请求已分配其他账号或该账号不可用时完整发送
+func planSession(ctx context.Context, cfg *config.Config, chatReq *openai.ChatCompletionRequest) *sessionPlan {
+	if !cfg.Session.Enabled {
+		return nil
+	}
+	fingerprints := messageFingerprints(chatReq.Model, chatReq.Messages)
+	n := len(chatReq.Messages)
+	plan := &sessionPlan{fingerprint: fingerprints[n], messages: n}
+	defer func() { plan.account = account.Name(ctx) }()
+
+	// 先比较回复再选择账号，account.Prefer 会占用账号
+	resume := func(s *Session) bool {
+		reply := matchReply(s, chatReq.Messages)
+		if reply == nil || !account.Prefer(ctx, s.Account) {
+			return false
+		}
+		plan.reply = reply
+		plan.skip = s.Messages + 1
+		return true
+	}
+
+	key, _ := ctx.Value(sessionKey{}).(string)
+	if key == "" && cfg.Session.KeyFromUser && chatReq.User != "" {
+		key = chatReq.User
+	}
+	if key != "" {
+		// 不同模型对应不同的 bot，会话键按模型区分
+		plan.key = "key:" + chatReq.Model + ":" + key
+		if s, ok := sessions.Get(plan.key); ok && s.Messages+1 < n && fingerprints[s.Messages] == s.Fingerprint {
+			resume(s)
+		}
+		return plan
+	}
+
+	plan.key = "fp:" + plan.fingerprint
+	for i := n - 2; i > 0; i-- {
+		if s, ok := sessions.Get("fp:" + fingerprints[i]); ok && s.Messages == i && resume(s) {
+			break
+		}
+	}
+	return plan
+}
+
+// matchReply 返回客户端在已发送消息之后回传的 assistant 消息对应的回复，不一致时返回 nil
+func matchReply(s *Session, messages []openai.ChatCompletionMessage) *SessionReply {
+	msg := messages[s.Messages]
+	if msg.Role != openai.ChatMessageRoleAssistant || len(msg.ToolCalls) > 0 {
+		return nil
+	}
+	digest := replyDigest(utils.MessageText(msg))
+	for i := range s.Replies {
+		if s.Replies[i].Digest == digest {
+			return &s.Replies[i]
+		}
+	}
+	return nil
+}
+
+// replyDigest 计算回复正文的摘要，忽略客户端回传时保留的开头思考过程与首尾空白
+func replyDigest(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "<think>") {
+		if end := strings.Index(text, "</think>"); end >= 0 {
+			text = strings.TrimSpace(text[end+len("</think>"):])
+		}
+	}
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// newMessages 返回需要发送的消息，复用会话时只包含回传的回复之后的新增部分
+func (p *sessionPlan) newMessages(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	if p == nil || p.reply == nil {
+		return messages
+	}
+	return messages[p.skip:]
+}
+
+// portable 是否没有接续只属于当前账号的会话
+func (p *sessionPlan) portable() bool {
+	return p == nil || p.reply == nil
+}
+
+// setAccount 请求换用其他账号后，会话记录到新账号下
+func (p *sessionPlan) setAccount(name string) {
+	if p != nil {
+		p.account = name
+	}
+}
+
+// sessionsMu 串行化会话的读改写，避免同一会话键的并发请求互相覆盖回复
+var sessionsMu sync.Mutex
+
+// save 上游回复完整结束后记录会话，下一轮请求回传该回复时接在 itemID 之后继续
+// 同一组消息的多个回复（n > 1、重新生成）合并记录，客户端选择其中任意一个继续都能复用
+func (p *sessionPlan) save(conversationID, itemID, text string) {
+	if p == nil || itemID == "" {
+		return
+	}
+	reply := SessionReply{ConversationID: conversationID, ItemID: itemID, Digest: replyDigest(text)}
+
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	replies := []SessionReply{reply}
+	if prev, ok := sessions.Get(p.key); ok && prev.Fingerprint == p.fingerprint && prev.Messages == p.messages && prev.Account == p.account {
+		// 缓存中的会话可能正被其他请求读取，复制后再追加
+		replies = append(append(make([]SessionReply, 0, len(prev.Replies)+1), prev.Replies...), reply)
+		if len(replies) > maxSessionReplies {
+			replies = replies[len(replies)-maxSessionReplies:]
+		}
+	}
+	sessions.Set(p.key, &Session{
+		Messages:    p.messages,
+		Fingerprint: p.fingerprint,
+		Account:     p.account,
+		Replies:     replies,
+	}, 0)
+	if p.reply != nil {
+		logger.Debug("复用会话",
+			zap.String("conversation_id", conversationID),
+			zap.Int("sent_messages", p.messages-p.skip),
+		)
+	}
+}
+
+// messageFingerprints 计算消息各前缀的指纹，第 i 项对应前 i 条消息，模型不同时指纹不同
+func messageFingerprints(model string, messages []openai.ChatCompletionMessage) []string {
+	fingerprints := make([]string, 0, len(messages)+1)
+	sum := sha256.Sum256([]byte(model))
+	fingerprints = append(fingerprints, hex.EncodeToString(sum[:]))
+	for _, msg := range messages {
+		data, _ := json.Marshal(msg)
+		h := sha256.New()
+		h.Write(sum[:])
+		h.Write(data)
+		copy(sum[:], h.Sum(nil))
+		fingerprints = append(fingerprints, hex.EncodeToString(sum[:]))
+	}
+	return fingerprints
+}
@@ -0,0 +1,139 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"monica-proxy/internal/config"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func sessionConfig(t *testing.T) *config.Config {
+	cfg := &config.Config{Session: config.SessionConfig{Enabled: true, TTL: time.Hour, MaxEntries: 100}}
+	if err := InitSessionStore(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func chatMessages(contents ...string) []openai.ChatCompletionMessage {
+	messages := make([]openai.ChatCompletionMessage, 0, len(contents))
+	for i, content := range contents {
+		role := openai.ChatMessageRoleUser
+		if i%2 == 1 {
+			role = openai.ChatMessageRoleAssistant
+		}
+		messages = append(messages, openai.ChatCompletionMessage{Role: role, Content: content})
+	}
+	return messages
+}
+
+// convertSession 转换请求，reply 非空时模拟上游回复完整结束并记录会话
+func convertSession(t *testing.T, ctx context.Context, cfg *config.Config, reply string, contents ...string) *MonicaRequest {
+	t.Helper()
+	req, err := ChatGPTToMonica(ctx, cfg, openai.ChatCompletionRequest{Model: "gpt-4o", Messages: chatMessages(contents...)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "" {
+		req.SaveSession("", reply)
+	}
+	return req
+}
+
+// TestSessionReuse 测试按消息指纹复用会话，接在 Monica 的回复之后继续，编辑历史或回复时完整发送
+func TestSessionReuse(t *testing.T) {
+	cfg := sessionConfig(t)
+	ctx := context.Background()
+
+	first := convertSession(t, ctx, cfg, "你好！", "你好")
+	if len(first.Data.Items) != 2 || first.Data.IsIncognito {
+		t.Fatalf("新会话应包含欢迎消息且不使用无痕模式: %+v", first.Data)
+	}
+
+	second := convertSession(t, ctx, cfg, "星期一", "你好", "你好！", "今天星期几")
+	if second.Data.ConversationID != first.Data.ConversationID || len(second.Data.Items) != 1 {
+		t.Fatalf("应复用会话并只发送新增的提问: %+v", second.Data)
+	}
+	if second.Data.Items[0].ParentItemID != first.Data.PreGeneratedReplyID || second.Data.Items[0].ItemType != "question" {
+		t.Errorf("新增提问应接在上一轮 Monica 的回复之后: %+v", second.Data.Items[0])
+	}
+
+	regenerated := convertSession(t, ctx, cfg, "", "你好", "你好！", "今天星期几")
+	if regenerated.Data.ConversationID != first.Data.ConversationID || regenerated.Data.Items[0].ParentItemID != first.Data.PreGeneratedReplyID {
+		t.Errorf("重新生成应从上一轮继续: %+v", regenerated.Data)
+	}
+
+	withThinking := convertSession(t, ctx, cfg, "", "你好", "<think>打个招呼</think>\n你好！", "今天星期几")
+	if withThinking.Data.ConversationID != first.Data.ConversationID {
+		t.Errorf("回传的回复带思考过程时应忽略思考过程后比较: %+v", withThinking.Data)
+	}
+
+	for _, contents := range [][]string{
+		{"您好", "你好！", "今天星期几"},
+		{"你好", "您好！", "今天星期几"},
+	} {
+		edited := convertSession(t, ctx, cfg, "", contents...)
+		if edited.Data.ConversationID == first.Data.ConversationID || len(edited.Data.Items) != 4 {
+			t.Errorf("编辑历史或回复后应新建会话并完整发送 %q: %+v", contents, edited.Data)
+		}
+	}
+}
+
+// TestSessionKey 测试显式会话键，历史不一致时回退为完整发送
+func TestSessionKey(t *testing.T) {
+	cfg := sessionConfig(t)
+	ctx := WithSessionKey(context.Background(), "chat-1")
+
+	first := convertSession(t, ctx, cfg, "回答", "第一个问题")
+	second := convertSession(t, ctx, cfg, "回答二", "第一个问题", "回答", "第二个问题")
+	if second.Data.ConversationID != first.Data.ConversationID || len(second.Data.Items) != 1 {
+		t.Fatalf("应复用会话: %+v", second.Data)
+	}
+	regenerated := convertSession(t, ctx, cfg, "", "第一个问题", "回答", "第二个问题")
+	if regenerated.Data.ConversationID == first.Data.ConversationID {
+		t.Errorf("显式会话键的历史不一致时应新建会话")
+	}
+
+	// 未调用 SaveSession（上游回复未完整结束）时不记录会话
+	convertSession(t, WithSessionKey(context.Background(), "chat-2"), cfg, "", "问题")
+	if _, ok := sessions.Get("key:gpt-4o:chat-2"); ok {
+		t.Errorf("上游回复结束前不应记录会话")
+	}
+}
+
+// TestSessionConcurrentReplies 测试同一会话键并发的多个回复都被记录，客户端选择任意一个都能继续
+func TestSessionConcurrentReplies(t *testing.T) {
+	cfg := sessionConfig(t)
+	ctx := WithSessionKey(context.Background(), "chat-n")
+
+	replies := []string{"甲", "乙", "丙", "丁"}
+	reqs := make([]*MonicaRequest, len(replies))
+	for i := range replies {
+		reqs[i] = convertSession(t, ctx, cfg, "", "问题")
+	}
+	var wg sync.WaitGroup
+	for i, reply := range replies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reqs[i].SaveSession(fmt.Sprintf("msg:reply-%d", i), reply)
+		}()
+	}
+	wg.Wait()
+
+	for i, reply := range replies {
+		next := convertSession(t, ctx, cfg, "", "问题", reply, "追问")
+		if next.Data.ConversationID != reqs[i].Data.ConversationID || next.Data.Items[0].ParentItemID != fmt.Sprintf("msg:reply-%d", i) {
+			t.Errorf("选择回复 %s 后应接在它之后继续: %+v", reply, next.Data)
+		}
+	}
+	unknown := convertSession(t, ctx, cfg, "", "问题", "戊", "追问")
+	if len(unknown.Data.Items) != 4 {
+		t.Errorf("回传的回复与记录不一致时应完整发送: %+v", unknown.Data)
+	}
+}
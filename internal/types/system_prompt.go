@@ -0,0 +1,111 @@
+package types
+
+import (
+	"strings"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// isSystemMessage system 与 developer 消息都视为系统提示词
+func isSystemMessage(msg openai.ChatCompletionMessage) bool {
+	return msg.Role == openai.ChatMessageRoleSystem || msg.Role == openai.ChatMessageRoleDeveloper
+}
+
+// SystemPrompt 按出现顺序拼接所有 system / developer 消息的文本
+func SystemPrompt(messages []openai.ChatCompletionMessage) string {
+	var parts []string
+	for _, msg := range messages {
+		if !isSystemMessage(msg) {
+			continue
+		}
+		if text := strings.TrimSpace(utils.MessageText(msg)); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// ApplySystemPrompt 按配置的策略把 system / developer 消息改写为 Monica 普通对话能够接受的消息
+// Monica 普通对话没有系统提示词字段，pair 策略插入一问一答，merge 策略按模板并入第一条用户消息，
+// 其余策略直接去掉系统消息（custom_bot 策略在服务层改走 Custom Bot 接口）
+func ApplySystemPrompt(cfg *config.SystemPromptConfig, messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	system := SystemPrompt(messages)
+
+	result := make([]openai.ChatCompletionMessage, 0, len(messages)+2)
+	for _, msg := range messages {
+		if !isSystemMessage(msg) {
+			result = append(result, msg)
+		}
+	}
+	if system == "" {
+		return result
+	}
+
+	switch cfg.Strategy {
+	case config.SystemPromptMerge:
+		for i := range result {
+			if result[i].Role == openai.ChatMessageRoleUser {
+				result[i] = mergeSystemPrompt(cfg.Template, system, result[i])
+				return result
+			}
+		}
+		// 没有用户消息可以合并时退回一问一答
+		fallthrough
+	case config.SystemPromptPair:
+		pair := []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: system},
+			{Role: openai.ChatMessageRoleAssistant, Content: cfg.PairReply},
+		}
+		return append(pair, result...)
+	}
+	return result
+}
+
+// pinnedSystemMessages 按策略改写后，开头承载系统提示词的消息数，截断上下文时需要保留
+func pinnedSystemMessages(cfg *config.SystemPromptConfig, messages []openai.ChatCompletionMessage) int {
+	if SystemPrompt(messages) == "" {
+		return 0
+	}
+	switch cfg.Strategy {
+	case config.SystemPromptMerge:
+		i := 0
+		for _, msg := range messages {
+			if isSystemMessage(msg) {
+				continue
+			}
+			i++
+			if msg.Role == openai.ChatMessageRoleUser {
+				return i
+			}
+		}
+		return 2
+	case config.SystemPromptPair:
+		return 2
+	}
+	return 0
+}
+
+// mergeSystemPrompt 按模板把系统提示词合并进用户消息，多段内容的消息在首尾补充文本段
+func mergeSystemPrompt(template, system string, msg openai.ChatCompletionMessage) openai.ChatCompletionMessage {
+	template = strings.ReplaceAll(template, config.SystemPromptPlaceholder, system)
+	prefix, suffix, _ := strings.Cut(template, config.UserPromptPlaceholder)
+
+	if len(msg.MultiContent) == 0 {
+		msg.Content = prefix + msg.Content + suffix
+		return msg
+	}
+
+	parts := make([]openai.ChatMessagePart, 0, len(msg.MultiContent)+2)
+	if prefix = strings.TrimSpace(prefix); prefix != "" {
+		parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: prefix})
+	}
+	parts = append(parts, msg.MultiContent...)
+	if suffix = strings.TrimSpace(suffix); suffix != "" {
+		parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: suffix})
+	}
+	msg.MultiContent = parts
+	return msg
+}
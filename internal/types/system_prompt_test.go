@@ -0,0 +1,89 @@
+package types
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"monica-proxy/internal/config"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+var systemPromptMessages = []openai.ChatCompletionMessage{
+	{Role: openai.ChatMessageRoleSystem, Content: "You are a pirate."},
+	{Role: openai.ChatMessageRoleDeveloper, Content: "Answer briefly."},
+	{Role: openai.ChatMessageRoleUser, Content: "Hello"},
+	{Role: openai.ChatMessageRoleAssistant, Content: "Ahoy"},
+	{Role: openai.ChatMessageRoleUser, Content: "Bye"},
+}
+
+// TestSystemPrompt 测试多条 system / developer 消息按顺序拼接
+func TestSystemPrompt(t *testing.T) {
+	if got := SystemPrompt(systemPromptMessages); got != "You are a pirate.\n\nAnswer briefly." {
+		t.Errorf("SystemPrompt = %q", got)
+	}
+}
+
+// TestApplySystemPrompt 测试各系统提示词策略
+func TestApplySystemPrompt(t *testing.T) {
+	merge := &config.SystemPromptConfig{Strategy: config.SystemPromptMerge, Template: "[{{system}}] {{user}}"}
+	messages := ApplySystemPrompt(merge, systemPromptMessages)
+	if len(messages) != 3 || messages[0].Content != "[You are a pirate.\n\nAnswer briefly.] Hello" || messages[2].Content != "Bye" {
+		t.Errorf("merge 策略结果错误: %+v", messages)
+	}
+
+	pair := &config.SystemPromptConfig{Strategy: config.SystemPromptPair, PairReply: "OK"}
+	messages = ApplySystemPrompt(pair, systemPromptMessages)
+	if len(messages) != 5 || messages[0].Role != openai.ChatMessageRoleUser || messages[1].Content != "OK" || messages[2].Content != "Hello" {
+		t.Errorf("pair 策略结果错误: %+v", messages)
+	}
+
+	ignore := &config.SystemPromptConfig{Strategy: config.SystemPromptIgnore}
+	messages = ApplySystemPrompt(ignore, systemPromptMessages)
+	if len(messages) != 3 || messages[0].Content != "Hello" {
+		t.Errorf("ignore 策略结果错误: %+v", messages)
+	}
+
+	// 原消息不应被修改
+	if systemPromptMessages[2].Content != "Hello" {
+		t.Error("ApplySystemPrompt 不应修改传入的消息")
+	}
+}
+
+// TestMergeSystemPromptMultiContent 测试多段内容消息的合并
+func TestMergeSystemPromptMultiContent(t *testing.T) {
+	msg := openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleUser,
+		MultiContent: []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "data:image/png;base64,AA=="}},
+		},
+	}
+	merged := mergeSystemPrompt("<s>{{system}}</s>\n{{user}}", "rules", msg)
+	if len(merged.MultiContent) != 2 || merged.MultiContent[0].Text != "<s>rules</s>" {
+		t.Errorf("多段内容合并结果错误: %+v", merged.MultiContent)
+	}
+}
+
+// TestChatGPTToMonicaMultiContent 测试没有附件的多段内容消息按文本部分发送，合并的系统提示词不会丢失
+func TestChatGPTToMonicaMultiContent(t *testing.T) {
+	cfg := &config.Config{SystemPrompt: config.SystemPromptConfig{Strategy: config.SystemPromptMerge, Template: "[{{system}}] {{user}}"}}
+	req := openai.ChatCompletionRequest{
+		Model: "gpt-4o",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "You are a pirate."},
+			{Role: openai.ChatMessageRoleUser, MultiContent: []openai.ChatMessagePart{
+				{Type: openai.ChatMessagePartTypeText, Text: "Hello"},
+				{Type: openai.ChatMessagePartTypeText, Text: "there"},
+			}},
+		},
+	}
+	mReq, err := ChatGPTToMonica(context.Background(), cfg, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := mReq.Data.Items[len(mReq.Data.Items)-1]
+	if item.Data.Type != "text" || !strings.Contains(item.Data.Content, "You are a pirate.") || !strings.Contains(item.Data.Content, "there") {
+		t.Errorf("多段内容的文本未发送: %+v", item.Data)
+	}
+}
@@ -0,0 +1,436 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"monica-proxy/internal/account"
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+	"monica-proxy/internal/logger"
+	"monica-proxy/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	lop "github.com/samber/lo/parallel"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+const (
+	// 轮询文件解析状态的退避间隔
+	fileIndexPollMin = 250 * time.Millisecond
+	fileIndexPollMax = 4 * time.Second
+
+	// uploadRetryInterval 重试上传图片的基础间隔，第 n 次重试前等待 n 倍
+	uploadRetryInterval = 500 * time.Millisecond
+)
+
+// pendingUpload 已校验、等待上传到Monica的文件
+type pendingUpload struct {
+	data         []byte
+	info         *FileInfo
+	indexTimeout time.Duration // 等待 Monica 解析完成的最长时间
+	cacheKeys    []string      // 上传成功后写入缓存的键，第一个为内容键，其余为别名
+
+	result *FileInfo
+	err    error
+}
+
+// attachment 消息中的一个附件
+type attachment struct {
+	message  int  // 所属消息的下标
+	content  int  // 所属内容段的下标
+	document bool // 文档是回答的依据，上传失败时整个请求失败；图片失败时按配置的策略处理
+	part     openai.ChatMessagePart
+	source   string // 图片地址
+
+	upload  *pendingUpload
+	result  *FileInfo
+	err     error
+	invalid bool // 附件本身无效（格式错误、无法下载等），而非上传到Monica失败
+}
+
+// name 附件在请求中的位置
+func (a *attachment) name() string {
+	return fmt.Sprintf("messages[%d].content[%d]", a.message, a.content)
+}
+
+// messageUpload 单条消息的附件上传结果
+type messageUpload struct {
+	files []FileInfo
+	notes []string // lenient 策略下上传失败的图片说明，附加到消息文本中告知模型
+}
+
+// text 在消息文本后附加上传失败的说明
+func (m *messageUpload) text(content string) string {
+	for _, note := range m.notes {
+		content = joinText(content, note)
+	}
+	return content
+}
+
+// messageAttachments 收集消息中的图片与文档附件
+// MIME 为文档类型的 data: URL 按文档附件处理
+func messageAttachments(index int, msg openai.ChatCompletionMessage) []*attachment {
+	var attachments []*attachment
+	for j, part := range msg.MultiContent {
+		switch part.Type {
+		case openai.ChatMessagePartTypeImageURL:
+			if part.ImageURL == nil {
+				continue
+			}
+			if isDocumentDataURL(part.ImageURL.URL) {
+				attachments = append(attachments, &attachment{message: index, content: j, document: true, part: NewFilePart(part.ImageURL.URL, "")})
+			} else {
+				attachments = append(attachments, &attachment{message: index, content: j, part: part, source: part.ImageURL.URL})
+			}
+		case ChatMessagePartTypeFile:
+			attachments = append(attachments, &attachment{message: index, content: j, document: true, part: part})
+		}
+	}
+	return attachments
+}
+
+// uploadMessageAttachments 上传所有消息中的附件，返回每条消息的上传结果，不带附件的消息对应 nil
+// 所有需要上传的文件共用一次预签名、一次 batch_create 与一个解析状态轮询，客户端取消请求时上传随之停止
+// 文档上传失败时整个请求失败；图片上传失败时按 cfg.ImageUpload 的策略返回错误或跳过
+func uploadMessageAttachments(ctx context.Context, cfg *config.Config, messages []openai.ChatCompletionMessage) ([]*messageUpload, error) {
+	var attachments []*attachment
+	for i, msg := range messages {
+		attachments = append(attachments, messageAttachments(i, msg)...)
+	}
+	uploads := make([]*messageUpload, len(messages))
+	if len(attachments) == 0 {
+		return uploads, nil
+	}
+
+	indexTimeout := imageIndexTimeout
+	if lo.ContainsBy(attachments, func(a *attachment) bool { return a.document }) {
+		indexTimeout = max(indexTimeout, cfg.Attachments.IndexTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, ImageUploadTimeout+indexTimeout)
+	defer cancel()
+
+	resolveAttachments(ctx, cfg, attachments)
+
+	policy := cfg.ImageUpload.FailurePolicy
+	if policy == config.ImageUploadRetry {
+		retryAttachments(ctx, cfg, attachments)
+		policy = cfg.ImageUpload.RetryFallback
+	}
+
+	var failed []string
+	for _, a := range attachments {
+		if uploads[a.message] == nil {
+			uploads[a.message] = &messageUpload{files: []FileInfo{}}
+		}
+		if a.err == nil {
+			uploads[a.message].files = append(uploads[a.message].files, *a.result)
+			continue
+		}
+
+		if a.document {
+			logger.Error("上传文档失败", zap.String("part", a.name()), zap.Error(a.err))
+			return nil, a.err
+		}
+		logger.Error("上传图片失败",
+			zap.String("part", a.name()),
+			zap.String("image_url", logImageURL(a.source)),
+			zap.Error(a.err),
+		)
+		if policy == config.ImageUploadStrict {
+			if a.invalid {
+				return nil, errors.NewInvalidInputError(fmt.Sprintf("%s 的图片无效: %v", a.name(), a.err), a.err)
+			}
+			return nil, errors.NewImageUploadError(a.name(), a.err)
+		}
+		failed = append(failed, a.name())
+		uploads[a.message].notes = append(uploads[a.message].notes,
+			fmt.Sprintf("[Note: the image attached as content part %d of this message failed to upload and is not visible to you. Tell the user if the answer depends on it.]", a.content+1))
+	}
+
+	if len(failed) > 0 {
+		logger.Warn("部分图片上传失败，已跳过",
+			zap.Strings("parts", failed),
+			zap.Int("total", len(attachments)),
+		)
+		utils.AddResponseHeader(ctx, utils.WarningHeader, "image upload failed: "+strings.Join(failed, ", "))
+	}
+	return uploads, nil
+}
+
+// retryAttachments 以递增的间隔重试上传失败的图片，直到全部成功、次数用尽或请求取消
+// 格式错误、超出大小等本身无效的图片重试也不会成功，不重试
+func retryAttachments(ctx context.Context, cfg *config.Config, attachments []*attachment) {
+	for attempt := 1; attempt <= cfg.ImageUpload.MaxRetries; attempt++ {
+		failed := lo.Filter(attachments, func(a *attachment, _ int) bool { return !a.document && a.err != nil && !a.invalid })
+		if len(failed) == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(attempt) * uploadRetryInterval):
+		}
+
+		logger.Info("重试上传图片", zap.Int("attempt", attempt), zap.Int("count", len(failed)))
+		for _, a := range failed {
+			a.upload, a.result, a.err = nil, nil, nil
+		}
+		resolveAttachments(ctx, cfg, failed)
+	}
+}
+
+// resolveAttachments 读取、校验并批量上传附件，结果写入每个 attachment
+func resolveAttachments(ctx context.Context, cfg *config.Config, attachments []*attachment) {
+	// 1. 读取、校验附件并查找缓存，远程链接需要下载，因此并发进行
+	lop.ForEach(attachments, func(a *attachment, _ int) {
+		if a.document {
+			a.result, a.upload, a.err = prepareDocument(ctx, cfg, a.part)
+		} else {
+			a.result, a.upload, a.err = prepareImage(ctx, cfg, a.source, string(a.part.ImageURL.Detail))
+		}
+		a.invalid = a.err != nil
+	})
+
+	// 2. 相同内容只上传一次
+	var uploads []*pendingUpload
+	byKey := make(map[string]*pendingUpload)
+	for _, a := range attachments {
+		if a.upload == nil {
+			continue
+		}
+		if existing, ok := byKey[a.upload.cacheKeys[0]]; ok {
+			existing.cacheKeys = lo.Union(existing.cacheKeys, a.upload.cacheKeys[1:])
+			a.upload = existing
+			continue
+		}
+		byKey[a.upload.cacheKeys[0]] = a.upload
+		uploads = append(uploads, a.upload)
+	}
+
+	// 3. 批量上传并写入缓存
+	uploadBatch(ctx, cfg, uploads)
+	for _, u := range uploads {
+		if u.err != nil {
+			continue
+		}
+		for i, key := range u.cacheKeys {
+			size := int64(0)
+			if i == 0 {
+				size = int64(len(u.data))
+			}
+			imageCache.Set(accountCacheKey(ctx, key), u.result, size)
+		}
+	}
+
+	for _, a := range attachments {
+		switch {
+		case a.upload == nil:
+		case a.upload.err == nil:
+			a.result = a.upload.result
+		case a.document:
+			a.err = uploadError(a.upload)
+		default:
+			a.err = a.upload.err
+		}
+	}
+}
+
+// logImageURL 日志中只保留 data: URL 的前缀，避免输出整张图片
+func logImageURL(url string) string {
+	if len(url) > 64 && !utils.IsRemoteURL(url) {
+		return url[:64] + "..."
+	}
+	return url
+}
+
+// uploadError 将文档上传错误转换为 AppError：Monica 解析失败返回 422，其余返回 500
+func uploadError(u *pendingUpload) error {
+	if indexErr, ok := u.err.(*FileIndexError); ok {
+		return errors.NewFileIndexError(u.info.FileName, indexErr)
+	}
+	return errors.NewFileUploadError(u.err)
+}
+
+// uploadBatch 批量上传文件：一次预签名、并发 PUT、一次创建文件对象，再统一等待 Monica 解析完成
+// 结果与错误写入每个 pendingUpload，单个文件失败不影响其他文件
+func uploadBatch(ctx context.Context, cfg *config.Config, uploads []*pendingUpload) {
+	if len(uploads) == 0 {
+		return
+	}
+	fail := func(list []*pendingUpload, err error) {
+		for _, u := range list {
+			u.err = err
+		}
+	}
+
+	// 1. 获取预签名URL
+	preSignReq := &PreSignRequest{
+		FilenameList: lo.Map(uploads, func(u *pendingUpload, _ int) string { return u.info.FileName }),
+		Module:       ImageModule,
+		Location:     ImageLocation,
+		ObjID:        uuid.New().String(),
+	}
+
+	var preSignResp PreSignResponse
+	resp, err := utils.RestyDefaultClient.R().
+		SetContext(ctx).
+		SetHeader("cookie", account.Cookie(ctx)).
+		SetBody(preSignReq).
+		SetResult(&preSignResp).
+		Post(PreSignURL)
+	if err != nil {
+		fail(uploads, fmt.Errorf("get pre-sign url failed: %v", err))
+		return
+	}
+	account.Report(ctx, resp.StatusCode())
+	signed := preSignResp.Data
+	if len(signed.PreSignURLList) < len(uploads) || len(signed.ObjectURLList) < len(uploads) || len(signed.CDNURLList) < len(uploads) {
+		fail(uploads, fmt.Errorf("pre-sign returned %d urls for %d files", len(signed.PreSignURLList), len(uploads)))
+		return
+	}
+
+	// 2. 并发上传文件数据
+	sem := make(chan struct{}, MaxConcurrentUploads)
+	var wg sync.WaitGroup
+	for i, u := range uploads {
+		u.info.ObjectURL = signed.ObjectURLList[i]
+		u.info.FileURL = signed.CDNURLList[i]
+		wg.Add(1)
+		go func(u *pendingUpload, preSignURL string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			resp, err := utils.RestyDefaultClient.R().
+				SetContext(ctx).
+				SetHeader("Content-Type", u.info.FileType).
+				SetBody(u.data).
+				Put(preSignURL)
+			if err == nil && resp.IsError() {
+				err = fmt.Errorf("status %d", resp.StatusCode())
+			}
+			if err != nil {
+				u.err = fmt.Errorf("upload file failed: %v", err)
+			}
+		}(u, signed.PreSignURLList[i])
+	}
+	wg.Wait()
+
+	uploaded := lo.Filter(uploads, func(u *pendingUpload, _ int) bool { return u.err == nil })
+	if len(uploaded) == 0 {
+		return
+	}
+
+	// 3. 创建文件对象
+	uploadReq := &FileUploadRequest{
+		Data: lo.Map(uploaded, func(u *pendingUpload, _ int) FileInfo { return *u.info }),
+	}
+	var uploadResp FileUploadResponse
+	resp, err = utils.RestyDefaultClient.R().
+		SetContext(ctx).
+		SetHeader("cookie", account.Cookie(ctx)).
+		SetBody(uploadReq).
+		SetResult(&uploadResp).
+		Post(FileUploadURL)
+	if err != nil {
+		fail(uploaded, fmt.Errorf("create file object failed: %v", err))
+		return
+	}
+	account.Report(ctx, resp.StatusCode())
+	if len(uploadResp.Data.Items) != len(uploaded) {
+		fail(uploaded, fmt.Errorf("create file object returned %d items for %d files", len(uploadResp.Data.Items), len(uploaded)))
+		return
+	}
+	for i, u := range uploaded {
+		item := uploadResp.Data.Items[i]
+		u.info.FileName = item.FileName
+		u.info.FileType = item.FileType
+		u.info.FileSize = item.FileSize
+		u.info.FileUID = item.FileUID
+		u.info.FileExt = item.FileType
+		u.info.FileTokens = item.FileTokens
+		u.info.FileChunks = item.FileChunks
+		u.info.UseFullText = true
+	}
+
+	// 4. 等待 Monica 解析文件
+	waitFilesIndexed(ctx, cfg, uploaded)
+	for _, u := range uploaded {
+		if u.err == nil {
+			u.info.URL = ""
+			u.info.ObjectURL = ""
+			u.result = u.info
+		}
+	}
+}
+
+// waitFilesIndexed 以指数退避轮询 batch_get_file，直到所有文件解析完成（file_chunks > 0）
+// 返回 error_message 时视为解析失败，超过各自的 indexTimeout 仍未完成时记为超时
+func waitFilesIndexed(ctx context.Context, cfg *config.Config, uploads []*pendingUpload) {
+	start := time.Now()
+	pending := make(map[string]*pendingUpload, len(uploads))
+	for _, u := range uploads {
+		pending[u.info.FileUID] = u
+	}
+
+	delay := fileIndexPollMin
+	for len(pending) > 0 {
+		var batchResp FileBatchGetResponse
+		_, err := utils.RestyDefaultClient.R().
+			SetContext(ctx).
+			SetHeader("cookie", account.Cookie(ctx)).
+			SetBody(map[string][]string{"file_uids": lo.Keys(pending)}).
+			SetResult(&batchResp).
+			Post(FileGetURL)
+		if err != nil {
+			for _, u := range pending {
+				u.err = fmt.Errorf("batch get file failed: %v", err)
+			}
+			return
+		}
+
+		for _, item := range batchResp.Data.Items {
+			u, ok := pending[item.FileUid]
+			if !ok {
+				continue
+			}
+			switch {
+			case item.ErrorMessage != "":
+				u.err = &FileIndexError{FileName: item.FileName, IndexState: item.IndexState, ErrorMessage: item.ErrorMessage}
+			case item.FileChunks > 0:
+				u.info.FileChunks = item.FileChunks
+				u.info.FileTokens = item.FileTokens
+			default:
+				continue
+			}
+			delete(pending, item.FileUid)
+		}
+
+		elapsed := time.Since(start)
+		for uid, u := range pending {
+			if elapsed >= u.indexTimeout {
+				u.err = fmt.Errorf("wait for file index timed out after %s", u.indexTimeout)
+				delete(pending, uid)
+			}
+		}
+		if len(pending) == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			for _, u := range pending {
+				u.err = ctx.Err()
+			}
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, fileIndexPollMax)
+	}
+}
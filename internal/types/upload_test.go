@@ -0,0 +1,203 @@
+package types
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"monica-proxy/internal/config"
+	"monica-proxy/internal/errors"
+	"monica-proxy/internal/utils"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeMonica 模拟 Monica 文件接口，记录各接口的调用次数
+type fakeMonica struct {
+	mu    sync.Mutex
+	calls map[string]int
+	polls int // 第几次轮询后文件解析完成
+}
+
+func (f *fakeMonica) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	url := req.URL.String()
+	f.calls[url]++
+
+	var body []byte
+	var payload map[string][]any
+	if req.Body != nil {
+		data, _ := io.ReadAll(req.Body)
+		_ = json.Unmarshal(data, &payload)
+	}
+	switch url {
+	case PreSignURL:
+		var pre PreSignResponse
+		for i := range payload["filename_list"] {
+			n := string(rune('a' + i))
+			pre.Data.PreSignURLList = append(pre.Data.PreSignURLList, "https://upload.test/"+n)
+			pre.Data.ObjectURLList = append(pre.Data.ObjectURLList, "https://object.test/"+n)
+			pre.Data.CDNURLList = append(pre.Data.CDNURLList, "https://cdn.test/"+n)
+		}
+		body, _ = json.Marshal(pre)
+	case FileUploadURL:
+		items := make([]map[string]any, 0, len(payload["data"]))
+		for i, item := range payload["data"] {
+			info := item.(map[string]any)
+			items = append(items, map[string]any{"file_name": info["file_name"], "file_uid": "uid-" + string(rune('a'+i))})
+		}
+		body, _ = json.Marshal(map[string]any{"data": map[string]any{"items": items}})
+	case FileGetURL:
+		chunks := 0
+		if f.calls[url] >= f.polls {
+			chunks = 1
+		}
+		items := make([]FileBatchGetItem, 0, len(payload["file_uids"]))
+		for _, uid := range payload["file_uids"] {
+			items = append(items, FileBatchGetItem{FileUid: uid.(string), FileChunks: int64(chunks), FileTokens: 7})
+		}
+		var resp FileBatchGetResponse
+		resp.Data.Items = items
+		body, _ = json.Marshal(resp)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: io.NopCloser(strings.NewReader(string(body))), Request: req}, nil
+}
+
+// pngDataURL 生成内容各不相同的 PNG data: URL
+func pngDataURL(seed byte) string {
+	data := append([]byte("\x89PNG\r\n\x1a\n"), seed)
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// TestUploadMessageAttachmentsBatch 测试一个请求中的所有图片共用一次预签名、一次创建文件对象与一个轮询循环
+func TestUploadMessageAttachmentsBatch(t *testing.T) {
+	previous := utils.RestyDefaultClient
+	defer func() { utils.RestyDefaultClient = previous }()
+	fake := &fakeMonica{calls: make(map[string]int), polls: 2}
+	utils.RestyDefaultClient = resty.New().SetTransport(fake)
+
+	image := func(url string) openai.ChatMessagePart {
+		return openai.ChatMessagePart{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: url}}
+	}
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, MultiContent: []openai.ChatMessagePart{image(pngDataURL(1)), image(pngDataURL(2))}},
+		{Role: openai.ChatMessageRoleAssistant, Content: "ok"},
+		// 与第一张相同的图片只上传一次
+		{Role: openai.ChatMessageRoleUser, MultiContent: []openai.ChatMessagePart{image(pngDataURL(3)), image(pngDataURL(1))}},
+	}
+
+	uploads, err := uploadMessageAttachments(context.Background(), &config.Config{}, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads[0].files) != 2 || uploads[1] != nil || len(uploads[2].files) != 2 {
+		t.Fatalf("结果错误: %+v", uploads)
+	}
+	first := uploads[0].files[0]
+	if uploads[2].files[1].FileUID != first.FileUID || first.FileTokens != 7 || first.FileURL == "" {
+		t.Errorf("文件信息错误: %+v", uploads)
+	}
+	if fake.calls[PreSignURL] != 1 || fake.calls[FileUploadURL] != 1 || fake.calls[FileGetURL] != 2 {
+		t.Errorf("接口调用次数错误: %v", fake.calls)
+	}
+	if puts := fake.calls["https://upload.test/a"] + fake.calls["https://upload.test/b"] + fake.calls["https://upload.test/c"]; puts != 3 {
+		t.Errorf("PUT 次数 = %d, 期望 3", puts)
+	}
+}
+
+// TestUploadMessageAttachmentsCancel 测试客户端取消请求时停止等待解析
+func TestUploadMessageAttachmentsCancel(t *testing.T) {
+	previous := utils.RestyDefaultClient
+	defer func() { utils.RestyDefaultClient = previous }()
+	utils.RestyDefaultClient = resty.New().SetTransport(&fakeMonica{calls: make(map[string]int), polls: 1000})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	messages := []openai.ChatCompletionMessage{{
+		Role:         openai.ChatMessageRoleUser,
+		MultiContent: []openai.ChatMessagePart{NewFilePart("data:text/plain;base64,aGVsbG8=", "a.txt")},
+	}}
+	cfg := &config.Config{Attachments: config.AttachmentsConfig{MaxSize: 1024, AllowedTypes: []string{"text/plain"}, IndexTimeout: time.Minute}}
+	if _, err := uploadMessageAttachments(ctx, cfg, messages); err == nil {
+		t.Error("请求取消后应返回错误")
+	}
+}
+
+// TestImageUploadFailurePolicy 测试图片上传失败时的 strict、lenient 与 retry 策略
+func TestImageUploadFailurePolicy(t *testing.T) {
+	previous := utils.RestyDefaultClient
+	defer func() { utils.RestyDefaultClient = previous }()
+	fake := &fakeMonica{calls: make(map[string]int), polls: 1}
+	utils.RestyDefaultClient = resty.New().SetTransport(fake)
+
+	messages := []openai.ChatCompletionMessage{{
+		Role: openai.ChatMessageRoleUser,
+		MultiContent: []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeText, Text: "看图"},
+			{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "data:image/png;base64,!!!"}},
+		},
+	}}
+
+	strict := &config.Config{ImageUpload: config.ImageUploadConfig{FailurePolicy: config.ImageUploadStrict}}
+	_, err := uploadMessageAttachments(context.Background(), strict, messages)
+	if appErr, ok := err.(*errors.AppError); !ok || appErr.Status != http.StatusBadRequest || !strings.Contains(appErr.Message, "messages[0].content[1]") {
+		t.Errorf("strict 策略应返回指明内容段的错误: %v", err)
+	}
+
+	ctx, headers := utils.WithResponseHeaders(context.Background())
+	lenient := &config.Config{ImageUpload: config.ImageUploadConfig{FailurePolicy: config.ImageUploadLenient}}
+	uploads, err := uploadMessageAttachments(ctx, lenient, messages)
+	if err != nil || len(uploads[0].files) != 0 || len(uploads[0].notes) != 1 {
+		t.Fatalf("lenient 策略结果错误: %+v, %v", uploads, err)
+	}
+	if text := uploads[0].text("看图"); !strings.HasPrefix(text, "看图\n[Note:") {
+		t.Errorf("消息中应注明上传失败: %q", text)
+	}
+	header := make(http.Header)
+	headers.CopyTo(header)
+	if !strings.Contains(header.Get(utils.WarningHeader), "messages[0].content[1]") {
+		t.Errorf("缺少警告响应头: %v", header)
+	}
+
+	// 本身无效的图片不重试
+	noRetry := &config.Config{ImageUpload: config.ImageUploadConfig{FailurePolicy: config.ImageUploadRetry, MaxRetries: 3, RetryFallback: config.ImageUploadLenient}}
+	start := time.Now()
+	if uploads, err := uploadMessageAttachments(context.Background(), noRetry, messages); err != nil || len(uploads[0].notes) != 1 {
+		t.Errorf("无效图片按 retry_fallback 处理的结果错误: %+v, %v", uploads, err)
+	}
+	if elapsed := time.Since(start); elapsed >= uploadRetryInterval {
+		t.Errorf("无效图片不应重试，耗时 %s", elapsed)
+	}
+
+	// 上传失败后重试成功
+	failing := &failingTransport{next: fake, failures: 1}
+	utils.RestyDefaultClient = resty.New().SetTransport(failing)
+	messages[0].MultiContent[1].ImageURL.URL = pngDataURL(42)
+	retry := &config.Config{ImageUpload: config.ImageUploadConfig{FailurePolicy: config.ImageUploadRetry, MaxRetries: 1, RetryFallback: config.ImageUploadStrict}}
+	uploads, err = uploadMessageAttachments(context.Background(), retry, messages)
+	if err != nil || len(uploads[0].files) != 1 {
+		t.Errorf("retry 策略结果错误: %+v, %v", uploads, err)
+	}
+}
+
+// failingTransport 前 failures 次预签名请求返回错误
+type failingTransport struct {
+	next     http.RoundTripper
+	failures int
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.String() == PreSignURL && f.failures > 0 {
+		f.failures--
+		return nil, io.ErrUnexpectedEOF
+	}
+	return f.next.RoundTrip(req)
+}
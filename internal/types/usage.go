@@ -0,0 +1,35 @@
+package types
+
+import "monica-proxy/internal/tokenizer"
+
+// PromptTokens 按转换后实际发送给 Monica 的会话条目计算输入 token 数
+func (r *MonicaRequest) PromptTokens(model string) int {
+	return countItemTokens(model, r.Data.Items)
+}
+
+// PromptTokens 按转换后实际发送给 Monica 的会话条目与 bot 提示词计算输入 token 数
+func (r *CustomBotRequest) PromptTokens(model string) int {
+	n := countItemTokens(model, r.Data.Items)
+	if r.BotData.Prompt != "" {
+		n += tokenizer.Count(model, r.BotData.Prompt) + tokenizer.TokensPerMessage
+	}
+	return n
+}
+
+// countItemTokens 统计会话条目的文本与附件 token，附件使用 Monica 上传时返回的 file_tokens
+func countItemTokens(model string, items []Item) int {
+	n := tokenizer.TokensPerReply
+	for _, item := range items {
+		n += ItemTokens(model, item)
+	}
+	return n
+}
+
+// ItemTokens 计算单个会话条目的 token 数，附件使用 Monica 上传时返回的 file_tokens
+func ItemTokens(model string, item Item) int {
+	n := tokenizer.TokensPerMessage + tokenizer.Count(model, item.Data.Content)
+	for _, file := range item.Data.FileInfos {
+		n += int(file.FileTokens)
+	}
+	return n
+}
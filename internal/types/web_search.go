@@ -0,0 +1,49 @@
+package types
+
+import (
+	"context"
+	"monica-proxy/internal/config"
+	"slices"
+	"strings"
+)
+
+// OnlineModelSuffix 模型名后缀，如 gpt-4o:online，带该后缀时开启联网搜索
+const OnlineModelSuffix = ":online"
+
+// webSearchKey ctx 中记录请求级联网搜索开关的键
+type webSearchKey struct{}
+
+// WithWebSearch 去掉模型名的 :online 后缀，并把请求级的联网搜索开关记录到 ctx 中
+// enabled 为请求中显式指定的开关，优先于模型后缀；两者都没有时由转换请求时按配置的模型默认值决定
+func WithWebSearch(ctx context.Context, model string, enabled *bool) (context.Context, string) {
+	model, online := strings.CutSuffix(model, OnlineModelSuffix)
+	switch {
+	case enabled != nil:
+		return context.WithValue(ctx, webSearchKey{}, *enabled), model
+	case online:
+		return context.WithValue(ctx, webSearchKey{}, true), model
+	}
+	return ctx, model
+}
+
+// webSearchEnabled 判断本次请求是否开启联网搜索
+func webSearchEnabled(ctx context.Context, cfg *config.Config, model string) bool {
+	if enabled, ok := ctx.Value(webSearchKey{}).(bool); ok {
+		return enabled
+	}
+	return slices.Contains(cfg.WebSearch.Models, model)
+}
+
+// ChatCompletionAnnotation 回复消息的标注，目前只有联网搜索的 url_citation
+type ChatCompletionAnnotation struct {
+	Type        string       `json:"type"`
+	URLCitation *URLCitation `json:"url_citation,omitempty"`
+}
+
+// URLCitation 引用的网页，StartIndex 与 EndIndex 为引用标记在 content 中的字符位置
+type URLCitation struct {
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+}
@@ -0,0 +1,36 @@
+package types
+
+import (
+	"context"
+	"testing"
+
+	"monica-proxy/internal/config"
+)
+
+// TestWithWebSearch 测试联网搜索开关的优先级：请求字段 > 模型后缀 > 配置
+func TestWithWebSearch(t *testing.T) {
+	cfg := &config.Config{WebSearch: config.WebSearchConfig{Models: []string{"sonar"}}}
+	on, off := true, false
+	tests := []struct {
+		model    string
+		enabled  *bool
+		want     bool
+		stripped string
+	}{
+		{"gpt-4o", nil, false, "gpt-4o"},
+		{"gpt-4o:online", nil, true, "gpt-4o"},
+		{"gpt-4o:online", &off, false, "gpt-4o"},
+		{"gpt-4o", &on, true, "gpt-4o"},
+		{"sonar", nil, true, "sonar"},
+		{"sonar", &off, false, "sonar"},
+	}
+	for _, tt := range tests {
+		ctx, model := WithWebSearch(context.Background(), tt.model, tt.enabled)
+		if model != tt.stripped {
+			t.Errorf("WithWebSearch(%q) 模型名 = %q, 期望 %q", tt.model, model, tt.stripped)
+		}
+		if got := webSearchEnabled(ctx, cfg, model); got != tt.want {
+			t.Errorf("WithWebSearch(%q, %v) 开关 = %v, 期望 %v", tt.model, tt.enabled, got, tt.want)
+		}
+	}
+}
@@ -8,3 +8,8 @@ import (
 func Base64Decode(data string) ([]byte, error) {
 	return base64.StdEncoding.DecodeString(data)
 }
+
+// Base64Encode 将字节数组编码为base64字符串
+func Base64Encode(data []byte) string {
+	return base64.StdEncoding.EncodeToString(data)
+}
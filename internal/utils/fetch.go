@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monica-proxy/internal/config"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RemoteFile 从 http(s) 链接下载的文件
+type RemoteFile struct {
+	Data        []byte
+	ContentType string // 根据文件内容嗅探出的类型，不信任服务端返回的 Content-Type
+	URL         string // 跟随重定向后的最终地址
+}
+
+// remoteFetcher 全局远程文件下载客户端，在 InitHTTPClients 中初始化
+var remoteFetcher *remoteFetchClient
+
+// 除 net.IP 自带判断外，同样视为非公网的地址段
+var reservedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // 本网络
+	"100.64.0.0/10", // 运营商级 NAT
+	"192.0.0.0/24",  // IETF 协议分配
+	"198.18.0.0/15", // 基准测试
+	"240.0.0.0/4",   // 保留地址
+	"64:ff9b::/96",  // NAT64，可能映射到内网 IPv4
+	"2002::/16",     // 6to4，可能映射到内网 IPv4
+)
+
+// remoteFetchClient 带 SSRF 防护的下载客户端
+// 在建立连接时检查解析出的每个 IP，重定向后的地址同样经过检查，可以防止 DNS 重绑定
+type remoteFetchClient struct {
+	cfg          config.RemoteFetchConfig
+	client       *http.Client
+	dialer       *net.Dialer
+	allowedHosts map[string]bool
+	allowedNets  []*net.IPNet
+}
+
+// newRemoteFetchClient 创建远程文件下载客户端
+func newRemoteFetchClient(cfg config.RemoteFetchConfig) *remoteFetchClient {
+	f := &remoteFetchClient{
+		cfg: cfg,
+		dialer: &net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+		allowedHosts: make(map[string]bool),
+	}
+	for _, host := range cfg.AllowedHosts {
+		if _, network, err := net.ParseCIDR(host); err == nil {
+			f.allowedNets = append(f.allowedNets, network)
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			f.allowedNets = append(f.allowedNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		f.allowedHosts[strings.ToLower(host)] = true
+	}
+
+	f.client = &http.Client{
+		Transport: &http.Transport{
+			// 不走环境变量中的代理，否则连接检查只能看到代理地址
+			Proxy:               nil,
+			DialContext:         f.dialContext,
+			MaxIdleConns:        20,
+			IdleConnTimeout:     30 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		Timeout: cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme: %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// dialContext 解析主机名并逐个检查 IP，只连接允许访问的地址
+func (f *remoteFetchClient) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	hostAllowed := f.cfg.AllowPrivate || f.allowedHosts[strings.ToLower(host)]
+	var lastErr error
+	for _, ip := range ips {
+		if !hostAllowed && !f.ipAllowed(ip.IP) {
+			lastErr = fmt.Errorf("access to non-public address %s (%s) is not allowed", ip.IP, host)
+			continue
+		}
+		conn, err := f.dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no address found for host %s", host)
+	}
+	return nil, lastErr
+}
+
+// ipAllowed 公网地址或命中允许列表的地址可以访问
+func (f *remoteFetchClient) ipAllowed(ip net.IP) bool {
+	for _, network := range f.allowedNets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return IsPublicIP(ip)
+}
+
+// IsPublicIP 判断是否为公网地址，回环、私有、链路本地、组播及保留地址都不是公网地址
+func IsPublicIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// FetchRemoteFile 下载 http(s) 链接指向的文件
+// 限制文件大小、超时与重定向次数，并拒绝访问非公网地址（允许列表除外）
+func FetchRemoteFile(ctx context.Context, rawURL string) (*RemoteFile, error) {
+	if remoteFetcher == nil {
+		return nil, fmt.Errorf("remote fetch client is not initialized")
+	}
+	return remoteFetcher.fetch(ctx, rawURL)
+}
+
+func (f *remoteFetchClient) fetch(ctx context.Context, rawURL string) (*RemoteFile, error) {
+	if !f.cfg.Enabled {
+		return nil, fmt.Errorf("remote file fetching is disabled")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("unsupported url: only http(s) links are allowed")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36")
+	req.Header.Set("Accept", "image/*,*/*;q=0.8")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s failed: %w", u.Redacted(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s failed: status %d", u.Redacted(), resp.StatusCode)
+	}
+	if resp.ContentLength > f.cfg.MaxSize {
+		return nil, fmt.Errorf("file size exceeds limit: %d > %d", resp.ContentLength, f.cfg.MaxSize)
+	}
+
+	// 服务端可能不返回或谎报 Content-Length，读取时同样限制大小
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.cfg.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s failed: %w", u.Redacted(), err)
+	}
+	if int64(len(data)) > f.cfg.MaxSize {
+		return nil, fmt.Errorf("file size exceeds limit: > %d", f.cfg.MaxSize)
+	}
+
+	return &RemoteFile{
+		Data:        data,
+		ContentType: http.DetectContentType(data),
+		URL:         resp.Request.URL.String(),
+	}, nil
+}
+
+// IsRemoteURL 是否为 http(s) 链接
+func IsRemoteURL(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
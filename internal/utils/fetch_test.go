@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"monica-proxy/internal/config"
+)
+
+// 1x1 PNG
+var pngBytes = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89")
+
+func newTestFetcher(allowedHosts ...string) *remoteFetchClient {
+	return newRemoteFetchClient(config.RemoteFetchConfig{
+		Enabled:      true,
+		MaxSize:      1024,
+		Timeout:      5 * time.Second,
+		MaxRedirects: 1,
+		AllowedHosts: allowedHosts,
+	})
+}
+
+// TestIsPublicIP 测试公网地址判断
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+	}
+	for addr, want := range tests {
+		if got := IsPublicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("IsPublicIP(%s) = %v; 期望 %v", addr, got, want)
+		}
+	}
+}
+
+// TestFetchRemoteFileSSRF 测试默认拒绝访问回环地址，允许列表中的地址可以访问
+func TestFetchRemoteFileSSRF(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngBytes)
+	}))
+	defer server.Close()
+
+	if _, err := newTestFetcher().fetch(context.Background(), server.URL); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("应拒绝访问回环地址，实际 err = %v", err)
+	}
+
+	file, err := newTestFetcher("127.0.0.0/8").fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.ContentType != "image/png" {
+		t.Errorf("ContentType = %s; 期望 image/png", file.ContentType)
+	}
+}
+
+// TestFetchRemoteFileLimits 测试大小与重定向限制
+func TestFetchRemoteFileLimits(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 2048))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/redirect", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := newTestFetcher("127.0.0.1")
+	if _, err := fetcher.fetch(context.Background(), server.URL+"/large"); err == nil || !strings.Contains(err.Error(), "exceeds limit") {
+		t.Errorf("应拒绝超过大小限制的文件，实际 err = %v", err)
+	}
+	if _, err := fetcher.fetch(context.Background(), server.URL+"/redirect"); err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("应在超过重定向次数后停止，实际 err = %v", err)
+	}
+	if _, err := fetcher.fetch(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("应拒绝非 http(s) 链接")
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// InjectPrompt 将附加说明放在最后一条 user 消息之前
+// Monica 普通对话没有 system 角色，工具说明、输出格式等约束统一通过这种方式注入上游
+func InjectPrompt(messages []openai.ChatCompletionMessage, prompt string) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := &messages[i]
+		if msg.Role != openai.ChatMessageRoleUser {
+			continue
+		}
+		if len(msg.MultiContent) == 0 {
+			msg.Content = prompt + "\n\n" + msg.Content
+			return
+		}
+
+		parts := make([]openai.ChatMessagePart, 0, len(msg.MultiContent)+1)
+		parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: prompt})
+		msg.MultiContent = append(parts, msg.MultiContent...)
+		return
+	}
+}
+
+// MessageText 提取消息中的文本内容，多段内容以换行拼接
+func MessageText(msg openai.ChatCompletionMessage) string {
+	if len(msg.MultiContent) == 0 {
+		return msg.Content
+	}
+	var texts []string
+	for _, part := range msg.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeText {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
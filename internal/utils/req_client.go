@@ -21,6 +21,7 @@ var (
 func InitHTTPClients(cfg *config.Config) {
 	RestySSEClient = createSSEClient(cfg)
 	RestyDefaultClient = createDefaultClient(cfg)
+	remoteFetcher = newRemoteFetchClient(cfg.RemoteFetch)
 }
 
 // createSSEClient 创建SSE专用客户端
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// WarningHeader 请求处理中出现降级（如图片上传失败被跳过）时返回的响应头
+const WarningHeader = "X-Monica-Proxy-Warning"
+
+// ContextHeader 对话超出模型上下文窗口、被截断或摘要时返回的响应头
+const ContextHeader = "X-Monica-Proxy-Context"
+
+// ResponseHeaders 请求级别的响应头收集器，服务层通过 context 写入，在响应开始写出前复制到响应中
+type ResponseHeaders struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+type responseHeadersKey struct{}
+
+// WithResponseHeaders 在 context 中放入新的响应头收集器
+func WithResponseHeaders(ctx context.Context) (context.Context, *ResponseHeaders) {
+	headers := &ResponseHeaders{header: make(http.Header)}
+	return context.WithValue(ctx, responseHeadersKey{}, headers), headers
+}
+
+// AddResponseHeader 追加响应头，相同的值只保留一个；context 中没有收集器时忽略
+func AddResponseHeader(ctx context.Context, key, value string) {
+	headers, ok := ctx.Value(responseHeadersKey{}).(*ResponseHeaders)
+	if !ok {
+		return
+	}
+	headers.mu.Lock()
+	defer headers.mu.Unlock()
+	key = http.CanonicalHeaderKey(key)
+	if !slices.Contains(headers.header[key], value) {
+		headers.header[key] = append(headers.header[key], value)
+	}
+}
+
+// CopyTo 将收集到的响应头写入 dst
+func (h *ResponseHeaders) CopyTo(dst http.Header) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, values := range h.header {
+		dst[key] = append(dst[key], values...)
+	}
+}
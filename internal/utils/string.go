@@ -42,3 +42,18 @@ func RandStringUsingMathRand(n int) string {
 
 	return sb.String()
 }
+
+// PartialSuffixLen 返回 s 的最长后缀长度，该后缀同时是 target 的真前缀
+// 用于流式匹配时保留可能跨分片的标记开头
+func PartialSuffixLen(s, target string) int {
+	maxLen := len(target) - 1
+	if maxLen > len(s) {
+		maxLen = len(s)
+	}
+	for n := maxLen; n > 0; n-- {
+		if strings.HasSuffix(s, target[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"monica-proxy/internal/account"
 	"monica-proxy/internal/apiserver"
 	"monica-proxy/internal/config"
 	"monica-proxy/internal/logger"
-	"monica-proxy/internal/utils"
 	customMiddleware "monica-proxy/internal/middleware"
+	"monica-proxy/internal/monica"
+	"monica-proxy/internal/types"
+	"monica-proxy/internal/utils"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -35,6 +45,9 @@ func main() {
 	}
 }
 
+// shutdownTimeout 收到退出信号后等待进行中的请求结束的最长时间
+const shutdownTimeout = 30 * time.Second
+
 // App 应用实例
 type App struct {
 	config *config.Config
@@ -46,6 +59,24 @@ func newApp(cfg *config.Config) *App {
 	// 初始化HTTP客户端
 	utils.InitHTTPClients(cfg)
 
+	// 初始化账号池
+	if err := account.Init(cfg); err != nil {
+		logger.Fatal("初始化账号池失败", zap.Error(err))
+	}
+	account.StartHealthCheck(monica.CheckCookie)
+	// 加载 Files API 的文件元数据
+	if err := types.InitFileStore(cfg); err != nil {
+		logger.Fatal("加载文件元数据失败", zap.Error(err))
+	}
+	// 初始化上传缓存
+	if err := types.InitImageCache(cfg); err != nil {
+		logger.Fatal("加载上传缓存失败", zap.Error(err))
+	}
+	// 初始化会话存储
+	if err := types.InitSessionStore(cfg); err != nil {
+		logger.Fatal("初始化会话存储失败", zap.Error(err))
+	}
+
 	// 设置 Echo Server
 	e := echo.New()
 	e.Logger.SetOutput(io.Discard)
@@ -60,7 +91,7 @@ func newApp(cfg *config.Config) *App {
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 	e.Use(middleware.RequestID())
-	
+
 	// 添加限流中间件
 	e.Use(customMiddleware.RateLimit(cfg))
 
@@ -73,7 +104,40 @@ func newApp(cfg *config.Config) *App {
 	}
 }
 
-// Start 启动应用
+// Start 启动应用，收到 SIGINT/SIGTERM 后停止接收新请求，等待进行中的请求结束并写回上传缓存
 func (a *App) Start() error {
-	return a.server.Start(a.config.GetAddress())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- a.server.Start(a.config.GetAddress())
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	case <-ctx.Done():
+		logger.Info("收到退出信号，正在关闭服务器")
+	}
+	return a.Shutdown()
+}
+
+// Shutdown 关闭服务器，超时后不再等待进行中的请求，等待账号状态通知发送完成后把上传缓存写回磁盘
+func (a *App) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := a.server.Shutdown(ctx); err != nil {
+		logger.Warn("等待进行中的请求结束超时", zap.Error(err))
+	}
+	account.WaitNotifications()
+
+	if err := types.FlushImageCache(); err != nil {
+		logger.Error("保存上传缓存失败", zap.Error(err))
+		return err
+	}
+	logger.Info("服务器已关闭")
+	return nil
 }